@@ -1,77 +1,164 @@
 package cmd
 
 import (
-	"gitlab.com/magsh-2019/2/gud/gud"
+	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
 )
 
 var allF bool
+var patchF bool
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
-	Use:   "add <file>...",
+	Use:   "add <file>...\nadd -p [<file>...]",
 	Short: "Add receives the path of the updated files in the project, in order to use them in the next save",
 	Long: `Add command orders the program to keep track of the given files,
-therefore adding them to the next save`,
+therefore adding them to the next save.
+With -p, choose which changes to the tracked files to add, one hunk at a time`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var gConf gud.GlobalConfig
 		err := gud.LoadConfig(&gConf, gConf.GetPath())
 		if err != nil {
 			return err
 		}
+		if allF {
+			return addAll()
+		}
+		if patchF {
+			return addPatch(args)
+		}
 		err = checkArgsNum(1, len(args), modeMin)
 		if err != nil {
-			if allF {
-				files, err := getAllFiles()
-				if err != nil {
-					return err
-				} else {
-					return addFiles(files)
-				}
-			} else {
-				return err
-			}
-		} else {
-			return addFiles(args)
+			return err
 		}
+		return addFiles(args)
 	},
 }
 
-func addFiles(paths []string) error {
+func addAll() error {
 	p, err := LoadProject()
 	if err != nil {
 		return err
 	}
-	err = p.Checkpoint("add")
+	return withTransaction(p, "add", p.AddAll)
+}
+
+func addFiles(paths []string) error {
+	p, err := LoadProject()
 	if err != nil {
 		return err
 	}
-
-	defer func() {
+	for i, path := range paths {
+		temp, err := filepath.Abs(path)
 		if err != nil {
-			_ = p.Undo()
+			return err
 		}
-	}()
+		paths[i] = temp
+	}
 
+	return withTransaction(p, "add", func() error {
+		return p.Add(paths...)
+	})
+}
+
+func addPatch(paths []string) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
 	for i, path := range paths {
-		temp, err := filepath.Abs(path)
+		var abs string
+		abs, err = filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		paths[i], err = filepath.Rel(p.Path, abs)
 		if err != nil {
 			return err
 		}
-		paths[i] = temp
 	}
 
-	err = p.Add(paths...)
+	return withTransaction(p, "add", func() error {
+		return selectHunks(p, paths)
+	})
+}
+
+// selectHunks asks which hunks of the changes to the files under paths to add, and adds them.
+func selectHunks(p *gud.Project, paths []string) error {
+	diffs, err := p.Diff()
 	if err != nil {
 		return err
 	}
 
+	quit := false
+	for _, diff := range diffs {
+		if quit {
+			break
+		}
+		if diff.State != gud.StateModified || diff.Binary || !underPaths(diff.Path, paths) { // binary files can only be added whole
+			continue
+		}
+
+		var selected []gud.Hunk
+		for _, hunk := range diff.Hunks() {
+			err = gud.WriteDiffHeader(os.Stdout, diff)
+			if err != nil {
+				return err
+			}
+			err = gud.WriteHunk(os.Stdout, hunk)
+			if err != nil {
+				return err
+			}
+
+			answer := ""
+			prompt := &survey.Select{
+				Message: "Add this hunk?",
+				Options: []string{"yes", "no", "quit"},
+			}
+			err = survey.AskOne(prompt, &answer, icons)
+			if err != nil {
+				return err
+			}
+
+			if answer == "yes" {
+				selected = append(selected, hunk)
+			} else if answer == "quit" {
+				quit = true
+				break
+			}
+		}
+
+		if len(selected) > 0 {
+			err = p.ApplyPatch(diff.Path, selected)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
+// underPaths returns true if relPath is one of paths or inside one of them, or if there are no paths.
+func underPaths(relPath string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		if path == "." || relPath == path || strings.HasPrefix(relPath, path+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	addCmd.Flags().BoolVarP(&allF, "all", "a", false, "Add all files in the project")
+	addCmd.Flags().BoolVarP(&patchF, "patch", "p", false, "Choose the hunks to add")
 	rootCmd.AddCommand(addCmd)
 }
@@ -7,18 +7,27 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var branchRemotesF bool
+
 // branchCmd represents the branch command
 var branchCmd = &cobra.Command{
 	Use:   "branch",
 	Short: "Gives you information about your project's branches. Also takes place as the branch root command",
 	Long: `Branch is the root command for branch commands. This means in order to execute more
 complex branch commands, you will write "gud branch" and then your command. In addition,
-when branch is called by it's own it will print information about the branches in your project`,
+when branch is called by it's own it will print information about the branches in your project.
+With -r the remote-tracking branches, which fetch downloads, are printed instead`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		p, err := LoadProject()
 		if err != nil {
 			return err
 		}
+		if branchRemotesF {
+			return p.ListRemoteBranches(func(name string) error {
+				_, err := fmt.Println(name)
+				return err
+			})
+		}
 		branch, err := p.CurrentBranch()
 		fmt.Fprintf(os.Stdout, "Current branch is: \n%s\n", branch)
 		fmt.Fprintf(os.Stdout, "Other branches:\n")
@@ -32,5 +41,6 @@ when branch is called by it's own it will print information about the branches i
 }
 
 func init() {
+	branchCmd.Flags().BoolVarP(&branchRemotesF, "remotes", "r", false, "list the remote-tracking branches")
 	rootCmd.AddCommand(branchCmd)
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// checkIgnoreCmd represents the check-ignore command
+var checkIgnoreCmd = &cobra.Command{
+	Args:  cobra.MinimumNArgs(1),
+	Use:   "check-ignore <path>...",
+	Short: "Explain whether paths are ignored",
+	Long: `Print the rule that decides whether each of the paths is ignored,
+with the ignore file and the line it was read from.
+Rules are read from the .gudignore files of the project's directories,
+and from the global excludes file (~/.gudExcludes, or the one set with "gud config -g")`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		for _, path := range args {
+			rule, err := p.CheckIgnore(path)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case rule == nil:
+				fmt.Printf("%s: not ignored\n", path)
+			case rule.Negates():
+				fmt.Printf("%s: not ignored, by %s:%d:%s\n", path, rule.Source, rule.Line, rule.Pattern)
+			default:
+				fmt.Printf("%s: ignored, by %s:%d:%s\n", path, rule.Source, rule.Line, rule.Pattern)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkIgnoreCmd)
+}
@@ -21,38 +21,24 @@ While the other versions are preserved`,
 			return err
 		}
 
-		err = p.Checkpoint("checkpoint")
-		if err != nil {
-			return err
-		}
-
-		defer func() {
-			if err != nil {
-				_ = p.Undo()
-			}
-		}()
-
-		if len(args) == 0 {
-			for back := true; back; {
-				back, err = checkoutSelect(p)
-				if err != nil {
-					return err
+		return withTransaction(p, "checkpoint", func() error {
+			if len(args) == 0 {
+				for back := true; back; {
+					back, err = checkoutSelect(p)
+					if err != nil {
+						return err
+					}
 				}
+				return nil
 			}
-			return nil
-		}
 
-		err = checkArgsNum(1, len(args), "")
-		if err != nil {
-			return err
-		}
-
-		err = checkout(p, args[0])
-		if err != nil {
-			return err
-		}
+			err = checkArgsNum(1, len(args), "")
+			if err != nil {
+				return err
+			}
 
-		return nil
+			return checkout(p, args[0])
+		})
 	},
 }
 
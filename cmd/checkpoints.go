@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// checkpointsCmd represents the checkpoints command
+var checkpointsCmd = &cobra.Command{
+	Use:   "checkpoints",
+	Short: "Browse the checkpoints taken before commands. Also takes place as the checkpoints root command",
+	Long: `Checkpoints is the root command for the states of the working tree that are kept before every command
+that changes it. When called by it's own it will list the checkpoints`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listCheckpoints()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkpointsCmd)
+}
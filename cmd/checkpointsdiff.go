@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+// checkpointsDiffCmd represents the checkpoints diff command
+var checkpointsDiffCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(1),
+	Use:   "diff <id>",
+	Short: "Show the changes made to the files since a checkpoint",
+	Long: `A subcommand of "checkpoints" root command. Shows the changes in the working tree
+since the given checkpoint, line by line`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		hash, err := p.ResolveCheckpoint(args[0])
+		if err != nil {
+			return err
+		}
+
+		diffs, err := p.DiffCheckpoint(*hash)
+		if err != nil {
+			return err
+		}
+
+		return gud.WriteDiff(os.Stdout, diffs)
+	},
+}
+
+func init() {
+	checkpointsCmd.AddCommand(checkpointsDiffCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// checkpointsListCmd represents the checkpoints list command
+var checkpointsListCmd = &cobra.Command{
+	Args:  cobra.NoArgs,
+	Use:   "list",
+	Short: "List the checkpoints",
+	Long: `A subcommand of "checkpoints" root command. Prints the checkpoints from the newest to the oldest,
+with their ids, times, the commands they were taken before and the files that changed since the previous one`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listCheckpoints()
+	},
+}
+
+func listCheckpoints() error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := p.Checkpoints()
+	if err != nil {
+		return err
+	}
+
+	for _, checkpoint := range checkpoints {
+		fmt.Printf("%s %s %s\n", checkpoint.Hash.String()[:8],
+			checkpoint.Time.Format("2006-01-02 15:04:05"), checkpoint.Message)
+		for _, change := range checkpoint.Changes {
+			fmt.Printf("\t%s: %s\n", jsonStates[change.State], change.Path)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	checkpointsCmd.AddCommand(checkpointsListCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// checkpointsRestoreCmd represents the checkpoints restore command
+var checkpointsRestoreCmd = &cobra.Command{
+	Args:  cobra.MinimumNArgs(1),
+	Use:   "restore <id> [<path>...]",
+	Short: "Restore files from a checkpoint",
+	Long: `A subcommand of "checkpoints" root command. Returns the given files and directories,
+or the whole working tree, to the way they were in the given checkpoint.
+The index and the saved versions are not changed, and files that the checkpoint does not have are kept`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return restoreCheckpoint(args[0], args[1:])
+	},
+}
+
+func restoreCheckpoint(id string, paths []string) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	hash, err := p.ResolveCheckpoint(id)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, "checkpoints-restore", func() error {
+		return p.RestoreCheckpoint(*hash, paths...)
+	})
+}
+
+func init() {
+	checkpointsCmd.AddCommand(checkpointsRestoreCmd)
+}
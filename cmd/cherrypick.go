@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var pickContinueF bool
+var pickAbortF bool
+
+// cherryPickCmd represents the cherry-pick command
+var cherryPickCmd = &cobra.Command{
+	Use:   "cherry-pick <version>...\ncherry-pick --continue\ncherry-pick --abort",
+	Short: "Apply the changes of existing versions on top of the current branch",
+	Long: `Apply the changes each of the given versions made, compared to the version before it,
+on top of the current branch. The new versions keep the message and author of the original ones.
+If there are conflicts, resolve them, add the files and run "gud cherry-pick --continue",
+or cancel the whole cherry-pick with "gud cherry-pick --abort"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		if pickContinueF || pickAbortF {
+			err = checkArgsNum(0, len(args), "")
+			if err != nil {
+				return err
+			}
+
+			if pickAbortF {
+				return withTransaction(p, "cherry-pick-abort", p.AbortCherryPick)
+			}
+			return withTransaction(p, "cherry-pick-continue", p.ContinueCherryPick)
+		}
+
+		err = checkArgsNum(1, len(args), modeMin)
+		if err != nil {
+			return err
+		}
+
+		hashes := make([]gud.ObjectHash, len(args))
+		for i, arg := range args {
+			hash, err := p.Resolve(arg)
+			if err != nil {
+				return err
+			}
+			hashes[i] = *hash
+		}
+
+		return withTransaction(p, "cherry-pick", func() error {
+			return p.CherryPick(hashes...)
+		})
+	},
+}
+
+func init() {
+	cherryPickCmd.Flags().BoolVar(&pickContinueF, "continue", false, "continue after resolving conflicts")
+	cherryPickCmd.Flags().BoolVar(&pickAbortF, "abort", false, "cancel the cherry-pick and restore the branch")
+	rootCmd.AddCommand(cherryPickCmd)
+}
@@ -5,6 +5,9 @@ import (
 	"github.com/spf13/cobra"
 	"gitlab.com/magsh-2019/2/gud/gud"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 )
 
 // cloneCmd represents the clone command
@@ -27,7 +30,7 @@ but will be a copy of an existing project from the server`,
 			domain, owner, project = args[0], args[1], args[2]
 		}
 
-		p, err := gud.StartHeadless("")
+		p, err := startClone()
 		if err != nil {
 			return err
 		}
@@ -38,9 +41,21 @@ but will be a copy of an existing project from the server`,
 			return err
 		}
 
+		// Resume the clone that was interrupted
+		query := url.Values{}
+		query.Set("branch", gud.FirstBranchName)
+		id, skip, err := p.IncomingTransfer(gud.FirstBranchName)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			query.Set("resume", id)
+			query.Set("skip", strconv.Itoa(skip))
+		}
+
 		req, err := http.NewRequest(http.MethodGet,
-			fmt.Sprintf("%s/api/v1/user/%s/project/%s/pull?branch=%s",
-				domain, owner, project, gud.FirstBranchName), nil)
+			fmt.Sprintf("%s/api/v1/user/%s/project/%s/pull?%s",
+				domain, owner, project, query.Encode()), nil)
 		if err != nil {
 			return err
 		}
@@ -57,10 +72,19 @@ but will be a copy of an existing project from the server`,
 			return err
 		}
 
-		_, err = p.PullBranch(gud.FirstBranchName, resp.Body, resp.Header.Get("Content-Type"))
+		bar := newProgressBar("Cloning")
+		hash, err := p.PullBranchWith(gud.FirstBranchName, resp.Body, resp.Header.Get("Content-Type"),
+			gud.PullOptions{Progress: bar.Update})
+		bar.Done()
 		if err != nil {
 			return err
 		}
+		if hash != nil {
+			err = p.SetRemoteBranch(gud.DefaultRemote, gud.FirstBranchName, *hash)
+			if err != nil {
+				return err
+			}
+		}
 		err = p.AddHead()
 		if err != nil {
 			return err
@@ -74,6 +98,11 @@ but will be a copy of an existing project from the server`,
 
 		config.OwnerName = owner
 		config.ProjectName = project
+		if len(args) == 3 { // the project is pulled from its server, not the one of the global configuration
+			config.Remotes = map[string]gud.Remote{
+				gud.DefaultRemote: {Domain: domain, Owner: owner, Project: project},
+			}
+		}
 		err = p.WriteConfig(config)
 		if err != nil {
 			return err
@@ -83,6 +112,30 @@ but will be a copy of an existing project from the server`,
 	},
 }
 
+// startClone starts the project of a clone in the current directory,
+// or loads the project of a clone that was interrupted there, to resume it.
+func startClone() (*gud.Project, error) {
+	p, err := gud.StartHeadless("")
+	if !os.IsExist(err) {
+		return p, err
+	}
+
+	wd, werr := os.Getwd()
+	if werr != nil {
+		return nil, werr
+	}
+	existing, lerr := gud.Load(wd)
+	if lerr != nil || existing.Path != wd {
+		return nil, err
+	}
+	id, _, lerr := existing.IncomingTransfer(gud.FirstBranchName)
+	if lerr != nil || id == "" {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
 func init() {
 	rootCmd.AddCommand(cloneCmd)
 }
@@ -67,35 +67,16 @@ Command can also be used by only writing "config"`,
 			if err != nil {
 				return err
 			}
-		} else {
-			err = getConfigChanges(args, &config)
-			if err != nil {
-				return err
-			}
-			err = p.Checkpoint("config-change")
-			if err != nil {
-				return err
-			}
+			return gud.WriteConfig(gConfig, gConfig.GetPath())
 		}
 
-		defer func() {
-			if err != nil {
-				_ = p.Undo()
-			}
-		}()
-		if globalF {
-			err = gud.WriteConfig(gConfig, gConfig.GetPath())
-			if err != nil {
-				return err
-			}
-		} else {
-			err = p.WriteConfig(config)
-			if err != nil {
-				return err
-			}
+		err = getConfigChanges(args, &config)
+		if err != nil {
+			return err
 		}
-
-		return nil
+		return withTransaction(p, "config-change", func() error {
+			return p.WriteConfig(config)
+		})
 	},
 }
 
@@ -117,7 +98,7 @@ func getConfigChanges(args []string, config *gud.Config) error {
 	if len(args) == 0 {
 		prompt := &survey.Select{
 			Message: "Choose field:",
-			Options: []string{"Project name", "Owner name", "Checkpoints", "Automatic push"},
+			Options: []string{"Project name", "Owner name", "Checkpoints", "Automatic push", "Quiet period"},
 		}
 		err = survey.AskOne(prompt, &field, icons)
 		if err != nil {
@@ -162,6 +143,12 @@ func getConfigChanges(args []string, config *gud.Config) error {
 		}
 	case "automatic push", "automaticpush":
 		config.AutoPush = value == "true"
+	case "quiet period", "quietperiod":
+		var err error
+		config.QuietPeriod, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s is not an integer\n", value)
+		}
 	default:
 		return fmt.Errorf("%s is not a configuration field\n", field)
 	}
@@ -175,7 +162,7 @@ func getGlobalConfigChanges(args []string, config *gud.GlobalConfig) error {
 	if len(args) == 0 {
 		prompt := &survey.Select{
 			Message: "Choose field:",
-			Options: []string{"Name", "Token", "Server domain"},
+			Options: []string{"Name", "Token", "Server domain", "Merge tool", "Diff tool", "Excludes file", "Parallelism"},
 		}
 		err = survey.AskOne(prompt, &field, icons)
 		if err != nil {
@@ -200,6 +187,17 @@ func getGlobalConfigChanges(args []string, config *gud.GlobalConfig) error {
 	case "server domain", "serverdomain":
 		checkUrl(&value)
 		config.ServerDomain = value
+	case "merge tool", "mergetool":
+		config.MergeTool = value
+	case "diff tool", "difftool":
+		config.DiffTool = value
+	case "excludes file", "excludesfile":
+		config.ExcludesFile = value
+	case "parallelism":
+		config.Parallelism, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s is not an integer\n", value)
+		}
 	default:
 		return fmt.Errorf("%s is not a configuration field\n", field)
 	}
@@ -20,30 +20,17 @@ var createCmd = &cobra.Command{
 			return fmt.Errorf("failed to load project: %s", err.Error())
 		}
 
-		err = p.Checkpoint("branch-create")
-		if err != nil {
-			return err
-		}
-
-		defer func() {
-			if err != nil {
-				_ = p.Undo()
-			}
-		}()
-
-		err = p.CreateBranch(branchName)
-		if err != nil {
-			return err
-		}
-
-		if !stayF {
-			err = checkout(p, branchName)
+		return withTransaction(p, "branch-create", func() error {
+			err := p.CreateBranch(branchName)
 			if err != nil {
 				return err
 			}
-		}
 
-		return nil
+			if !stayF {
+				return checkout(p, branchName)
+			}
+			return nil
+		})
 	},
 }
 
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var diffStagedF bool
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [--staged]\ndiff <version> [<version>]",
+	Short: "Show the changes made to the files, line by line",
+	Long: `Show the changes in the working tree that were not added yet.
+With --staged, show the added changes compared to the current version instead.
+With a single version, show the changes from it to the current version,
+and with two versions, show the changes between them`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		diffs, err := getDiffs(p, diffStagedF, args)
+		if err != nil {
+			return err
+		}
+
+		return gud.WriteDiff(os.Stdout, diffs)
+	},
+}
+
+func getDiffs(p *gud.Project, staged bool, args []string) ([]gud.FileDiff, error) {
+	err := checkArgsNum(2, len(args), modeMax)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		if staged {
+			return p.DiffStaged()
+		}
+		return p.Diff()
+	}
+
+	from, err := p.Resolve(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := p.CurrentHash()
+	if len(args) == 2 {
+		to, err = p.Resolve(args[1])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.DiffVersions(*from, *to)
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffStagedF, "staged", false, "show the added changes")
+	rootCmd.AddCommand(diffCmd)
+}
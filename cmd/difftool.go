@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var diffToolStagedF bool
+var diffToolF string
+
+// diffToolCmd represents the difftool command
+var diffToolCmd = &cobra.Command{
+	Use:   "difftool [--staged]\ndifftool <version> [<version>]",
+	Short: "Show the changes made to the files in an external diff tool",
+	Long: `Open every file "gud diff" would show in an external diff tool, one after the other.
+The tool is set with "gud config -g difftool <tool>" or with --tool.
+meld, kdiff3 and vimdiff are known, and any other command can be given with
+$LOCAL and $REMOTE in place of the old and the new file`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		tool := diffToolF
+		if tool == "" {
+			var gConfig gud.GlobalConfig
+			err = gud.LoadConfig(&gConfig, gConfig.GetPath())
+			if err != nil {
+				return err
+			}
+			tool = gConfig.DiffTool
+		}
+
+		diffs, err := getDiffs(p, diffToolStagedF, args)
+		if err != nil {
+			return err
+		}
+
+		for _, diff := range diffs {
+			err = diffFile(tool, diff)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+func diffFile(tool string, diff gud.FileDiff) error {
+	dir, err := ioutil.TempDir("", "gud-difftool")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	local, err := writeToolFile(dir, diff.Path, "old", diff.Old)
+	if err != nil {
+		return err
+	}
+	remote, err := writeToolFile(dir, diff.Path, "new", diff.New)
+	if err != nil {
+		return err
+	}
+
+	return runTool(tool, diffTools, map[string]string{
+		"LOCAL":  local,
+		"REMOTE": remote,
+		"MERGED": filepath.Base(diff.Path),
+	})
+}
+
+func init() {
+	diffToolCmd.Flags().BoolVar(&diffToolStagedF, "staged", false, "show the added changes")
+	diffToolCmd.Flags().StringVarP(&diffToolF, "tool", "t", "", "the diff tool to use")
+	rootCmd.AddCommand(diffToolCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var fetchRemoteF string
+
+// fetchCmd represents the fetch command
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [branch...]",
+	Short: "Download the server's branches without changing yours",
+	Long: `Download the versions of the server's branches, or only of the given ones,
+into remote-tracking branches like origin/master.
+Your branches and files are not changed, so the downloaded versions can be
+inspected with log and diff before they are merged.
+The branches are fetched from the origin remote, unless another one is given with --remote.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		t, name, err := openTransport(p, fetchRemoteF, false)
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+		if name == "" {
+			return errors.New("a path can only be fetched from after it is added as a remote")
+		}
+
+		branches := args
+		if len(branches) == 0 {
+			remoteHashes, err := t.Branches()
+			if err != nil {
+				return err
+			}
+			for branch := range remoteHashes {
+				branches = append(branches, branch)
+			}
+			sort.Strings(branches)
+		}
+
+		for _, branch := range branches {
+			err = fetchBranch(p, t, name, branch)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+// fetchBranch downloads the versions of a branch in a remote into its remote-tracking branch.
+func fetchBranch(p *gud.Project, t gud.Transport, remoteName, branch string) error {
+	return withTransaction(p, "", func() error {
+		start, err := p.GetRemoteBranch(remoteName, branch)
+		if err != nil {
+			return err
+		}
+
+		name := remoteName + "/" + branch
+		bar := newProgressBar("Fetching " + name)
+		hash, err := p.FetchFrom(t, remoteName, branch, bar.Update)
+		bar.Done()
+		if err != nil {
+			return err
+		}
+
+		if hash != nil && (start == nil || *start != *hash) {
+			fmt.Printf("%s: %s\n", name, hash)
+		}
+		return nil
+	})
+}
+
+func init() {
+	fetchCmd.Flags().StringVar(&fetchRemoteF, "remote", gud.DefaultRemote, "the remote to fetch from")
+	rootCmd.AddCommand(fetchCmd)
+}
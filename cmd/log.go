@@ -10,22 +10,30 @@ import (
 
 // logCmd represents the log command
 var logCmd = &cobra.Command{
-	Use:   "log",
+	Args:  cobra.MaximumNArgs(1),
+	Use:   "log [version]",
 	Short: "Show saved versions log",
 	Long: `Print list of saved versions,
 including information about them,
-such as hash, message, and time`,
+such as hash, message, and time.
+Given a version hash, a branch or a remote-tracking branch like origin/master,
+the versions up to it are printed instead of the ones up to the current version`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		p, err := LoadProject()
 		if err != nil {
 			return err
 		}
 
-		hash, err := p.CurrentHash()
+		var hash *gud.ObjectHash
+		if len(args) == 0 {
+			hash, err = p.CurrentHash()
+		} else {
+			hash, err = p.Resolve(args[0])
+		}
 		if err != nil {
 			return err
 		}
-		v, err := p.CurrentVersion()
+		v, err := p.LoadVersion(*hash)
 		if err != nil {
 			return err
 		}
@@ -52,8 +60,15 @@ func printLog(p gud.Project, hash gud.ObjectHash, version gud.Version) error {
 		}
 	}
 
-	fmt.Fprintf(os.Stdout, "Message: %s\nTime: %s\nAuthor: %s\nHash: %s\n\n",
+	fmt.Fprintf(os.Stdout, "Message: %s\nTime: %s\nAuthor: %s\nHash: %s\n",
 		version.Message, version.Time.Format("2006-01-02 15:04:05"), version.Author, hash)
+	if picked := version.PickedFrom(); picked != nil {
+		fmt.Fprintf(os.Stdout, "Picked from: %s\n", *picked)
+	}
+	for _, rename := range version.Renames() {
+		fmt.Fprintf(os.Stdout, "Renamed: %s -> %s\n", rename.From, rename.To)
+	}
+	fmt.Fprintln(os.Stdout)
 	return nil
 }
 
@@ -5,6 +5,11 @@ import (
 	"gitlab.com/magsh-2019/2/gud/gud"
 )
 
+var mergeNoFfF bool
+var mergeFfOnlyF bool
+var mergeSquashF bool
+var mergeMessageF string
+
 // mergeCmd represents the merge command
 var mergeCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
@@ -13,51 +18,52 @@ var mergeCmd = &cobra.Command{
 	Long: `Merge files from a given file to the current one.
 If there are changes in a file in both branches,
 will create a "conflict", allowing you to decide what to keep
-and what to replace from the both of the files`,
+and what to replace from the both of the files.
+When the current branch has no changes of its own it is fast-forwarded,
+unless --no-ff is given. With --ff-only nothing else is allowed.
+With --squash the changes are saved as a single ordinary version`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		p, err := LoadProject()
 		if err != nil {
 			return err
 		}
 
-		err = p.Checkpoint("merge")
+		from, err := p.Resolve(args[0])
 		if err != nil {
 			return err
 		}
 
-		defer func() {
-			if err != nil {
-				_ = p.Undo()
-			}
-		}()
-
-		var dst gud.ObjectHash
-		err = stringToHash(&dst, args[0])
-		if err == nil {
-			_, err = p.MergeHash(dst)
-			if err != nil {
+		return withTransaction(p, "merge", func() error {
+			// A branch is merged by its name, which goes in the message of the merge version
+			var dst gud.ObjectHash
+			if stringToHash(&dst, args[0]) == nil && dst == *from {
+				_, err = p.MergeHash(*from, mergeOptions())
+			} else {
 				_, err = mergeByName(p, args[0])
-				if err != nil {
-					return err
-				}
-			}
-
-		} else {
-			_, err = mergeByName(p, args[0])
-			if err != nil {
-				return err
 			}
-		}
-
-		return nil
+			return err
+		})
 	},
 }
 
 func mergeByName(p *gud.Project, name string) (v *gud.Version, err error) {
-	v, err = p.MergeBranch(name)
+	v, err = p.MergeBranch(name, mergeOptions())
 	return
 }
 
+func mergeOptions() gud.MergeOptions {
+	return gud.MergeOptions{
+		NoFastForward:   mergeNoFfF,
+		FastForwardOnly: mergeFfOnlyF,
+		Squash:          mergeSquashF,
+		Message:         mergeMessageF,
+	}
+}
+
 func init() {
+	mergeCmd.Flags().BoolVar(&mergeNoFfF, "no-ff", false, "save a merge version even when fast-forwarding is possible")
+	mergeCmd.Flags().BoolVar(&mergeFfOnlyF, "ff-only", false, "merge only if the branch can be fast-forwarded")
+	mergeCmd.Flags().BoolVar(&mergeSquashF, "squash", false, "save the changes as a single ordinary version")
+	mergeCmd.Flags().StringVarP(&mergeMessageF, "message", "m", "", "the message of the saved version")
 	rootCmd.AddCommand(mergeCmd)
 }
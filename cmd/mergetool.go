@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var mergeToolF string
+
+// mergeToolCmd represents the mergetool command
+var mergeToolCmd = &cobra.Command{
+	Use:   "mergetool [<file>...]",
+	Short: "Resolve conflicts with an external merge tool",
+	Long: `Open every conflicting file, or only the given ones, in an external merge tool,
+with copies of the file from the common version (base), the current branch (ours)
+and the merged version (theirs).
+When the tool exits successfully the file is added, marking it as resolved.
+The tool is set with "gud config -g mergetool <tool>" or with --tool.
+meld, kdiff3 and vimdiff are known, and any other command can be given with
+$BASE, $LOCAL, $REMOTE and $MERGED in place of the files`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		tool := mergeToolF
+		if tool == "" {
+			var gConfig gud.GlobalConfig
+			err = gud.LoadConfig(&gConfig, gConfig.GetPath())
+			if err != nil {
+				return err
+			}
+			tool = gConfig.MergeTool
+		}
+
+		conflicts, err := p.Conflicts()
+		if err != nil {
+			return err
+		}
+		if len(conflicts) == 0 {
+			fmt.Println("No files need merging")
+			return nil
+		}
+
+		selected := make(map[string]bool)
+		for _, arg := range args {
+			path, err := filepath.Abs(arg)
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(p.Path, path)
+			if err != nil {
+				return err
+			}
+			selected[relPath] = true
+		}
+
+		// The files resolved before one that fails are kept, so the transaction is committed either way
+		var mergeErr error
+		err = withTransaction(p, "mergetool", func() error {
+			for _, conflict := range conflicts {
+				if len(selected) > 0 && !selected[conflict.Path] {
+					continue
+				}
+
+				err := mergeFile(p, tool, conflict)
+				if err != nil {
+					mergeErr = fmt.Errorf("%s was not resolved: %v", conflict.Path, err)
+					return nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return mergeErr
+	},
+}
+
+// mergeFile runs the merge tool on a conflicting file, and adds it if the tool succeeds.
+func mergeFile(p *gud.Project, tool string, conflict gud.Conflict) error {
+	dir, err := ioutil.TempDir("", "gud-mergetool")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"MERGED": filepath.Join(p.Path, conflict.Path)}
+	for _, version := range []struct {
+		name, label string
+		hash        *gud.ObjectHash
+	}{
+		{"BASE", "base", conflict.Base},
+		{"LOCAL", "ours", conflict.Ours},
+		{"REMOTE", "theirs", conflict.Theirs},
+	} {
+		content := ""
+		if version.hash != nil {
+			content, err = p.ReadBlob(*version.hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		files[version.name], err = writeToolFile(dir, conflict.Path, version.label, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = runTool(tool, mergeTools, files)
+	if err != nil {
+		return err
+	}
+
+	return p.Add(files["MERGED"])
+}
+
+func init() {
+	mergeToolCmd.Flags().StringVarP(&mergeToolF, "tool", "t", "", "the merge tool to use")
+	rootCmd.AddCommand(mergeToolCmd)
+}
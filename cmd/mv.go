@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// mvCmd represents the mv command
+var mvCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(2),
+	Use:   "mv <source> <destination>",
+	Short: "Move or rename a file or a directory",
+	Long: `Moves a file or a directory, and adds the move to the index.
+If the destination is a directory, the source is moved into it.
+The moved files are shown as renamed, rather than deleted and added again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return moveFile(args[0], args[1])
+	},
+}
+
+func moveFile(src, dst string) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, "move", func() error {
+		return p.Move(src, dst)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+const progressBarWidth = 30
+
+// progressInterval is the least time between two renders of a progress bar.
+const progressInterval = 100 * time.Millisecond
+
+// progressBar renders the progress of a transfer on the standard error.
+type progressBar struct {
+	label string
+	mutex sync.Mutex
+	last  time.Time
+	shown bool
+}
+
+func newProgressBar(label string) *progressBar {
+	return &progressBar{label: label}
+}
+
+// Update renders the progress, unless it was rendered too recently and the transfer is not done.
+func (b *progressBar) Update(progress gud.Progress) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	done := progress.Total != 0 && progress.Objects >= progress.Total
+	if !done && time.Since(b.last) < progressInterval {
+		return
+	}
+	b.last = time.Now()
+	b.shown = true
+
+	if progress.Total == 0 {
+		fmt.Fprintf(os.Stderr, "\r%s: %d objects, %s", b.label, progress.Objects, formatBytes(progress.Bytes))
+		return
+	}
+
+	filled := progressBarWidth * progress.Objects / progress.Total
+	fmt.Fprintf(os.Stderr, "\r%s: [%s%s] %d/%d objects, %s", b.label,
+		strings.Repeat("#", filled), strings.Repeat(" ", progressBarWidth-filled),
+		progress.Objects, progress.Total, formatBytes(progress.Bytes))
+}
+
+// Done ends the line of the progress bar, if it was rendered.
+func (b *progressBar) Done() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.shown {
+		fmt.Fprintln(os.Stderr)
+		b.shown = false
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
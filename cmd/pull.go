@@ -4,79 +4,71 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	"gitlab.com/magsh-2019/2/gud/gud"
-	"net/http"
 )
 
+var pullRemoteF string
+
 // pullCmd represents the pull command
 var pullCmd = &cobra.Command{
-	Use:   "pull [url]",
+	Args:  cobra.MaximumNArgs(1),
+	Use:   "pull [remote|path]",
 	Short: "Get the server's version of your branch",
-	Long: `Get the server's version of your branch.'`,
+	Long: `Get the server's version of your branch.
+The branch is pulled from the origin remote, unless another one is given with --remote or as an argument.
+A path to a project on this computer can be given instead of a remote.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		p, err := LoadProject()
 		if err != nil {
 			return err
 		}
 
-		var gConfig gud.GlobalConfig
-		err = gud.LoadConfig(&gConfig, gConfig.GetPath())
-		if err != nil {
-			return err
+		if len(args) == 1 {
+			return PullBranch(p, args[0])
 		}
-
-		return PullBranch(p, gConfig.ServerDomain)
+		return PullBranch(p, pullRemoteF)
 	},
 }
 
-func PullBranch(p *gud.Project, domain string) (err error) {
-	var config gud.Config
-	err = p.LoadConfig(&config)
-	if err != nil {
-		return
-	}
-
-	var gConfig gud.GlobalConfig
-	err = gud.LoadConfig(&gConfig, gConfig.GetPath())
-	if err != nil {
-		return
-	}
+// PullBranch pulls the current branch from a remote or from the project at a path, and resets the working tree to it.
+func PullBranch(p *gud.Project, remoteName string) error {
+	return withTransaction(p, "", func() error {
+		return pullBranch(p, remoteName)
+	})
+}
 
+// pullBranch is PullBranch in the running transaction.
+func pullBranch(p *gud.Project, remoteName string) error {
 	branch, err := p.CurrentBranch()
 	if err != nil {
-		return
-	}
-	hash, err := p.GetBranch(branch)
-	if err != nil {
-		return
+		return err
 	}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/user/%s/project/%s/pull?branch=%s&start=%s", domain, config.OwnerName, config.ProjectName, branch, hash),
-		nil)
+	t, name, err := openTransport(p, remoteName, false)
 	if err != nil {
-		return
+		return err
 	}
+	defer t.Close()
 
-	req.AddCookie(&http.Cookie{Name: "session", Value: gConfig.Token})
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return
+	bar := newProgressBar("Pulling")
+	pulled, err := p.PullFrom(t, branch, bar.Update)
+	bar.Done()
+	if _, ok := err.(gud.NonFastForwardError); ok {
+		return fmt.Errorf("the branch %s has versions the remote does not have. fetch and merge instead", branch)
 	}
-	defer resp.Body.Close()
-
-	err = checkResponseError(resp)
 	if err != nil {
 		return err
 	}
-
-	_, err = p.PullBranch(branch, resp.Body, resp.Header.Get("Content-Type"))
-	if err != nil {
-		return err
+	if pulled != nil && name != "" {
+		err = p.SetRemoteBranch(name, branch, *pulled)
+		if err != nil {
+			return err
+		}
 	}
 
 	return p.Reset()
 }
 
 func init() {
+	pullCmd.Flags().StringVar(&pullRemoteF, "remote", gud.DefaultRemote, "the remote to pull from")
 	rootCmd.AddCommand(pullCmd)
 }
@@ -2,127 +2,140 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"gitlab.com/magsh-2019/2/gud/gud"
-	"io"
 	"net/http"
 )
 
 const projectNotFoundError = "project not found"
 
+var pushRemoteF string
+var pushForceF bool
+var pushForceWithLeaseF string
+
 // pushCmd represents the push command
 var pushCmd = &cobra.Command{
-	Args:  cobra.MaximumNArgs(1),
-	Use:   "push [branch]",
+	Args:  cobra.MaximumNArgs(2),
+	Use:   "push [remote|path] [branch]",
 	Short: "Push current branch to server",
 	Long: `Upload your branch into the server,
-by creating or updating the branch on the server to be like your local one.`,
+by creating or updating the branch on the server to be like your local one.
+The branch is pushed to the origin remote, unless another one is given with --remote or as an argument.
+A path to a project on this computer, like a backup drive, can be given instead of a remote,
+and a project is started there if it has none. The branch that such a project has checked out cannot be pushed to.
+A push that would lose versions that only the remote branch has is rejected. Pull them first,
+or replace the remote branch with --force, or with --force-with-lease <version> to only replace it
+if it is still at the given version, like origin/master when it was last fetched.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) == 0 {
-			return pushBranch("")
+		options, err := pushOptions()
+		if err != nil {
+			return err
+		}
+
+		switch len(args) {
+		case 0:
+			return pushBranch(pushRemoteF, "", options)
+		case 1:
+			p, err := LoadProject()
+			if err != nil {
+				return err
+			}
+			hash, err := p.GetBranch(args[0])
+			if err != nil {
+				return err
+			}
+			if hash != nil {
+				return pushBranch(pushRemoteF, args[0], options)
+			}
+			return pushBranch(args[0], "", options)
 		}
-		return pushBranch(args[0])
+		return pushBranch(args[0], args[1], options)
 	},
 }
 
-func pushBranch(branch string) error {
-	p, err := LoadProject()
-	if err != nil {
-		return err
+// pushOptions returns the options of a push from the flags.
+func pushOptions() (gud.PushOptions, error) {
+	options := gud.PushOptions{Force: pushForceF}
+	if pushForceWithLeaseF == "" {
+		return options, nil
 	}
 
-	if branch == "" {
-		branch, err = p.CurrentBranch()
+	// The version of the lease may be one that was never pulled
+	var lease gud.ObjectHash
+	err := stringToHash(&lease, pushForceWithLeaseF)
+	if err != nil || len(pushForceWithLeaseF) != hex.EncodedLen(len(lease)) {
+		p, err := LoadProject()
 		if err != nil {
-			return err
+			return options, err
 		}
+		resolved, err := p.Resolve(pushForceWithLeaseF)
+		if err != nil {
+			return options, err
+		}
+		lease = *resolved
 	}
 
-	var config gud.Config
-	err = p.LoadConfig(&config)
-	if err != nil {
-		return err
-	}
+	options.Force = true
+	options.Lease = &lease
+	return options, nil
+}
 
-	var gConfig gud.GlobalConfig
-	err = gud.LoadConfig(&gConfig, gConfig.GetPath())
+// pushBranch pushes a branch, or the current one if it is empty, to a remote or to the project at a path.
+func pushBranch(remoteName, branch string, options gud.PushOptions) error {
+	p, err := LoadProject()
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodGet,
-		fmt.Sprintf("%s/api/v1/user/%s/project/%s/branch/%s",
-			gConfig.ServerDomain, config.OwnerName, config.ProjectName, branch), nil)
-	if err != nil {
-		return err
-	}
-	req.AddCookie(&http.Cookie{Name: "session", Value: gConfig.Token})
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusNotFound {
-		var message gud.ErrorResponse
-		err := json.NewDecoder(resp.Body).Decode(&message)
-		if err != nil {
-			return errors.New(resp.Status)
-		}
+	return withTransaction(p, "", func() error {
+		return pushProjectBranch(p, remoteName, branch, options)
+	})
+}
 
-		if message.Error == projectNotFoundError {
-			err = createServerProject(config.ProjectName, gConfig)
-			if err != nil {
-				return err
-			}
-		}
-	} else {
-		err = checkResponseError(resp)
+// pushProjectBranch is pushBranch in the running transaction of the project.
+func pushProjectBranch(p *gud.Project, remoteName, branch string, options gud.PushOptions) error {
+	var err error
+	if branch == "" {
+		branch, err = p.CurrentBranch()
 		if err != nil {
 			return err
 		}
 	}
 
-	var startHash *gud.ObjectHash
-	if resp.StatusCode != http.StatusNotFound {
-		var hash gud.ObjectHash
-		_, err = resp.Body.Read(hash[:])
-		if err != nil && err != io.EOF {
-			return err
-		}
-		startHash = &hash
-	}
-
-	var buf bytes.Buffer
-	boundary, err := p.PushBranch(&buf, branch, startHash)
-	req, err = http.NewRequest(http.MethodPost,
-		fmt.Sprintf("%s/api/v1/user/%s/project/%s/push?branch=%s",
-			gConfig.ServerDomain, config.OwnerName, config.ProjectName, branch), &buf)
+	t, name, err := openTransport(p, remoteName, true)
 	if err != nil {
 		return err
 	}
-
-	req.AddCookie(&http.Cookie{Name: "session", Value: gConfig.Token})
-	req.Header.Add("Content-Type", "multipart/mixed; boundary="+boundary)
-
-	resp, err = client.Do(req)
-	if err != nil {
-		return err
+	defer t.Close()
+
+	bar := newProgressBar("Pushing")
+	options.Progress = bar.Update
+	hash, err := p.PushTo(t, branch, options)
+	bar.Done()
+	if _, ok := err.(gud.NonFastForwardError); ok {
+		if options.Lease != nil {
+			return fmt.Errorf("the branch %s was changed since %s. fetch it to see the changes", branch,
+				options.Lease)
+		}
+		return fmt.Errorf("the branch %s has versions you do not have. pull them first, "+
+			"or push with --force to replace them", branch)
 	}
-	defer resp.Body.Close()
-	err = checkResponseError(resp)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// The remote has the branch as it is here now
+	if name == "" {
+		return nil
+	}
+	return p.SetRemoteBranch(name, branch, *hash)
 }
 
-func createServerProject(name string, gConf gud.GlobalConfig) error {
-	request := gud.CreateProjectRequest{Name: name}
+func createServerProject(remote gud.Remote, gConf gud.GlobalConfig) error {
+	request := gud.CreateProjectRequest{Name: remote.Project}
 
 	var buf bytes.Buffer
 	err := json.NewEncoder(&buf).Encode(request)
@@ -131,7 +144,7 @@ func createServerProject(name string, gConf gud.GlobalConfig) error {
 	}
 
 	req, err := http.NewRequest(http.MethodPost,
-		fmt.Sprintf("%s/api/v1/projects/import", gConf.ServerDomain), &buf)
+		fmt.Sprintf("%s/api/v1/projects/import", remote.Domain), &buf)
 	if err != nil {
 		return err
 	}
@@ -152,5 +165,9 @@ func createServerProject(name string, gConf gud.GlobalConfig) error {
 }
 
 func init() {
+	pushCmd.Flags().StringVar(&pushRemoteF, "remote", gud.DefaultRemote, "the remote to push to")
+	pushCmd.Flags().BoolVarP(&pushForceF, "force", "f", false, "replace the remote branch even if versions are lost")
+	pushCmd.Flags().StringVar(&pushForceWithLeaseF, "force-with-lease", "",
+		"replace the remote branch only if it is at the given version")
 	rootCmd.AddCommand(pushCmd)
 }
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var rebaseContinueF bool
+var rebaseSkipF bool
+var rebaseAbortF bool
+var rebaseInteractiveF bool
+var rebasePlanF string
+
+// rebaseCmd represents the rebase command
+var rebaseCmd = &cobra.Command{
+	Use:   "rebase <branch>\nrebase --continue\nrebase --skip\nrebase --abort",
+	Short: "Replay the versions of the current branch on top of another branch",
+	Long: `Replay every version of the current branch that the given branch does not have
+on top of it, and move the current branch to the result.
+With -i the plan opens in an editor, where versions can be reordered,
+reworded, squashed into the one before them or dropped.
+A plan written in advance can be given with --plan.
+If there are conflicts, resolve them, add the files and run "gud rebase --continue",
+leave the version out with "gud rebase --skip",
+or cancel the whole rebase with "gud rebase --abort"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		if rebaseContinueF || rebaseSkipF || rebaseAbortF {
+			err = checkArgsNum(0, len(args), "")
+			if err != nil {
+				return err
+			}
+
+			if rebaseAbortF {
+				return withTransaction(p, "rebase-abort", p.AbortRebase)
+			}
+			if rebaseSkipF {
+				return withTransaction(p, "rebase-skip", p.SkipRebase)
+			}
+			return withTransaction(p, "rebase-continue", p.ContinueRebase)
+		}
+
+		err = checkArgsNum(1, len(args), "")
+		if err != nil {
+			return err
+		}
+
+		onto, err := p.Resolve(args[0])
+		if err != nil {
+			return err
+		}
+
+		plan, err := p.RebasePlan(*onto)
+		if err != nil {
+			return err
+		}
+
+		if rebasePlanF != "" {
+			plan, err = readRebasePlan(rebasePlanF)
+		} else if rebaseInteractiveF {
+			plan, err = editRebasePlan(plan)
+		} else {
+			current, err := p.CurrentHash()
+			if err != nil {
+				return err
+			}
+			upToDate, err := p.IsAncestor(*onto, *current)
+			if err != nil {
+				return err
+			}
+			if upToDate {
+				fmt.Println("Current branch is up to date")
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		return withTransaction(p, "rebase", func() error {
+			return p.Rebase(*onto, plan)
+		})
+	},
+}
+
+func readRebasePlan(path string) ([]gud.RebaseStep, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return gud.ParseRebasePlan(file)
+}
+
+func editRebasePlan(plan []gud.RebaseStep) ([]gud.RebaseStep, error) {
+	var buf bytes.Buffer
+	err := gud.WriteRebasePlan(&buf, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	var edited string
+	prompt := &survey.Editor{
+		Message:       "Edit the rebase plan:",
+		Default:       buf.String(),
+		HideDefault:   true,
+		AppendDefault: true,
+		FileName:      "rebase-plan*.txt",
+	}
+	err = survey.AskOne(prompt, &edited, icons)
+	if err != nil {
+		return nil, err
+	}
+
+	return gud.ParseRebasePlan(strings.NewReader(edited))
+}
+
+func init() {
+	rebaseCmd.Flags().BoolVar(&rebaseContinueF, "continue", false, "continue after resolving conflicts")
+	rebaseCmd.Flags().BoolVar(&rebaseSkipF, "skip", false, "leave out the conflicting version and continue")
+	rebaseCmd.Flags().BoolVar(&rebaseAbortF, "abort", false, "cancel the rebase and restore the branch")
+	rebaseCmd.Flags().BoolVarP(&rebaseInteractiveF, "interactive", "i", false, "edit the plan before the rebase")
+	rebaseCmd.Flags().StringVar(&rebasePlanF, "plan", "", "read the plan from a file")
+	rootCmd.AddCommand(rebaseCmd)
+}
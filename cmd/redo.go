@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var redoCountF int
+
+// redoCmd represents the redo command
+var redoCmd = &cobra.Command{
+	Args:  cobra.NoArgs,
+	Use:   "redo [-n <count>]",
+	Short: "Redo the last undone command",
+	Long: `Bring back the changes of the last command that was undone.
+With -n, the last given number of undone commands are redone.
+Commands can only be redone until another command changes the project.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return redoCommands(redoCountF)
+	},
+}
+
+func redoCommands(n int) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, "", func() error {
+		names, err := p.Redo(n)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			fmt.Println("Redone:", name)
+		}
+		return nil
+	})
+}
+
+func init() {
+	redoCmd.Flags().IntVarP(&redoCountF, "count", "n", 1, "the number of commands to redo")
+	rootCmd.AddCommand(redoCmd)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+// remoteCmd represents the remote command
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage the servers your project is pushed to. Also takes place as the remote root command",
+	Long: `Remote is the root command for the remotes of your project, which are projects in servers,
+on this computer or behind a command, that it is pushed to, pulled from and fetched from.
+When called by it's own it will list the remotes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listRemotes()
+	},
+}
+
+// remoteURL returns the address of the API of the project of a remote.
+func remoteURL(remote gud.Remote) string {
+	return fmt.Sprintf("%s/api/v1/user/%s/project/%s", remote.Domain, remote.Owner, remote.Project)
+}
+
+func init() {
+	rootCmd.AddCommand(remoteCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var remoteAddPathF string
+var remoteAddCommandF string
+
+// remoteAddCmd represents the remote add command
+var remoteAddCmd = &cobra.Command{
+	Args:  cobra.RangeArgs(1, 4),
+	Use:   "add <name> [domain] <owner> <project>",
+	Short: "Add a remote",
+	Long: `A subcommand of "remote" root command. Adds a remote for the given project of the given owner,
+in the given server, or in the server of the global configuration if none is given.
+With --path, the remote is the project at a path on this computer instead,
+and with --command it is the project served by a command line that runs "gud serve-stdio",
+like "ssh host gud serve-stdio projects/gud"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var remote gud.Remote
+		switch {
+		case remoteAddPathF != "" || remoteAddCommandF != "":
+			if len(args) != 1 {
+				return errors.New("a remote with a path or a command takes only a name")
+			}
+			remote.Command = remoteAddCommandF
+			if remoteAddPathF != "" {
+				path, err := filepath.Abs(remoteAddPathF)
+				if err != nil {
+					return err
+				}
+				remote.Path = path
+			}
+		case len(args) == 3:
+			remote = gud.Remote{Owner: args[1], Project: args[2]}
+		case len(args) == 4:
+			remote = gud.Remote{Domain: args[1], Owner: args[2], Project: args[3]}
+		default:
+			return errors.New("a remote in a server needs an owner and a project")
+		}
+
+		return changeRemotes("remote-add", func(p *gud.Project) error {
+			return p.AddRemote(args[0], remote)
+		})
+	},
+}
+
+// changeRemotes changes the remotes of the project, after taking a checkpoint.
+func changeRemotes(name string, change func(p *gud.Project) error) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, name, func() error {
+		return change(p)
+	})
+}
+
+func init() {
+	remoteAddCmd.Flags().StringVar(&remoteAddPathF, "path", "", "the path of a project on this computer")
+	remoteAddCmd.Flags().StringVar(&remoteAddCommandF, "command", "", `a command line that runs "gud serve-stdio"`)
+	remoteCmd.AddCommand(remoteAddCmd)
+}
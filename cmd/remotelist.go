@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// remoteListCmd represents the remote list command
+var remoteListCmd = &cobra.Command{
+	Args:  cobra.NoArgs,
+	Use:   "list",
+	Short: "List the remotes",
+	Long: `A subcommand of "remote" root command. Prints the names of the remotes,
+with their servers, owners and projects, or their paths or commands`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listRemotes()
+	},
+}
+
+func listRemotes() error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	remotes, err := p.Remotes()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(remotes))
+	for name := range remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		remote, err := p.GetRemote(name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case remote.Path != "":
+			fmt.Printf("%s\t%s\n", name, remote.Path)
+		case remote.Command != "":
+			fmt.Printf("%s\t%s\n", name, remote.Command)
+		default:
+			fmt.Printf("%s\t%s %s/%s\n", name, remote.Domain, remote.Owner, remote.Project)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	remoteCmd.AddCommand(remoteListCmd)
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+// remoteRemoveCmd represents the remote remove command
+var remoteRemoveCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(1),
+	Use:   "remove <name>",
+	Short: "Remove a remote",
+	Long:  `A subcommand of "remote" root command. Removes the given remote and its remote-tracking branches`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changeRemotes("remote-remove", func(p *gud.Project) error {
+			return p.RemoveRemote(args[0])
+		})
+	},
+}
+
+func init() {
+	remoteCmd.AddCommand(remoteRemoveCmd)
+}
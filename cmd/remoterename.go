@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+// remoteRenameCmd represents the remote rename command
+var remoteRenameCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(2),
+	Use:   "rename <old> <new>",
+	Short: "Rename a remote",
+	Long:  `A subcommand of "remote" root command. Renames the given remote, with its remote-tracking branches`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changeRemotes("remote-rename", func(p *gud.Project) error {
+			return p.RenameRemote(args[0], args[1])
+		})
+	},
+}
+
+func init() {
+	remoteCmd.AddCommand(remoteRenameCmd)
+}
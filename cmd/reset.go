@@ -12,14 +12,19 @@ var resetCmd = &cobra.Command{
 	Long: `Reset removes all unstaged changes in the project,
 returning it to the latest state that was saved.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		p, err := LoadProject()
-		if err != nil {
-			return err
-		}
-		return p.Reset()
+		return resetProject()
 	},
 }
 
+func resetProject() error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, "", p.Reset)
+}
+
 func init() {
 	rootCmd.AddCommand(resetCmd)
 }
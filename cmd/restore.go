@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+var restoreSourceF string
+var restoreStagedF bool
+var restoreWorkTreeF bool
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Args:  cobra.MinimumNArgs(1),
+	Use:   "restore [--source <version>] [--staged] [--worktree] <path>...",
+	Short: "Restore files from a version or from the index",
+	Long: `Returns the given files and directories to the way they were saved.
+By default, the working tree is restored from the index, discarding the changes that were not added.
+With --staged, the index is restored from the current version instead, removing the added changes,
+and with --worktree too, both are restored from it.
+With --source, the files are restored from the given version or branch.
+Tracked files that do not exist in the source are deleted, and untracked files are kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return restoreFiles(args)
+	},
+}
+
+func restoreFiles(paths []string) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	options := gud.RestoreOptions{Staged: restoreStagedF, WorkTree: restoreWorkTreeF}
+	if restoreSourceF != "" {
+		options.Source, err = p.Resolve(restoreSourceF)
+		if err != nil {
+			return err
+		}
+	}
+
+	return withTransaction(p, "restore", func() error {
+		return p.Restore(options, paths...)
+	})
+}
+
+func init() {
+	restoreCmd.Flags().StringVarP(&restoreSourceF, "source", "s", "", "the version or branch to restore the files from")
+	restoreCmd.Flags().BoolVarP(&restoreStagedF, "staged", "S", false, "restore the index")
+	restoreCmd.Flags().BoolVarP(&restoreWorkTreeF, "worktree", "W", false, "restore the working tree, which is the default without --staged")
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -43,33 +43,17 @@ If the file was already deleted, it will only be removed on the index index.`,
 			}
 		}
 
-		err := removeFiles(args)
-
-		if !keepF {
-			deleteFiles(args)
-		}
-
-		return err
+		return removeFiles(args, !keepF)
 	},
 }
 
-func removeFiles(paths []string) error {
+// removeFiles removes files from the index, and deletes them unless they are kept.
+func removeFiles(paths []string, deleted bool) error {
 	p, err := LoadProject()
 	if err != nil {
 		return err
 	}
 
-	err = p.Checkpoint("remove")
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if err != nil {
-			_ = p.Undo()
-		}
-	}()
-
 	for i, path := range paths {
 		temp, err := filepath.Abs(path)
 		if err != nil {
@@ -78,18 +62,38 @@ func removeFiles(paths []string) error {
 		paths[i] = temp
 	}
 
-	err = p.Remove(paths...)
-	if err != nil {
-		return err
-	}
+	return withTransaction(p, "remove", func() error {
+		// Decide what to delete first, as what is tracked changes
+		var files, dirs []string
+		if deleted {
+			for _, path := range paths {
+				pathFiles, pathDirs, err := p.DeletedFiles(path)
+				if err != nil {
+					return err
+				}
+				files = append(files, pathFiles...)
+				dirs = append(dirs, pathDirs...)
+			}
+		}
 
-	return nil
-}
+		err := p.Remove(paths...)
+		if err != nil {
+			return err
+		}
 
-func deleteFiles(paths []string) {
-	for _, path := range paths {
-		_ = os.RemoveAll(path)
-	}
+		for _, file := range files {
+			err = os.Remove(file)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		// Directories are deleted once their files are, and kept if they hold ignored files
+		for i := len(dirs) - 1; i >= 0; i-- {
+			_ = os.Remove(dirs[i])
+		}
+
+		return nil
+	})
 }
 
 func init() {
@@ -32,36 +32,27 @@ and removing deleted ones`,
 			return err
 		}
 
-		err = p.Checkpoint("save")
-		if err != nil {
-			return err
-		}
-
-		defer func() {
+		return withTransaction(p, "save", func() error {
+			_, err := saveVersion(p, message)
 			if err != nil {
-				_ = p.Undo()
+				return err
 			}
-		}()
-
-		_, err = saveVersion(p, message)
-		if err != nil {
-			return err
-		}
-
-		var config gud.Config
-		err = p.LoadConfig(&config)
-		if err != nil {
-			return err
-		}
 
-		if config.AutoPush {
-			err = pushBranch(message)
+			var config gud.Config
+			err = p.LoadConfig(&config)
 			if err != nil {
 				return err
 			}
-		}
 
-		return nil
+			if config.AutoPush {
+				err = pushProjectBranch(p, gud.DefaultRemote, "", gud.PushOptions{})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
 	},
 }
 
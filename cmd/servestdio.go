@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+// serveStdioCmd represents the serve-stdio command
+var serveStdioCmd = &cobra.Command{
+	Args:  cobra.MaximumNArgs(1),
+	Use:   "serve-stdio [path]",
+	Short: "Serve a project to push and pull on standard input and output",
+	Long: `Serve the project at the given path, or in the current directory, to another gud that runs this command,
+usually through ssh or another tunnel, as the command of a remote (see "gud remote add --command").
+A project without a working tree is started there if it has none.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "."
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		t, err := gud.NewLocalTransport(path, true)
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+
+		return gud.ServeStdio(t, os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveStdioCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// sparseCmd represents the sparse command
+var sparseCmd = &cobra.Command{
+	Use:   "sparse",
+	Short: "Prints the paths the working tree is limited to. Also takes place as the sparse root command",
+	Long: `Sparse is the root command for limiting the working tree to some paths of the project.
+When called by it's own it will print the paths the working tree is limited to`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		paths, err := p.SparsePaths()
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			fmt.Println("The whole project is checked out")
+			return nil
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sparseCmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// sparseDisableCmd represents the sparse disable command
+var sparseDisableCmd = &cobra.Command{
+	Args:  cobra.NoArgs,
+	Use:   "disable",
+	Short: "Check out the whole project again",
+	Long:  `A subcommand of "sparse" root command. Brings back the files that were outside the sparse paths`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSparse(nil, "sparse-disable")
+	},
+}
+
+func init() {
+	sparseCmd.AddCommand(sparseDisableCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// sparseSetCmd represents the sparse set command
+var sparseSetCmd = &cobra.Command{
+	Args:  cobra.MinimumNArgs(1),
+	Use:   "set <path>...",
+	Short: "Limit the working tree to some paths",
+	Long: `A subcommand of "sparse" root command. Removes the files outside the given paths from the working tree,
+and brings the ones inside them. The removed files stay in the project, and are kept unchanged when saving`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSparse(args, "sparse-set")
+	},
+}
+
+func setSparse(paths []string, checkpoint string) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, checkpoint, func() error {
+		return p.SetSparse(paths...)
+	})
+}
+
+func init() {
+	sparseCmd.AddCommand(sparseSetCmd)
+}
@@ -1,32 +1,90 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"gitlab.com/magsh-2019/2/gud/gud"
 )
 
+var porcelainF bool
+var jsonF bool
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Prints the status of the current version compared to the last one",
 	Long: `Prints the status of all of the changes made from the last save.
-Will show any changed removed or added file or folder`,
+Will show any changed removed or added file or folder
+
+With --porcelain, prints a format that stays the same between versions of gud.
+It starts with lines of the state of the project:
+  # branch <name>     the current branch, or the one head was detached from
+  # head <hash>       the current version
+  # detached          head is detached
+  # merging <hash>    a merge of the version is waiting to be saved
+  # cherry-picking    a cherry-pick stopped on conflicts
+  # rebasing          a rebase stopped on conflicts
+followed by a line "XY <path>" for every changed file, where X is the change
+added to the index and Y the change in the working tree that was not added:
+A for new, D for deleted, M for modified, R for renamed, U for conflict and . for none.
+A renamed file is printed as "<old path> -> <path>".
+Paths are separated by slashes. A path that has a double quote, a backslash, a control character,
+" -> ", or a space at its start or end is printed in double quotes, with C escapes like \" \\ \t \n
+and \ooo (three octal digits) for the other control characters.
+
+With --json, prints the same information as a JSON object.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return printStatus()
+		if porcelainF && jsonF {
+			return errors.New("--porcelain and --json cannot be used together")
+		}
+
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		// A running gud watch already knows which files changed
+		status, err := p.WatchedStatus()
+		if err == nil && status == nil {
+			status, err = p.Status()
+		}
+		if err != nil {
+			return err
+		}
+
+		if porcelainF {
+			return printPorcelainStatus(status)
+		}
+		if jsonF {
+			return printJSONStatus(status)
+		}
+		return printStatus(status)
 	},
 }
 
-func trackedCallback(relPath string, state gud.FileState) error {
+func trackedCallback(relPath, from string, state gud.FileState, partial bool) error {
 	stateMsg := make(map[gud.FileState]string)
 	stateMsg[gud.StateNew] = "new: "
 	stateMsg[gud.StateRemoved] = "deleted: "
 	stateMsg[gud.StateModified] = "modified: " //Change to empty when get a full message
+	stateMsg[gud.StateConflict] = "conflict: "
+	stateMsg[gud.StateRenamed] = "renamed: "
 
-	fMsg := stateMsg[state] + relPath + "\n"
-	_, err := fmt.Fprintf(os.Stdout, fMsg)
+	fMsg := stateMsg[state] + relPath
+	if state == gud.StateRenamed {
+		fMsg = stateMsg[state] + from + " -> " + relPath
+	}
+	if partial {
+		fMsg += " (also modified after it was added)"
+	}
+	_, err := fmt.Fprintln(os.Stdout, fMsg)
 	return err
 }
 
@@ -41,15 +99,187 @@ func unTrackedCallback(relPath string, state gud.FileState) error {
 	return err
 }
 
-func printStatus() error {
-	p, err := LoadProject()
-	if err != nil {
-		return err
+func printStatus(status *gud.ProjectStatus) error {
+	for _, file := range status.Files {
+		if file.Staged != gud.StateUnchanged {
+			err := trackedCallback(file.Path, file.From, file.Staged, file.Working != gud.StateUnchanged)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, file := range status.Files {
+		if file.Working != gud.StateUnchanged {
+			err := unTrackedCallback(file.Path, file.Working)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+var porcelainCodes = map[gud.FileState]byte{
+	gud.StateNew:       'A',
+	gud.StateRemoved:   'D',
+	gud.StateModified:  'M',
+	gud.StateConflict:  'U',
+	gud.StateRenamed:   'R',
+	gud.StateUnchanged: '.',
+}
+
+func printPorcelainStatus(status *gud.ProjectStatus) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# branch %s\n# head %s\n", status.Branch, status.Hash)
+	if status.IsDetached {
+		out.WriteString("# detached\n")
+	}
+	if status.IsMerging() {
+		fmt.Fprintf(&out, "# merging %s\n", status.MergedHash)
+	}
+	if status.CherryPicking {
+		out.WriteString("# cherry-picking\n")
+	}
+	if status.Rebasing {
+		out.WriteString("# rebasing\n")
+	}
+
+	for _, file := range status.Files {
+		path := quotePorcelainPath(file.Path)
+		if file.Staged == gud.StateRenamed {
+			path = quotePorcelainPath(file.From) + " -> " + path
+		}
+		fmt.Fprintf(&out, "%c%c %s\n", porcelainCodes[file.Staged], porcelainCodes[file.Working], path)
+	}
+
+	_, err := fmt.Fprint(os.Stdout, out.String())
+	return err
+}
+
+// quotePorcelainPath returns a path as it is printed by --porcelain, in double quotes with C escapes
+// if it could not be told apart from the rest of the line otherwise.
+func quotePorcelainPath(path string) string {
+	path = filepath.ToSlash(path)
+	needed := strings.HasPrefix(path, " ") || strings.HasSuffix(path, " ") || strings.Contains(path, " -> ")
+	for i := 0; i < len(path) && !needed; i++ {
+		c := path[i]
+		needed = c < ' ' || c == 0x7f || c == '"' || c == '\\'
+	}
+	if !needed {
+		return path
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '"', '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(c)
+		case '\t':
+			quoted.WriteString(`\t`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		default:
+			if c < ' ' || c == 0x7f {
+				fmt.Fprintf(&quoted, "\\%03o", c)
+			} else {
+				quoted.WriteByte(c)
+			}
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+type jsonStatus struct {
+	Branch        string           `json:"branch"`
+	Head          string           `json:"head"`
+	Detached      bool             `json:"detached"`
+	Merging       *string          `json:"merging"`
+	CherryPicking bool             `json:"cherryPicking"`
+	Rebasing      bool             `json:"rebasing"`
+	Files         []jsonFileStatus `json:"files"`
+}
+
+type jsonFileStatus struct {
+	Path     string        `json:"path"`
+	From     *string       `json:"from,omitempty"` // the path a renamed file was moved from
+	Staged   *string       `json:"staged"`
+	Working  *string       `json:"working"`
+	Conflict *jsonConflict `json:"conflict,omitempty"`
+}
+
+// jsonConflict has the hashes of the versions of a conflicting file, null where it does not exist
+type jsonConflict struct {
+	Base   *string `json:"base"`
+	Ours   *string `json:"ours"`
+	Theirs *string `json:"theirs"`
+}
+
+var jsonStates = map[gud.FileState]string{
+	gud.StateNew:      "new",
+	gud.StateRemoved:  "deleted",
+	gud.StateModified: "modified",
+	gud.StateConflict: "conflict",
+	gud.StateRenamed:  "renamed",
+}
+
+func printJSONStatus(status *gud.ProjectStatus) error {
+	hashString := func(hash *gud.ObjectHash) *string {
+		if hash == nil {
+			return nil
+		}
+		s := hash.String()
+		return &s
+	}
+	stateString := func(state gud.FileState) *string {
+		if state == gud.StateUnchanged {
+			return nil
+		}
+		s := jsonStates[state]
+		return &s
+	}
+
+	res := jsonStatus{
+		Branch:        status.Branch,
+		Head:          status.Hash.String(),
+		Detached:      status.IsDetached,
+		Merging:       hashString(status.MergedHash),
+		CherryPicking: status.CherryPicking,
+		Rebasing:      status.Rebasing,
+		Files:         make([]jsonFileStatus, 0, len(status.Files)),
+	}
+	for _, file := range status.Files {
+		fileRes := jsonFileStatus{
+			Path:    filepath.ToSlash(file.Path),
+			Staged:  stateString(file.Staged),
+			Working: stateString(file.Working),
+		}
+		if file.Staged == gud.StateRenamed {
+			from := filepath.ToSlash(file.From)
+			fileRes.From = &from
+		}
+		if file.Conflict != nil {
+			fileRes.Conflict = &jsonConflict{
+				Base:   hashString(file.Conflict.Base),
+				Ours:   hashString(file.Conflict.Ours),
+				Theirs: hashString(file.Conflict.Theirs),
+			}
+		}
+		res.Files = append(res.Files, fileRes)
 	}
 
-	return p.Status(trackedCallback, unTrackedCallback)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(res)
 }
 
 func init() {
+	statusCmd.Flags().BoolVar(&porcelainF, "porcelain", false, "print the status in a stable format for scripts")
+	statusCmd.Flags().BoolVar(&jsonF, "json", false, "print the status as JSON")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The command lines of the known tools.
+// $BASE, $LOCAL, $REMOTE and $MERGED are replaced by the paths of the compared files.
+var mergeTools = map[string]string{
+	"meld":    "meld --output $MERGED $LOCAL $BASE $REMOTE",
+	"kdiff3":  "kdiff3 --auto --L1 base --L2 ours --L3 theirs -o $MERGED $BASE $LOCAL $REMOTE",
+	"vimdiff": "vimdiff -f -d -c wincmd\\ J $MERGED $LOCAL $BASE $REMOTE",
+}
+
+var diffTools = map[string]string{
+	"meld":    "meld $LOCAL $REMOTE",
+	"kdiff3":  "kdiff3 $LOCAL $REMOTE",
+	"vimdiff": "vimdiff -f -d $LOCAL $REMOTE",
+}
+
+// runTool runs a known tool or a custom command line, with the paths of the compared files.
+// It fails if the tool exits with an error.
+func runTool(tool string, known map[string]string, files map[string]string) error {
+	if tool == "" {
+		return errors.New(`no tool configured. set one with "gud config -g" or pass --tool`)
+	}
+
+	command, ok := known[tool]
+	if !ok {
+		command = tool
+	}
+
+	fields := splitCommand(command)
+	if len(fields) == 0 {
+		return errors.New(`the tool command is empty. set one with "gud config -g" or pass --tool`)
+	}
+
+	var args []string
+	for _, field := range fields {
+		args = append(args, os.Expand(field, func(name string) string {
+			if path, ok := files[name]; ok {
+				return path
+			}
+			return os.Getenv(name)
+		}))
+	}
+
+	toolCmd := exec.Command(args[0], args[1:]...)
+	toolCmd.Stdin = os.Stdin
+	toolCmd.Stdout = os.Stdout
+	toolCmd.Stderr = os.Stderr
+	return toolCmd.Run()
+}
+
+// splitCommand splits a command line on spaces, except for spaces escaped with a backslash.
+func splitCommand(command string) []string {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+	for _, c := range command {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ' ':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(c)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// writeToolFile writes the contents of a version of a file for a tool to read.
+// The copy keeps the extension of the file, so the tool can recognize its type.
+func writeToolFile(dir, relPath, label, content string) (string, error) {
+	ext := filepath.Ext(relPath)
+	name := strings.TrimSuffix(filepath.Base(relPath), ext) + "." + label + ext
+	path := filepath.Join(dir, name)
+	return path, ioutil.WriteFile(path, []byte(content), 0644)
+}
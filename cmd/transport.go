@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gitlab.com/magsh-2019/2/gud/gud"
+)
+
+// openTransport returns a transport to a remote of the project, or to the project at a path if there is
+// no such remote, with the name of the remote, which is empty for a path.
+// If create is set, a missing project in a server is created.
+func openTransport(p *gud.Project, name string, create bool) (gud.Transport, string, error) {
+	remotes, err := p.Remotes()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, ok := remotes[name]; !ok {
+		if !isPath(name) {
+			return nil, "", errors.New("unknown remote: " + name)
+		}
+		t, err := gud.NewLocalTransport(name, create)
+		return t, "", err
+	}
+
+	remote, err := p.GetRemote(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case remote.Path != "":
+		t, err := gud.NewLocalTransport(remote.Path, create)
+		return t, name, err
+	case remote.Command != "":
+		args := splitCommand(remote.Command)
+		if len(args) == 0 {
+			return nil, "", errors.New("empty command of remote: " + name)
+		}
+		serveCmd := exec.Command(args[0], args[1:]...)
+		serveCmd.Stderr = os.Stderr
+		t, err := gud.NewStdioTransport(serveCmd)
+		return t, name, err
+	}
+
+	var gConfig gud.GlobalConfig
+	err = gud.LoadConfig(&gConfig, gConfig.GetPath())
+	if err != nil {
+		return nil, "", err
+	}
+	return &httpTransport{client: &http.Client{}, remote: *remote, gConfig: gConfig, create: create}, name, nil
+}
+
+// isPath returns whether an argument that is not the name of a remote is a path to a project.
+func isPath(arg string) bool {
+	return strings.ContainsRune(arg, '/') || strings.ContainsRune(arg, os.PathSeparator) ||
+		strings.HasPrefix(arg, ".")
+}
+
+// httpTransport reaches a project in a server through its API.
+type httpTransport struct {
+	client  *http.Client
+	remote  gud.Remote
+	gConfig gud.GlobalConfig
+	create  bool // create the project if the server does not have it
+}
+
+func (t *httpTransport) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, remoteURL(t.remote)+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.AddCookie(&http.Cookie{Name: "session", Value: t.gConfig.Token})
+	return t.client.Do(req)
+}
+
+func (t *httpTransport) Branches() (map[string]gud.ObjectHash, error) {
+	resp, err := t.do(http.MethodGet, "/branches", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if t.create && resp.StatusCode == http.StatusNotFound {
+		var message gud.ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&message)
+		if err != nil {
+			return nil, errors.New(resp.Status)
+		}
+		if message.Error != projectNotFoundError {
+			return nil, errors.New(message.Error)
+		}
+
+		err = createServerProject(t.remote, t.gConfig)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]gud.ObjectHash{}, nil
+	}
+
+	err = checkResponseError(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var branches map[string]string
+	err = json.NewDecoder(resp.Body).Decode(&branches)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]gud.ObjectHash, len(branches))
+	for branch, branchHash := range branches {
+		var hash gud.ObjectHash
+		err = stringToHash(&hash, branchHash)
+		if err != nil {
+			return nil, err
+		}
+		hashes[branch] = hash
+	}
+	return hashes, nil
+}
+
+// IncomingTransfer reads the part of a push the server has from the headers of the branch,
+// which are set even if the branch does not exist yet.
+func (t *httpTransport) IncomingTransfer(branch string) (string, int, error) {
+	resp, err := t.do(http.MethodGet, "/branch/"+branch, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		err = checkResponseError(resp)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	id := resp.Header.Get(gud.ResumeHeader)
+	if id == "" {
+		return "", 0, nil
+	}
+	skip, err := strconv.Atoi(resp.Header.Get(gud.SkipHeader))
+	if err != nil {
+		return "", 0, err
+	}
+	return id, skip, nil
+}
+
+func (t *httpTransport) Receive(branch string, in io.Reader, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("%s/push?branch=%s", remoteURL(t.remote), url.QueryEscape(branch)), in)
+	if err != nil {
+		return err
+	}
+
+	req.AddCookie(&http.Cookie{Name: "session", Value: t.gConfig.Token})
+	req.Header.Add("Content-Type", contentType)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkTransferError(resp, branch)
+}
+
+// checkTransferError is checkResponseError for a push or a pull of a branch, which returns a
+// gud.NonFastForwardError for the error the server reports with its code.
+func checkTransferError(resp *http.Response, branch string) error {
+	if resp.StatusCode != http.StatusConflict {
+		return checkResponseError(resp)
+	}
+
+	var message gud.ErrorResponse
+	err := json.NewDecoder(resp.Body).Decode(&message)
+	if err != nil {
+		return errors.New(resp.Status)
+	}
+	if message.Code == gud.NonFastForwardCode {
+		return gud.NonFastForwardError{Branch: branch}
+	}
+	return errors.New(message.Error)
+}
+
+func (t *httpTransport) Send(branch string, options gud.PushOptions) (io.ReadCloser, string, error) {
+	query := url.Values{}
+	query.Set("branch", branch)
+	if options.Start != nil {
+		query.Set("start", options.Start.String())
+	}
+	for _, have := range options.Haves {
+		query.Add("have", have.String())
+	}
+	if options.Resume != "" {
+		query.Set("resume", options.Resume)
+		query.Set("skip", strconv.Itoa(options.Skip))
+	}
+	if options.Force {
+		query.Set("force", "true")
+	}
+
+	resp, err := t.do(http.MethodGet, "/pull?"+query.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = checkTransferError(resp, branch)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, "", err
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+func (t *httpTransport) Close() error {
+	return nil
+}
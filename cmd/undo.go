@@ -1,27 +1,48 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 )
 
+var undoCountF int
+
 // undoCmd represents the undo command
 var undoCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
-	Use:   "undo",
+	Use:   "undo [-n <count>]",
 	Short: "Undo the last command",
-	Long: `Return to the version before the last command was executed.
-Only commands that changes information counts.
-Number of last versions saved can be modified using config file.`,
+	Long: `Return the project to the way it was before the last command that changed it,
+including the files, the index, the branches and the current version.
+With -n, the last given number of commands are undone.
+Undone commands can be redone until another command changes the project.
+How long commands can be undone for is set by the checkpoints retention in the config file.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		p, err := LoadProject()
+		return undoCommands(undoCountF)
+	},
+}
+
+func undoCommands(n int) error {
+	p, err := LoadProject()
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(p, "", func() error {
+		names, err := p.Undo(n)
 		if err != nil {
 			return err
 		}
 
-		return p.Undo()
-	},
+		for _, name := range names {
+			fmt.Println("Undone:", name)
+		}
+		return nil
+	})
 }
 
 func init() {
+	undoCmd.Flags().IntVarP(&undoCountF, "count", "n", 1, "the number of commands to undo")
 	rootCmd.AddCommand(undoCmd)
 }
@@ -7,33 +7,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"gitlab.com/magsh-2019/2/gud/gud"
 )
 
-func getAllFiles() ([]string, error) {
-
-	root, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-	var files []string
-	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		// Checking if not adding .gud directory
-		if !strings.Contains(path, ".gud") && !info.IsDir() {
-			files = append(files, path)
-		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-	return files, nil
-}
-
 func LoadProject() (*gud.Project, error) {
 	wd, err := os.Getwd()
 	if err != nil {
@@ -84,4 +62,41 @@ func checkUrl(url *string) {
 	if strings.Contains(*url,  "http") {
 		*url = "https://" + *url
 	}
-}
\ No newline at end of file
+}
+
+// withTransaction runs a command that changes the project in a transaction. If name is not empty, a checkpoint
+// is taken first, so the command can be undone, and the project is rolled back to it if the command fails.
+// A failed command aborts the transaction, unless it stopped at conflicts that are left to be resolved.
+func withTransaction(p *gud.Project, name string, command func() error) (err error) {
+	err = p.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && !isConflict(err) {
+			_ = p.Abort()
+			return
+		}
+		cerr := p.Commit()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	if name != "" {
+		err = p.Checkpoint(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = command()
+	if err != nil && name != "" && !isConflict(err) {
+		_ = p.Rollback()
+	}
+	return err
+}
+
+func isConflict(err error) bool {
+	return err == gud.ErrMergeConflict || err == gud.ErrRebaseConflict || err == gud.ErrPickConflict
+}
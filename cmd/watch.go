@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Args:  cobra.NoArgs,
+	Use:   "watch",
+	Short: "Watch the project for changes, to answer status quickly and save checkpoints",
+	Long: `Watch the working tree of the project until interrupted.
+While watching, status only compares the files that changed, and a checkpoint is saved
+once no file changed for the quiet period of the configuration`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := LoadProject()
+		if err != nil {
+			return err
+		}
+
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		fmt.Println("Watching", p.Path)
+		return p.Watch(stop, func(err error) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,213 @@
+package gud
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const AttributesFileName = ".gudattributes"
+
+// binaryCheckSize is how much of a file is searched for a null byte to tell whether it is binary
+const binaryCheckSize = 8000
+
+type textMode int
+
+const (
+	textUnset textMode = iota // stored as it is, unless an eol is set
+	textAuto                  // text=auto: normalized unless it looks binary
+	textOn                    // text: always normalized
+	textOff                   // -text or binary: never normalized
+)
+
+// attributes tell how the contents of a file are converted between the working tree and the blobs.
+// Text files are saved with LF line endings, and written to the working tree with the line endings of eol.
+type attributes struct {
+	text   textMode
+	eol    string // "lf", "crlf" or "" for LF
+	noDiff bool   // -diff or binary: diffs show only that the file changed
+}
+
+type attributeRule struct {
+	pattern IgnoreRule
+	attrs   []string
+}
+
+// attributer finds the attributes of files.
+// Every directory may have an attributes file, with patterns relative to it that take precedence
+// over the ones of its parents, like ignore files. A nil attributer gives every file no attributes.
+// It may be used by several goroutines at once.
+type attributer struct {
+	root string
+	lock sync.Mutex
+	dirs map[string][]attributeRule // the rules of each directory, read when first needed
+}
+
+func (p Project) loadAttributer() *attributer {
+	return &attributer{
+		root: p.Path,
+		dirs: make(map[string][]attributeRule),
+	}
+}
+
+// lookup returns the attributes of a file.
+func (at *attributer) lookup(relPath string) (attributes, error) {
+	var res attributes
+	if at == nil {
+		return res, nil
+	}
+
+	// The attributes files from the root of the project down to the directory of the file
+	relPath = filepath.ToSlash(relPath)
+	dirs := []string{"."}
+	for i, c := range relPath {
+		if c == '/' {
+			dirs = append(dirs, relPath[:i])
+		}
+	}
+
+	for _, dir := range dirs {
+		rules, err := at.rules(dir)
+		if err != nil {
+			return res, err
+		}
+		for _, rule := range rules {
+			if rule.pattern.matches(relPath, false) {
+				res.set(rule.attrs)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (at *attributer) rules(dir string) ([]attributeRule, error) {
+	at.lock.Lock()
+	defer at.lock.Unlock()
+
+	rules, ok := at.dirs[dir]
+	if ok {
+		return rules, nil
+	}
+
+	base := dir
+	if base == "." {
+		base = ""
+	}
+
+	rules, err := readAttributesFile(filepath.Join(at.root, filepath.FromSlash(dir), AttributesFileName), base)
+	if err != nil {
+		return nil, err
+	}
+
+	at.dirs[dir] = rules
+	return rules, nil
+}
+
+// readAttributesFile reads the rules of an attributes file, which match paths relative to base.
+// Each line is a pattern followed by attributes. A missing file has no rules.
+func readAttributesFile(path, base string) ([]attributeRule, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []attributeRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0][0] == '#' {
+			continue
+		}
+
+		pattern, ok := parseIgnoreRule(fields[0])
+		if !ok || pattern.negate || pattern.dirOnly { // attributes belong to files
+			continue
+		}
+		pattern.base = base
+		rules = append(rules, attributeRule{pattern: pattern, attrs: fields[1:]})
+	}
+
+	return rules, scanner.Err()
+}
+
+// set applies attributes as they are written in a rule. Unknown attributes are skipped.
+func (a *attributes) set(attrs []string) {
+	for _, attr := range attrs {
+		switch attr {
+		case "text":
+			a.text = textOn
+		case "text=auto":
+			a.text = textAuto
+		case "-text":
+			a.text = textOff
+		case "binary":
+			a.text = textOff
+			a.noDiff = true
+		case "diff":
+			a.noDiff = false
+		case "-diff":
+			a.noDiff = true
+		case "eol=lf", "eol=crlf":
+			a.eol = strings.TrimPrefix(attr, "eol=")
+		}
+	}
+}
+
+// converts returns true if the contents of the file might be converted,
+// which has to be decided by looking at them.
+func (a attributes) converts() bool {
+	return a.text != textOff && (a.text != textUnset || a.eol != "")
+}
+
+func (a attributes) isText(content []byte) bool {
+	return a.converts() && (a.text != textAuto || !looksBinary(content))
+}
+
+// toBlob returns the contents of a working file as they are saved.
+func (a attributes) toBlob(content []byte) []byte {
+	if !a.isText(content) {
+		return content
+	}
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+}
+
+// toWorkTree returns the contents of a blob as they are written to the working tree.
+func (a attributes) toWorkTree(content []byte) []byte {
+	if !a.isText(content) || a.eol != "crlf" {
+		return content
+	}
+	return bytes.ReplaceAll(a.toBlob(content), []byte("\n"), []byte("\r\n"))
+}
+
+// binaryDiff returns true if the changes from old to new should not be shown line by line.
+func (a attributes) binaryDiff(old, new string) bool {
+	if a.noDiff {
+		return true
+	}
+	return a.text != textOn && (looksBinary([]byte(old)) || looksBinary([]byte(new)))
+}
+
+// markBinary sets whether the changes to a file should not be shown line by line.
+func (at *attributer) markBinary(diff *FileDiff) error {
+	attrs, err := at.lookup(diff.Path)
+	if err != nil {
+		return err
+	}
+	diff.Binary = attrs.binaryDiff(diff.Old, diff.New)
+	return nil
+}
+
+func looksBinary(content []byte) bool {
+	if len(content) > binaryCheckSize {
+		content = content[:binaryCheckSize]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
@@ -0,0 +1,57 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_Attributes(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	textPath := filepath.Join(testDir, "file.txt")
+	_ = ioutil.WriteFile(filepath.Join(testDir, AttributesFileName), []byte("*.txt text eol=crlf\n*.bin binary\n"), 0644)
+	_ = ioutil.WriteFile(textPath, []byte("a\r\nb\r\n"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("crlf")
+	first, _ := p.CurrentHash()
+
+	obj, err := p.findObject("file.txt", *first)
+	if err != nil || obj == nil {
+		t.Fatal("the file was not saved:", err)
+	}
+	saved, _ := readBlob(p.gudPath, obj.Hash)
+	if saved != "a\nb\n" {
+		t.Errorf("the file was saved as %q", saved)
+	}
+
+	status, err := p.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Files) != 0 {
+		t.Error("the converted file is not clean:", status.Files)
+	}
+
+	_ = ioutil.WriteFile(textPath, []byte("c\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(testDir, "file.bin"), []byte("a\x00b"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("lf")
+
+	err = p.Checkout(*first)
+	if err != nil {
+		t.Fatal("failed to checkout:", err)
+	}
+	data, _ := ioutil.ReadFile(textPath)
+	if string(data) != "a\r\nb\r\n" {
+		t.Errorf("the file was checked out as %q", data)
+	}
+
+	_ = p.CheckoutBranch(FirstBranchName)
+	_ = ioutil.WriteFile(filepath.Join(testDir, "file.bin"), []byte("c\x00d"), 0644)
+	diffs, _ := p.Diff()
+	if len(diffs) != 1 || !diffs[0].Binary {
+		t.Error("the binary file was not diffed as binary:", diffs)
+	}
+}
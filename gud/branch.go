@@ -1,10 +1,11 @@
 package gud
 
 import (
-	"container/list"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -55,15 +56,43 @@ func (p Project) CreateBranch(name string) error {
 }
 
 func (p Project) GetBranch(name string) (*ObjectHash, error) {
-	_, err := os.Stat(filepath.Join(p.gudPath, branchesPath, name))
-	if os.IsNotExist(err) {
-		return nil, nil
+	return getRef(p.gudPath, filepath.Join(branchesPath, name))
+}
+
+// lookupBranch returns the version a branch or a remote-tracking branch points to, or nil if there is neither.
+// Branches are preferred to remote-tracking branches of the same name.
+func (p Project) lookupBranch(name string) (*ObjectHash, error) {
+	hash, err := p.GetBranch(name)
+	if err != nil || hash != nil {
+		return hash, err
+	}
+
+	return p.lookupRemoteBranch(name)
+}
+
+// Resolve returns the hash of the version rev refers to.
+// rev can be either a version hash, the name of a branch or the name of a remote-tracking branch.
+func (p Project) Resolve(rev string) (*ObjectHash, error) {
+	var hash ObjectHash
+	if len(rev) == hex.EncodedLen(len(hash)) {
+		_, err := hex.Decode(hash[:], []byte(rev))
+		if err == nil {
+			_, err = loadVersion(p.gudPath, hash)
+			if err == nil {
+				return &hash, nil
+			}
+		}
 	}
+
+	branchHash, err := p.lookupBranch(rev)
 	if err != nil {
 		return nil, err
 	}
+	if branchHash == nil {
+		return nil, Error{"unknown version or branch: " + rev}
+	}
 
-	return loadBranch(p.gudPath, name)
+	return branchHash, nil
 }
 
 func (p Project) CheckoutBranch(branch string) error {
@@ -116,21 +145,36 @@ func (p Project) checkoutHash(hash ObjectHash) error {
 	return p.removeChanges(tree, nil)
 }
 
-func (p Project) MergeBranch(from string) (*Version, error) {
-	hash, err := loadBranch(p.gudPath, from)
+// MergeOptions change the way a merge is saved. The zero value fast-forwards when possible,
+// and otherwise saves a merge version with a default message.
+type MergeOptions struct {
+	NoFastForward   bool   // save a merge version even when the branch can be fast-forwarded
+	FastForwardOnly bool   // refuse to merge unless the branch can be fast-forwarded
+	Squash          bool   // save the merged changes as an ordinary version, without linking the merged one
+	Message         string // the message of the saved version, instead of the default one
+}
+
+var ErrNotFastForward = Error{"cannot fast-forward: the branches have diverged"}
+
+// MergeBranch merges a branch or a remote-tracking branch into the current branch.
+func (p Project) MergeBranch(from string, options MergeOptions) (*Version, error) {
+	hash, err := p.lookupBranch(from)
 	if err != nil {
 		return nil, err
 	}
-	return p.merge(*hash, from)
+	if hash == nil {
+		return nil, Error{"unknown branch: " + from}
+	}
+	return p.merge(*hash, from, options)
 }
 
-func (p Project) MergeHash(from ObjectHash) (*Version, error) {
+func (p Project) MergeHash(from ObjectHash, options MergeOptions) (*Version, error) {
 	version, err := loadVersion(p.gudPath, from)
 	if err != nil {
 		return nil, err
 	}
 
-	return p.merge(from, fmt.Sprintf(`"%s"`, version.Message))
+	return p.merge(from, fmt.Sprintf(`"%s"`, version.Message), options)
 }
 
 func (p Project) ListBranches(fn func(branch string) error) error {
@@ -151,7 +195,7 @@ func (p Project) ListBranches(fn func(branch string) error) error {
 	})
 }
 
-func (p Project) merge(from ObjectHash, name string) (*Version, error) {
+func (p Project) merge(from ObjectHash, name string, options MergeOptions) (*Version, error) {
 	err := p.assertNoChanges()
 	if err != nil {
 		return nil, err
@@ -170,124 +214,355 @@ func (p Project) merge(from ObjectHash, name string) (*Version, error) {
 		return nil, err
 	}
 
-	toVersion, err := loadVersion(p.gudPath, *to)
+	res, err := p.mergeVersions(head.Branch, *to, from, name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.conflicts) > 0 {
+		err = p.applyMerge(res.toTree, res.tree, res.conflicts, head.Branch, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// A squashed merge is saved as an ordinary version, that does not link the merged one
+		var merged *ObjectHash
+		if !options.Squash {
+			merged = &from
+		}
+		err = dumpHead(p.gudPath, Head{
+			IsDetached: false,
+			Branch:     head.Branch,
+			MergedHash: merged,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrMergeConflict
+	}
+
+	err = p.removeChanges(res.tree, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.version, nil
+}
+
+// MergeBranchInto merges a branch into another one without touching the index or the working tree.
+// If there are conflicts, nothing is saved and ErrMergeConflict is returned.
+func (p Project) MergeBranchInto(to, from string, options MergeOptions) (*Version, error) {
+	toHash, err := loadBranch(p.gudPath, to)
 	if err != nil {
 		return nil, err
 	}
-	fromVersion, err := loadVersion(p.gudPath, from)
+	fromHash, err := loadBranch(p.gudPath, from)
 	if err != nil {
 		return nil, err
 	}
 
-	oldToNew, err := isDescendent(p.gudPath, *to, from)
+	res, err := p.mergeVersions(to, *toHash, *fromHash, from, options)
 	if err != nil {
 		return nil, err
 	}
-	if oldToNew {
-		return toVersion, nil
+	if len(res.conflicts) > 0 {
+		return nil, ErrMergeConflict
 	}
 
-	newToOld, err := isDescendent(p.gudPath, from, *to)
+	return res.version, nil
+}
+
+// mergeResult is a merge computed in the object store.
+type mergeResult struct {
+	version   *Version // the version the branch was moved to, nil if there are conflicts
+	toTree    tree
+	tree      tree // the merged tree
+	conflicts []mergeConflict
+}
+
+// mergeVersions merges the version from into the branch, which points to the version to.
+// Unless there are conflicts, the result is saved and the branch is moved to it.
+func (p Project) mergeVersions(branch string, to, from ObjectHash, name string, options MergeOptions) (*mergeResult, error) {
+	if options.FastForwardOnly && (options.NoFastForward || options.Squash) {
+		return nil, InputError{"fast-forward only cannot be combined with no fast-forward or squash"}
+	}
+
+	oldToNew, err := isDescendent(p.gudPath, to, from)
 	if err != nil {
 		return nil, err
 	}
-	if newToOld {
-		err = dumpBranch(p.gudPath, head.Branch, from)
+	if oldToNew {
+		toVersion, err := loadVersion(p.gudPath, to)
 		if err != nil {
 			return nil, err
 		}
+		toTree, err := loadTree(p.gudPath, toVersion.TreeHash)
+		if err != nil {
+			return nil, err
+		}
+		return &mergeResult{version: toVersion, toTree: toTree, tree: toTree}, nil
+	}
 
-		tree, err := loadTree(p.gudPath, fromVersion.TreeHash)
+	newToOld, err := isDescendent(p.gudPath, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if newToOld && !options.NoFastForward && !options.Squash {
+		fromVersion, err := loadVersion(p.gudPath, from)
+		if err != nil {
+			return nil, err
+		}
+		fromTree, err := loadTree(p.gudPath, fromVersion.TreeHash)
 		if err != nil {
 			return nil, err
 		}
 
-		err = p.removeChanges(tree, nil)
+		err = dumpBranch(p.gudPath, branch, from)
 		if err != nil {
 			return nil, err
 		}
+		return &mergeResult{version: fromVersion, tree: fromTree}, nil
+	}
+	if !newToOld && options.FastForwardOnly {
+		return nil, ErrNotFastForward
+	}
+
+	res := &mergeResult{}
+	res.tree, res.toTree, res.conflicts, err = mergeTreesOf(p.gudPath, to, from, true)
+	if err != nil || len(res.conflicts) > 0 {
+		return res, err
+	}
 
-		return fromVersion, nil
+	treeObj, err := createTree(p.gudPath, "", res.tree)
+	if err != nil {
+		return nil, err
 	}
 
+	// A squashed merge is saved as an ordinary version, that does not link the merged one
+	merged := &from
+	if options.Squash {
+		merged = nil
+	}
+
+	message := options.Message
+	if message == "" && options.Squash {
+		message = fmt.Sprintf("squashed %s into %s", name, branch)
+	} else if message == "" {
+		message = fmt.Sprintf("merged %s into %s", name, branch)
+	}
+
+	res.version, err = p.saveVersion(message, branch, treeObj.Hash, &to, merged)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// mergeTreesOf merges the trees of two versions, using the tree of their merge base.
+// It returns the merged tree and the tree of to. See mergeTrees for dump.
+func mergeTreesOf(gudPath string, to, from ObjectHash, dump bool,
+) (merged, toTree tree, conflicts []mergeConflict, err error) {
+	base, err := mergeBase(gudPath, to, from)
+	if err != nil {
+		return
+	}
+
+	var trees [3]tree
+	for i, hash := range []ObjectHash{to, from, *base} {
+		version, err := loadVersion(gudPath, hash)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		trees[i], err = loadTree(gudPath, version.TreeHash)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	merged, conflicts, err = mergeTrees(gudPath, "", trees[0], trees[1], trees[2], dump)
+	return merged, trees[0], conflicts, err
+}
+
+// mergeBase returns the latest version in the history of from that to descends from.
+func mergeBase(gudPath string, to, from ObjectHash) (*ObjectHash, error) {
 	base := from
-	foundBase := false
-	for !foundBase {
-		baseVersion, err := loadVersion(p.gudPath, base)
+	for {
+		found, err := isDescendent(gudPath, to, base)
 		if err != nil {
 			return nil, err
 		}
-		if !baseVersion.HasPrev() {
-			panic("???")
+		if found {
+			return &base, nil
 		}
 
-		base = *baseVersion.prev
-		foundBase, err = isDescendent(p.gudPath, *to, base)
+		baseVersion, err := loadVersion(gudPath, base)
 		if err != nil {
 			return nil, err
 		}
+		if !baseVersion.HasPrev() {
+			return nil, Error{"the versions have no common history"}
+		}
+		base = *baseVersion.prev
 	}
+}
 
-	baseVersion, err := loadVersion(p.gudPath, base)
+// applyMerge writes the result of a merge with conflicts to the working tree.
+// The merged changes are staged, and the conflicting files are marked in the index
+// until they are resolved and added.
+func (p Project) applyMerge(current, merged tree, conflicts []mergeConflict, toName, fromName string) error {
+	err := p.removeChanges(merged, nil)
+	if err != nil {
+		return err
+	}
+
+	sparse, err := loadSparse(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	index := make([]indexEntry, 0, len(conflicts))
+	err = diffTrees(p.gudPath, "", current, merged, func(relPath string, state FileState, obj object) error {
+		entry := indexEntry{Path: relPath, State: state, Shared: true}
+		if state != StateRemoved {
+			entry.Hash = obj.Hash
+		}
+		if state != StateRemoved && sparse.includes(relPath) { // files outside the sparse set are not written
+			info, err := os.Stat(filepath.Join(p.Path, relPath))
+			if err != nil {
+				return err
+			}
+			entry.Mtime = info.ModTime()
+			entry.Size = info.Size()
+		}
+
+		index = setIndexEntry(index, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, conflict := range conflicts {
+		if !sparse.includes(conflict.Path) { // written anyway, to be resolved
+			err = os.MkdirAll(filepath.Join(p.Path, filepath.Dir(conflict.Path)), dirPerm)
+			if err != nil {
+				return err
+			}
+		}
+
+		if conflict.To != nil && conflict.From != nil {
+			err = p.writeConflict(conflict.Path, conflict.To.Hash, conflict.From.Hash, toName, fromName)
+		} else if conflict.From != nil { // removed in target, keep the changed file for resolving
+			err = p.extractBlob(conflict.Path, conflict.From.Hash, p.loadAttributer())
+		}
+		if err != nil {
+			return err
+		}
+
+		index = setIndexEntry(index, indexEntry{
+			Path:   conflict.Path,
+			State:  StateConflict,
+			Base:   objectHash(conflict.Base),
+			Ours:   objectHash(conflict.To),
+			Theirs: objectHash(conflict.From),
+		})
+	}
+
+	return dumpIndex(p.gudPath, index)
+}
+
+// applyVersion merges the changes the version hash made relative to its predecessor into the version onto,
+// and writes the result to the working tree.
+// It returns the hash of the merged tree, or nil if the changes are already in onto.
+// On conflicts, they are written to the working tree and ErrMergeConflict is returned.
+func (p Project) applyVersion(onto, hash ObjectHash, ontoName string) (*ObjectHash, error) {
+	applied, err := loadVersion(p.gudPath, hash)
 	if err != nil {
 		return nil, err
 	}
-	toTree, err := loadTree(p.gudPath, toVersion.TreeHash)
+
+	var base tree
+	if applied.HasPrev() {
+		prev, err := loadVersion(p.gudPath, *applied.prev)
+		if err != nil {
+			return nil, err
+		}
+		base, err = loadTree(p.gudPath, prev.TreeHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ontoVersion, err := loadVersion(p.gudPath, onto)
 	if err != nil {
 		return nil, err
 	}
-	fromTree, err := loadTree(p.gudPath, fromVersion.TreeHash)
+	ours, err := loadTree(p.gudPath, ontoVersion.TreeHash)
 	if err != nil {
 		return nil, err
 	}
-	baseTree, err := loadTree(p.gudPath, baseVersion.TreeHash)
+	theirs, err := loadTree(p.gudPath, applied.TreeHash)
 	if err != nil {
 		return nil, err
 	}
 
-	tree, conflicts, err := p.mergeTrees(".", toTree, fromTree, head.Branch, name, baseTree)
+	merged, conflicts, err := mergeTrees(p.gudPath, "", ours, theirs, base, true)
 	if err != nil {
 		return nil, err
 	}
 
-	if conflicts != nil {
-		index := make([]indexEntry, 0, conflicts.Len())
-		for p := conflicts.Front(); p != nil; p = p.Next() {
-			index = append(index, indexEntry{
-				Path:  p.Value.(string),
-				State: StateConflict,
-			})
-		}
-
-		err = dumpHead(p.gudPath, Head{
-			IsDetached: false,
-			Branch:     head.Branch,
-			MergedHash: &from,
-		})
+	if len(conflicts) > 0 {
+		err = p.applyMerge(ours, merged, conflicts, ontoName, hash.String())
 		if err != nil {
 			return nil, err
 		}
 		return nil, ErrMergeConflict
 	}
 
-	treeObj, err := createTree(p.gudPath, ".", tree)
+	treeObj, err := createTree(p.gudPath, "", merged)
 	if err != nil {
 		return nil, err
 	}
+	if treeObj.Hash == ontoVersion.TreeHash {
+		return nil, nil
+	}
 
-	version, err := p.saveVersion(
-		fmt.Sprintf("merged %s into %s", name, head.Branch),
-		head.Branch, treeObj.Hash, to, &from)
+	err = p.removeChanges(merged, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.removeChanges(tree, nil)
+	return &treeObj.Hash, nil
+}
+
+// discardChanges empties the index and returns the working tree to the state of a version.
+func (p Project) discardChanges(hash ObjectHash) error {
+	index, err := loadIndex(p.gudPath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	for _, entry := range index {
+		err = removeEntry(p.gudPath, entry)
+		if err != nil {
+			return err
+		}
+	}
+	err = initIndex(p.gudPath)
+	if err != nil {
+		return err
 	}
 
-	return version, nil
+	version, err := loadVersion(p.gudPath, hash)
+	if err != nil {
+		return err
+	}
+	tree, err := loadTree(p.gudPath, version.TreeHash)
+	if err != nil {
+		return err
+	}
+
+	return p.removeChanges(tree, nil)
 }
 
 func (p Project) Reset() error {
@@ -309,22 +584,47 @@ func (p Project) Reset() error {
 	return p.removeChanges(tree, index)
 }
 
+// removeChanges returns the working tree to the state of a tree, except for the changes staged in index.
+// Ignored files are kept, and so are the new directories that hold them.
+// Paths outside the sparse set are not touched.
 func (p Project) removeChanges(tree tree, index []indexEntry) error {
-	return p.compareTree(".", tree, index,
+	s, err := p.loadScan()
+	if err != nil {
+		return err
+	}
+
+	var newDirs []string
+	err = p.compareTree(".", tree, index, s,
 		func(relPath string, state FileState, hash *ObjectHash, isDir bool) error {
 			path := filepath.Join(p.Path, relPath)
-			if isDir && state == StateNew {
-				return os.Remove(path)
+			if isDir && state == StateNew { // removed once its files are
+				newDirs = append(newDirs, path)
+				return nil
 			}
 			if isDir {
-				return os.Mkdir(path, dirPerm)
+				return os.MkdirAll(path, dirPerm)
 			}
 			if state == StateNew {
 				return os.Remove(path)
 			}
-			return p.extractBlob(relPath, *hash)
+			return p.extractBlob(relPath, *hash, s.attributer())
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	for i := len(newDirs) - 1; i >= 0; i-- {
+		err = os.Remove(newDirs[i])
+		if err != nil {
+			left, readErr := ioutil.ReadDir(newDirs[i])
+			if readErr != nil || len(left) == 0 {
+				return err
+			}
+		}
+	}
+
+	return p.dumpScan(s)
 }
 
 func getCurrentHash(gudPath string, head Head) (*ObjectHash, error) {
@@ -334,6 +634,41 @@ func getCurrentHash(gudPath string, head Head) (*ObjectHash, error) {
 	return loadBranch(gudPath, head.Branch)
 }
 
+// IsAncestor returns true if the version hash descends from the version ancestor.
+func (p Project) IsAncestor(ancestor, hash ObjectHash) (bool, error) {
+	versions := make(map[ObjectHash]bool)
+	err := markAncestors(p.gudPath, hash, versions)
+	if err != nil {
+		return false, err
+	}
+
+	return versions[ancestor], nil
+}
+
+// markAncestors marks the version hash and every version it descends from, including merged ones.
+func markAncestors(gudPath string, hash ObjectHash, versions map[ObjectHash]bool) error {
+	for !versions[hash] {
+		versions[hash] = true
+
+		version, err := loadVersion(gudPath, hash)
+		if err != nil {
+			return err
+		}
+		if version.IsMergeVersion() {
+			err = markAncestors(gudPath, *version.merged, versions)
+			if err != nil {
+				return err
+			}
+		}
+		if !version.HasPrev() {
+			break
+		}
+		hash = *version.prev
+	}
+
+	return nil
+}
+
 func isDescendent(gudPath string, new, old ObjectHash) (bool, error) {
 	for new != old {
 		version, err := loadVersion(gudPath, new)
@@ -350,7 +685,7 @@ func isDescendent(gudPath string, new, old ObjectHash) (bool, error) {
 }
 
 func (p Project) assertNoChanges() error {
-	return p.Status(
+	return p.walkStatus(
 		func(relPath string, state FileState) error {
 			return ErrUnstagedChanges
 		},
@@ -360,120 +695,122 @@ func (p Project) assertNoChanges() error {
 	)
 }
 
-func (p Project) mergeTrees(
-	relPath string, to, from tree, toName, fromName string, base tree) (tree, *list.List, error) {
-	res := make(tree, len(to), len(to)+len(from))
-	conflicts := list.New()
-	copy(res, to)
+type mergeConflict struct {
+	Path           string
+	To, From, Base *object // nil if the file does not exist in that version
+}
+
+// mergeTrees merges the changes made from base to from into to.
+// Conflicting files keep their object from to in the merged tree, and are returned separately.
+// The merged subtrees are only written to the object store if dump is set, otherwise they are only hashed.
+func mergeTrees(gudPath, relPath string, to, from, base tree, dump bool) (tree, []mergeConflict, error) {
+	res := make(tree, 0, len(to)+len(from))
+	var conflicts []mergeConflict
 
 	toInd := 0
 	fromInd := 0
-	for toInd < len(to) && fromInd < len(from) {
-		toObj := to[toInd]
-		fromObj := from[fromInd]
-
-		if toObj.Name < fromObj.Name { // new object in target
-			toInd++
-		} else if fromObj.Name < toObj.Name { // new object in merged
-			res = append(res, object{})
-			copy(res[fromInd+1:], res[fromInd:])
-			res[fromInd] = fromObj
-
-			fromInd++
-		} else {
-			if toObj.Hash != fromObj.Hash {
-				baseInd, found := searchTree(base, toObj.Name)
-				if found {
-					baseObj := base[baseInd]
-					if toObj.Hash != baseObj.Hash && fromObj.Hash != baseObj.Hash { // conflicting changes
-						mergedObj, newConflicts, err := p.mergeDiff(relPath, toObj, fromObj, toName, fromName, &baseObj)
-						if err != nil {
-							return nil, nil, err
-						}
-						if newConflicts != nil {
-							conflicts.PushFrontList(newConflicts)
-						} else {
-							res[toInd] = *mergedObj
-						}
-
-					} else if fromObj.Hash != baseObj.Hash { // change in merged
-						res[toInd] = fromObj
-					}
-				} else { // conflicting changes
-					mergedObj, newConflicts, err := p.mergeDiff(relPath, toObj, fromObj, toName, fromName, nil)
+	baseInd := 0
+	for toInd < len(to) || fromInd < len(from) || baseInd < len(base) {
+		name := ""
+		for _, next := range []struct {
+			tree tree
+			ind  int
+		}{{to, toInd}, {from, fromInd}, {base, baseInd}} {
+			if next.ind < len(next.tree) && (name == "" || next.tree[next.ind].Name < name) {
+				name = next.tree[next.ind].Name
+			}
+		}
+
+		toObj := nextObject(to, &toInd, name)
+		fromObj := nextObject(from, &fromInd, name)
+		baseObj := nextObject(base, &baseInd, name)
+		childPath := filepath.Join(relPath, name)
+
+		switch {
+		case sameObject(fromObj, baseObj): // unchanged in merged
+			res = appendObject(res, toObj)
+
+		case sameObject(toObj, baseObj): // changed only in merged
+			res = appendObject(res, fromObj)
+
+		case sameObject(toObj, fromObj): // same change in both
+			res = appendObject(res, toObj)
+
+		case isTreeOrNil(toObj) && isTreeOrNil(fromObj): // directory changed in both
+			var trees [3]tree
+			for i, obj := range []*object{toObj, fromObj, baseObj} {
+				if obj != nil && obj.Type == typeTree {
+					var err error
+					trees[i], err = loadTree(gudPath, obj.Hash)
 					if err != nil {
 						return nil, nil, err
 					}
-					if newConflicts != nil {
-						conflicts.PushFrontList(newConflicts)
-					} else {
-						res[toInd] = *mergedObj
-					}
 				}
 			}
 
-			toInd++
-			fromInd++
+			inner, innerConflicts, err := mergeTrees(gudPath, childPath, trees[0], trees[1], trees[2], dump)
+			if err != nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, innerConflicts...)
+
+			if len(inner) > 0 {
+				var obj *object
+				if dump {
+					obj, err = createTree(gudPath, childPath, inner)
+				} else {
+					obj, err = hashTree(childPath, inner)
+				}
+				if err != nil {
+					return nil, nil, err
+				}
+				res = append(res, *obj)
+			}
+
+		case toObj != nil && fromObj != nil && toObj.Type != fromObj.Type:
+			return nil, nil, Error{"cannot merge directory and file: " + childPath}
+
+		default: // conflicting changes
+			conflicts = append(conflicts, mergeConflict{Path: childPath, To: toObj, From: fromObj, Base: baseObj})
+			res = appendObject(res, toObj)
 		}
 	}
-	for ; fromInd < len(from); fromInd++ {
-		res = append(res, from[fromInd])
-	}
 
-	if conflicts.Len() > 0 {
-		return nil, conflicts, nil
-	}
-	return res, nil, nil
+	return res, conflicts, nil
 }
 
-func (p Project) mergeDiff(
-	parentPath string,
-	to, from object,
-	toName, fromName string,
-	base *object) (*object, *list.List, error) {
-	relPath := filepath.Join(parentPath, to.Name)
-
-	if to.Type != from.Type {
-		return nil, nil, Error{"cannot merge directory and file: " + relPath}
+func objectHash(obj *object) *ObjectHash {
+	if obj == nil {
+		return nil
 	}
-	if to.Type == typeBlob {
-		err := p.writeConflict(relPath, to.Hash, from.Hash, toName, fromName)
-		if err != nil {
-			return nil, nil, err
-		}
+	return &obj.Hash
+}
 
-		conflicts := list.New()
-		conflicts.PushFront(relPath)
-		return nil, conflicts, nil
+func nextObject(tree tree, ind *int, name string) *object {
+	if *ind < len(tree) && tree[*ind].Name == name {
+		obj := &tree[*ind]
+		*ind++
+		return obj
 	}
+	return nil
+}
 
-	toTree, err := loadTree(p.gudPath, to.Hash)
-	if err != nil {
-		return nil, nil, err
-	}
-	fromTree, err := loadTree(p.gudPath, from.Hash)
-	if err != nil {
-		return nil, nil, err
-	}
-	var baseTree tree
-	if base != nil && base.Type == typeTree {
-		baseTree, err = loadTree(p.gudPath, base.Hash)
+func sameObject(a, b *object) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
 	}
+	return a.Type == b.Type && a.Hash == b.Hash
+}
 
-	newTree, conflicts, err := p.mergeTrees(relPath, toTree, fromTree, toName, fromName, baseTree)
-	if err != nil {
-		return nil, nil, err
-	}
-	if conflicts != nil {
-		return nil, conflicts, nil
-	}
+func isTreeOrNil(obj *object) bool {
+	return obj == nil || obj.Type == typeTree
+}
 
-	newObj, err := createTree(p.gudPath, relPath, newTree)
-	if err != nil {
-		return nil, nil, err
+func appendObject(tree tree, obj *object) tree {
+	if obj == nil {
+		return tree
 	}
-
-	return newObj, nil, nil
+	return append(tree, *obj)
 }
 
 func (p Project) writeConflict(
@@ -528,26 +865,44 @@ func initBranches(gudPath string) error {
 	return os.Mkdir(filepath.Join(gudPath, branchesPath), dirPerm)
 }
 
-func dumpBranch(gudPath string, name string, hash ObjectHash) (err error) {
-	file, err := os.Create(filepath.Join(gudPath, branchesPath, name))
+func dumpBranch(gudPath string, name string, hash ObjectHash) error {
+	return dumpRef(gudPath, filepath.Join(branchesPath, name), hash)
+}
+
+func loadBranch(gudPath, name string) (*ObjectHash, error) {
+	return loadRef(gudPath, filepath.Join(branchesPath, name))
+}
+
+// getRef returns the version a reference points to, or nil if it does not exist.
+// References are branches and remote-tracking branches, whose paths are relative to the gud directory.
+func getRef(gudPath, ref string) (*ObjectHash, error) {
+	_, err := os.Stat(filepath.Join(gudPath, ref))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer func() {
-		cerr := file.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
 
-	_, err = file.Write(hash[:])
-	return
+	return loadRef(gudPath, ref)
 }
 
-func loadBranch(gudPath, name string) (*ObjectHash, error) {
+func dumpRef(gudPath, ref string, hash ObjectHash) error {
+	err := os.MkdirAll(filepath.Join(gudPath, filepath.Dir(ref)), dirPerm)
+	if err != nil {
+		return err
+	}
+
+	return writeMetadata(gudPath, ref, func(w io.Writer) error {
+		_, err := w.Write(hash[:])
+		return err
+	})
+}
+
+func loadRef(gudPath, ref string) (*ObjectHash, error) {
 	var hash ObjectHash
 
-	file, err := os.Open(filepath.Join(gudPath, branchesPath, name))
+	file, err := os.Open(filepath.Join(gudPath, ref))
 	if err != nil {
 		return nil, err
 	}
@@ -564,19 +919,10 @@ func loadBranch(gudPath, name string) (*ObjectHash, error) {
 	return &hash, nil
 }
 
-func dumpHead(gudPath string, head Head) (err error) {
-	file, err := os.Create(filepath.Join(gudPath, headFileName))
-	if err != nil {
-		return err
-	}
-	defer func() {
-		cerr := file.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-
-	return gob.NewEncoder(file).Encode(head)
+func dumpHead(gudPath string, head Head) error {
+	return writeMetadata(gudPath, headFileName, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(head)
+	})
 }
 
 func loadHead(gudPath string) (*Head, error) {
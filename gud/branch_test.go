@@ -0,0 +1,124 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_MergeBranch(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	featurePath := filepath.Join(testDir, "feature")
+	otherPath := filepath.Join(testDir, "other")
+
+	_ = p.CreateBranch("feature")
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(featurePath, []byte("feature"), 0644)
+	_ = p.Add(featurePath)
+	_, _ = p.Save("feature change")
+	featureHash, _ := p.CurrentHash()
+	_ = p.CheckoutBranch(FirstBranchName)
+	masterHash, _ := p.CurrentHash()
+
+	version, err := p.MergeBranch("feature", MergeOptions{NoFastForward: true, Message: "custom"})
+	if err != nil {
+		t.Fatal("failed to merge:", err)
+	}
+	if !version.IsMergeVersion() || *version.merged != *featureHash || version.Message != "custom" {
+		t.Error("no fast-forward did not save a merge version")
+	}
+
+	_ = p.resetTo(FirstBranchName, *masterHash)
+	version, err = p.MergeBranch("feature", MergeOptions{Squash: true})
+	if err != nil {
+		t.Fatal("failed to squash:", err)
+	}
+	if version.IsMergeVersion() || *version.prev != *masterHash {
+		t.Error("squash saved a merge version")
+	}
+	if data, _ := ioutil.ReadFile(featurePath); string(data) != "feature" {
+		t.Error("squashed changes were not applied")
+	}
+
+	_ = ioutil.WriteFile(otherPath, []byte("other"), 0644)
+	_ = p.Add(otherPath)
+	_, _ = p.Save("other change")
+	_ = p.CheckoutBranch("feature")
+	_, err = p.MergeBranch(FirstBranchName, MergeOptions{FastForwardOnly: true})
+	if err != ErrNotFastForward {
+		t.Error("fast-forward only merged diverged branches:", err)
+	}
+	if current, _ := p.CurrentHash(); *current != *featureHash {
+		t.Error("branch moved after a refused merge")
+	}
+}
+
+func TestProject_PreviewMerge(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("first\nsecond\nthird\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("base")
+
+	_ = p.CreateBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("first\nmaster\nthird\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("master change")
+	masterHash, _ := p.CurrentHash()
+
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("first\nfeature\nthird\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("feature change")
+	featureHash, _ := p.CurrentHash()
+
+	preview, err := p.PreviewMerge(*masterHash, *featureHash)
+	if err != nil {
+		t.Fatal("failed to preview:", err)
+	}
+	if preview.TreeHash != nil || len(preview.Conflicts) != 1 || preview.Conflicts[0].Path != testFile {
+		t.Fatal("wrong conflicts:", preview.Conflicts)
+	}
+	hunks := preview.Conflicts[0].Hunks
+	if len(hunks) != 1 || hunks[0].Line != 2 || hunks[0].To != "master\n" || hunks[0].From != "feature\n" {
+		t.Error("wrong hunks:", hunks)
+	}
+
+	data, _ := ioutil.ReadFile(testPath)
+	if string(data) != "first\nfeature\nthird\n" {
+		t.Error("preview changed the working tree")
+	}
+	if current, _ := p.CurrentHash(); *current != *featureHash {
+		t.Error("preview moved the branch")
+	}
+
+	_ = p.CreateBranch("other")
+	_ = p.CheckoutBranch("other")
+	otherPath := filepath.Join(testDir, "other")
+	_ = ioutil.WriteFile(otherPath, []byte("other"), 0644)
+	_ = p.Add(otherPath)
+	_, _ = p.Save("other change")
+	otherHash, _ := p.CurrentHash()
+
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("first\nfeature\nthird\nfourth\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("another feature change")
+	featureHash, _ = p.CurrentHash()
+
+	preview, err = p.PreviewMerge(*featureHash, *otherHash)
+	if err != nil {
+		t.Fatal("failed to preview:", err)
+	}
+	if preview.TreeHash == nil || len(preview.Conflicts) != 0 {
+		t.Fatal("clean merge reported conflicts:", preview.Conflicts)
+	}
+	if _, err = os.Stat(objectPath(p.gudPath, *preview.TreeHash)); !os.IsNotExist(err) {
+		t.Error("preview wrote the merged tree to the object store")
+	}
+}
@@ -0,0 +1,149 @@
+package gud
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// CheckpointInfo describes a checkpoint, with the files of the working tree that changed since the one before it.
+type CheckpointInfo struct {
+	Hash    ObjectHash
+	Message string
+	Time    time.Time
+	Changes []Change
+}
+
+// Checkpoints returns the checkpoints of the project, from the newest to the oldest.
+func (p Project) Checkpoints() ([]CheckpointInfo, error) {
+	inner := p.innerProject()
+	head, err := inner.CurrentHash()
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []CheckpointInfo
+	for hash := head; hash != nil; {
+		version, err := loadVersion(inner.gudPath, *hash)
+		if err != nil {
+			return nil, err
+		}
+		root, err := loadTree(inner.gudPath, version.TreeHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var prev tree
+		var prevHash *ObjectHash
+		if version.HasPrev() {
+			var prevVersion *Version
+			prevHash, prevVersion, err = inner.Prev(*version)
+			if err != nil {
+				return nil, err
+			}
+			prev, err = loadTree(inner.gudPath, prevVersion.TreeHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		info := CheckpointInfo{Hash: *hash, Message: version.Message, Time: version.Time}
+		err = diffTrees(inner.gudPath, ".", prev, root, func(relPath string, state FileState, _ object) error {
+			if !underPath(relPath, DefaultPath) {
+				info.Changes = append(info.Changes, Change{Path: relPath, State: state})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		checkpoints = append(checkpoints, info)
+		hash = prevHash
+	}
+
+	return checkpoints, nil
+}
+
+// ResolveCheckpoint returns the hash of the checkpoint id refers to, which is its hash or a unique prefix of it.
+func (p Project) ResolveCheckpoint(id string) (*ObjectHash, error) {
+	checkpoints, err := p.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *ObjectHash
+	for i := range checkpoints {
+		if id == "" || !strings.HasPrefix(hex.EncodeToString(checkpoints[i].Hash[:]), strings.ToLower(id)) {
+			continue
+		}
+		if found != nil {
+			return nil, Error{"ambiguous checkpoint: " + id}
+		}
+		found = &checkpoints[i].Hash
+	}
+	if found == nil {
+		return nil, Error{"unknown checkpoint: " + id}
+	}
+
+	return found, nil
+}
+
+// DiffCheckpoint returns the changes in the working tree since a checkpoint.
+// The metadata the checkpoint kept is not compared.
+func (p Project) DiffCheckpoint(hash ObjectHash) ([]FileDiff, error) {
+	inner := p.innerProject()
+	version, err := loadVersion(inner.gudPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	root, err := loadTree(inner.gudPath, version.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	files := root[:0:0]
+	for _, obj := range root {
+		if obj.Name != DefaultPath {
+			files = append(files, obj)
+		}
+	}
+
+	return inner.diffWorkTree(files, nil)
+}
+
+// RestoreCheckpoint returns files and directories of the working tree to the way they were in a checkpoint,
+// or the whole working tree if no paths are given.
+// Only the files the checkpoint has are written; the index and the saved versions are not touched.
+func (p Project) RestoreCheckpoint(hash ObjectHash, paths ...string) error {
+	inner := p.innerProject()
+	if len(paths) == 0 {
+		paths = []string{p.Path}
+	}
+
+	source := make(map[string]object)
+	for _, path := range paths {
+		relPath, err := p.relPath(path)
+		if err != nil {
+			return err
+		}
+		if underPath(relPath, DefaultPath) {
+			return Error{"the metadata of the project cannot be restored: " + path}
+		}
+
+		files, err := inner.savedFiles(relPath, hash)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return Error{"the path is not in the checkpoint: " + path}
+		}
+		for file, obj := range files {
+			if !underPath(file, DefaultPath) {
+				source[file] = obj
+			}
+		}
+	}
+
+	return inner.restoreWorkTree(source, nil)
+}
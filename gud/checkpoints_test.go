@@ -0,0 +1,71 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_Checkpoints(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	otherPath := filepath.Join(testDir, "other")
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("first"), 0644)
+	_ = ioutil.WriteFile(otherPath, []byte("other"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("first")
+	_ = p.Checkpoint("save")
+
+	_ = ioutil.WriteFile(testPath, []byte("checkpointed"), 0644)
+	err := p.Checkpoint("command")
+	if err != nil {
+		t.Fatal("failed to checkpoint:", err)
+	}
+
+	checkpoints, err := p.Checkpoints()
+	if err != nil {
+		t.Fatal("failed to list the checkpoints:", err)
+	}
+	if len(checkpoints) == 0 || checkpoints[0].Message != "command" {
+		t.Fatal("the checkpoint was not listed:", checkpoints)
+	}
+	changes := checkpoints[0].Changes
+	if len(changes) != 1 || changes[0].Path != testFile || changes[0].State != StateModified {
+		t.Error("unexpected changes in the checkpoint:", changes)
+	}
+
+	hash, err := p.ResolveCheckpoint(checkpoints[0].Hash.String()[:6])
+	if err != nil || *hash != checkpoints[0].Hash {
+		t.Fatal("failed to resolve the checkpoint:", err)
+	}
+
+	_ = ioutil.WriteFile(testPath, []byte("lost"), 0644)
+	_ = ioutil.WriteFile(otherPath, []byte("kept"), 0644)
+	diffs, err := p.DiffCheckpoint(*hash)
+	if err != nil {
+		t.Fatal("failed to diff the checkpoint:", err)
+	}
+	if len(diffs) != 2 || diffs[0].Path != "other" || diffs[1].Old != "checkpointed" || diffs[1].New != "lost" {
+		t.Error("unexpected diff from the checkpoint:", diffs)
+	}
+
+	index, _ := loadIndex(p.gudPath)
+	head, _ := p.CurrentHash()
+	err = p.RestoreCheckpoint(*hash, testPath)
+	if err != nil {
+		t.Fatal("failed to restore the checkpoint:", err)
+	}
+	if data, _ := ioutil.ReadFile(testPath); string(data) != "checkpointed" {
+		t.Errorf("the file was restored to %q", data)
+	}
+	if data, _ := ioutil.ReadFile(otherPath); string(data) != "kept" {
+		t.Error("a file outside the restored paths was changed")
+	}
+	restoredIndex, _ := loadIndex(p.gudPath)
+	restoredHead, _ := p.CurrentHash()
+	if len(restoredIndex) != len(index) || *restoredHead != *head {
+		t.Error("restoring the checkpoint changed the index or the history")
+	}
+}
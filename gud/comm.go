@@ -3,6 +3,8 @@ package gud
 import (
 	"compress/zlib"
 	"container/list"
+	"crypto/sha1"
+	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -19,6 +21,12 @@ import (
 const blobContentType = "application/x-gud-blob"
 const treeContentType = "application/x-gud-tree"
 const versionContentType = "application/x-gud-version"
+const transferContentType = "application/x-gud-transfer"
+const incomingFilePath = "incoming"
+
+// incomingSaveInterval is how many objects are received between the saves of the state of a transfer,
+// for it to be resumed if the process is killed.
+const incomingSaveInterval = 100
 
 type InputError Error
 
@@ -26,7 +34,84 @@ func (e InputError) Error() string {
 	return e.s
 }
 
-func (p Project) PushBranch(out io.Writer, branch string, start *ObjectHash) (boundary string, err error) {
+// Haves returns the versions the project has, for the sender of a transfer to skip the objects it already has.
+// They are the heads of its branches and remote-tracking branches.
+func (p Project) Haves() ([]ObjectHash, error) {
+	var haves []ObjectHash
+	err := p.ListBranches(func(branch string) error {
+		hash, err := p.GetBranch(branch)
+		if err != nil || hash == nil {
+			return err
+		}
+		haves = append(haves, *hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.ListRemoteBranches(func(name string) error {
+		hash, err := p.lookupRemoteBranch(name)
+		if err != nil || hash == nil {
+			return err
+		}
+		haves = append(haves, *hash)
+		return nil
+	})
+	return haves, err
+}
+
+// Progress is how much of a transfer is done. Total is the number of objects in the transfer,
+// or 0 if it is not known.
+type Progress struct {
+	Objects int
+	Total   int
+	Bytes   int64
+}
+
+// PushOptions are the options of PushBranch.
+type PushOptions struct {
+	Start    *ObjectHash  // the version of the branch the receiver has
+	Haves    []ObjectHash // other versions the receiver has
+	Resume   string       // the ID of the interrupted transfer the receiver has a part of
+	Skip     int          // the number of objects the receiver has of the interrupted transfer
+	Boundary string       // the multipart boundary, or empty for a random one
+	Force    bool         // replace the branch of the receiver, even if the branch does not follow it
+	Lease    *ObjectHash  // if not nil, a forced transfer is only accepted if the receiver's branch is at it
+	Progress func(Progress)
+}
+
+// PullOptions are the options of PullBranchWith.
+type PullOptions struct {
+	User        string // if not empty, the new versions must have been saved by them
+	ForbidForce bool   // reject forced transfers with ErrForceForbidden
+	Progress    func(Progress)
+}
+
+// transferHeader is the first part of a transfer, which identifies it so it can be resumed,
+// and tells the receiver which version of its branch the transferred versions follow.
+type transferHeader struct {
+	ID    string
+	Skip  int
+	Total int
+	Start *ObjectHash // the version the first transferred version follows
+	Force bool
+	Lease *ObjectHash
+}
+
+// transferPart is an object that a transfer sends.
+type transferPart struct {
+	Hash        ObjectHash
+	ContentType string
+}
+
+// PushBranch writes the versions of a branch after options.Start to out, for PullBranch.
+// The receiver has the start and the versions in options.Haves, so the objects of those that this project
+// knows are not written, and every other object is written once, after all the objects it refers to.
+// If options.Resume is the ID of the same transfer, the first options.Skip objects are not written.
+// If the branch does not follow the start, a NonFastForwardError is returned, unless options.Force is set,
+// and then the versions after the latest version of the branch the receiver has are written.
+func (p Project) PushBranch(out io.Writer, branch string, options PushOptions) (boundary string, err error) {
 	hash, err := p.GetBranch(branch)
 	if err != nil {
 		return
@@ -35,13 +120,61 @@ func (p Project) PushBranch(out io.Writer, branch string, start *ObjectHash) (bo
 		return "", InputError{"branch does not exist"}
 	}
 
+	haves := options.Haves
+	if options.Start != nil {
+		haves = append(haves[:len(haves):len(haves)], *options.Start)
+	}
+
+	start := options.Start
+	if start != nil {
+		forward, err := isDescendent(p.gudPath, *hash, *start)
+		if err != nil {
+			return "", err
+		}
+		if !forward && !options.Force {
+			return "", NonFastForwardError{branch}
+		}
+		if !forward {
+			start, err = p.forcedStart(*hash, haves)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
 	versions := list.New()
 	err = getVersions(p.gudPath, *hash, start, versions)
 	if err != nil {
 		return "", err
 	}
 
-	writer := multipart.NewWriter(out)
+	sent, err := p.haveObjects(haves)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []transferPart
+	for e := versions.Back(); e != nil; e = e.Prev() {
+		parts, err = planVersion(p.gudPath, e.Value.(ObjectHash), sent, parts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	header := transferHeader{ID: transferID(parts), Total: len(parts), Start: start, Force: options.Force,
+		Lease: options.Lease}
+	if options.Resume == header.ID && options.Skip <= len(parts) {
+		header.Skip = options.Skip
+	}
+
+	counter := &countingWriter{Writer: out}
+	writer := multipart.NewWriter(counter)
+	if options.Boundary != "" {
+		err = writer.SetBoundary(options.Boundary)
+		if err != nil {
+			return "", err
+		}
+	}
 	defer func() {
 		cerr := writer.Close()
 		if err == nil {
@@ -49,17 +182,89 @@ func (p Project) PushBranch(out io.Writer, branch string, start *ObjectHash) (bo
 		}
 	}()
 
-	for e := versions.Back(); e != nil; e = e.Prev() {
-		hash := e.Value.(ObjectHash)
-		err = pushVersion(p.gudPath, writer, hash)
+	err = pushHeader(writer, header)
+	if err != nil {
+		return "", err
+	}
+
+	progress := Progress{Objects: header.Skip, Total: header.Total}
+	for _, part := range parts[header.Skip:] {
+		err = pushObject(p.gudPath, writer, part)
 		if err != nil {
 			return "", err
 		}
+
+		progress.Objects++
+		progress.Bytes = counter.n
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
 	}
 
 	return writer.Boundary(), nil
 }
 
+// forcedStart returns the latest version in the history of hash that the receiver of a forced transfer has,
+// because it is in the history of one of the versions in haves, or nil if it has none of them.
+func (p Project) forcedStart(hash ObjectHash, haves []ObjectHash) (*ObjectHash, error) {
+	known := make(map[ObjectHash]bool)
+	for _, have := range haves {
+		_, err := os.Stat(objectPath(p.gudPath, have))
+		if os.IsNotExist(err) {
+			continue
+		}
+		err = markAncestors(p.gudPath, have, known)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for !known[hash] {
+		version, err := loadVersion(p.gudPath, hash)
+		if err != nil {
+			return nil, err
+		}
+		if !version.HasPrev() {
+			return nil, nil
+		}
+		hash = *version.prev
+	}
+	return &hash, nil
+}
+
+// haveObjects returns the trees and blobs of the versions in haves, which the receiver of a transfer has.
+// Versions this project does not know are skipped.
+func (p Project) haveObjects(haves []ObjectHash) (map[ObjectHash]bool, error) {
+	objects := make(map[ObjectHash]bool)
+	for _, hash := range haves {
+		version, err := loadVersion(p.gudPath, hash)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if objects[version.TreeHash] {
+			continue
+		}
+
+		objects[version.TreeHash] = true
+		root, err := loadTree(p.gudPath, version.TreeHash)
+		if err != nil {
+			return nil, err
+		}
+		err = walkObjects(p.gudPath, "", root, func(_ string, obj object) error {
+			objects[obj.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
 func getVersions(gudPath string, hash ObjectHash, start *ObjectHash, nexts *list.List) error {
 	if start != nil && hash == *start {
 		return nil
@@ -94,44 +299,81 @@ func getVersions(gudPath string, hash ObjectHash, start *ObjectHash, nexts *list
 	return nil
 }
 
-func pushVersion(gudPath string, writer *multipart.Writer, hash ObjectHash) error {
-	part, err := createPart(writer, hash, versionContentType)
+// planVersion adds a version to the parts of a transfer, after the objects of its tree that were not sent.
+func planVersion(gudPath string, hash ObjectHash, sent map[ObjectHash]bool, parts []transferPart,
+) ([]transferPart, error) {
+	version, err := loadVersion(gudPath, hash)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	src, err := os.Open(objectPath(gudPath, hash))
+	parts, err = planTree(gudPath, version.TreeHash, sent, parts)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer src.Close()
 
-	version, err := versionFromReader(io.TeeReader(src, part))
-	if err != nil {
-		return err
+	return append(parts, transferPart{hash, versionContentType}), nil
+}
+
+// planTree adds a tree to the parts of a transfer after the objects in it, skipping the ones that were sent.
+func planTree(gudPath string, hash ObjectHash, sent map[ObjectHash]bool, parts []transferPart,
+) ([]transferPart, error) {
+	if sent[hash] {
+		return parts, nil
 	}
+	sent[hash] = true
 
-	return walk(gudPath, *version, func(relPath string, obj object) error {
-		var contentType string
+	root, err := loadTree(gudPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range root {
 		if obj.Type == typeTree {
-			contentType = treeContentType
-		} else {
-			contentType = blobContentType
-		}
-		part, err := createPart(writer, obj.Hash, contentType)
-		if err != nil {
-			return err
+			parts, err = planTree(gudPath, obj.Hash, sent, parts)
+			if err != nil {
+				return nil, err
+			}
+		} else if !sent[obj.Hash] {
+			sent[obj.Hash] = true
+			parts = append(parts, transferPart{obj.Hash, blobContentType})
 		}
+	}
 
-		src, err := os.Open(objectPath(gudPath, obj.Hash))
-		if err != nil {
-			return err
-		}
-		defer src.Close()
+	return append(parts, transferPart{hash, treeContentType}), nil
+}
+
+// transferID identifies a transfer by the objects it sends, in their order.
+func transferID(parts []transferPart) string {
+	h := sha1.New()
+	for _, part := range parts {
+		_, _ = h.Write(part.Hash[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func pushHeader(writer *multipart.Writer, header transferHeader) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {transferContentType}})
+	if err != nil {
+		return err
+	}
+
+	return gob.NewEncoder(part).Encode(header)
+}
 
-		_, err = io.Copy(part, src)
+func pushObject(gudPath string, writer *multipart.Writer, part transferPart) error {
+	dst, err := createPart(writer, part.Hash, part.ContentType)
+	if err != nil {
 		return err
-	})
+	}
+
+	src, err := os.Open(objectPath(gudPath, part.Hash))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
 }
 
 func createPart(writer *multipart.Writer, hash ObjectHash, contentType string) (io.Writer, error) {
@@ -142,11 +384,29 @@ func createPart(writer *multipart.Writer, hash ObjectHash, contentType string) (
 	return writer.CreatePart(header)
 }
 
+// PullBranch receives the versions of a branch that PushBranch wrote, see PullBranchWith.
 func (p Project) PullBranch(branch string, in io.Reader, contentType string) (*ObjectHash, error) {
-	return p.PullBranchFrom(branch, in, contentType, "")
+	return p.PullBranchWith(branch, in, contentType, PullOptions{})
 }
 
+// PullBranchFrom is PullBranch for versions that must have been saved by user, if it is not empty.
 func (p Project) PullBranchFrom(branch string, in io.Reader, contentType, user string) (*ObjectHash, error) {
+	return p.PullBranchWith(branch, in, contentType, PullOptions{User: user})
+}
+
+// PullBranchWith receives the versions of a branch that PushBranch wrote, and moves the branch to the last of them.
+// Objects the project has may be skipped by the sender, but every object must come after the objects it refers to,
+// so it is checked as it is received.
+// If the transfer is interrupted, the objects that were received are kept, and the sender can resume it
+// from the ID and the number of objects that IncomingTransfer returns.
+func (p Project) PullBranchWith(branch string, in io.Reader, contentType string, options PullOptions,
+) (*ObjectHash, error) {
+	return p.pullRef(filepath.Join(branchesPath, branch), in, contentType, options)
+}
+
+// pullRef receives versions that PushBranch wrote, and moves a reference to the last of them.
+func (p Project) pullRef(ref string, in io.Reader, contentType string, options PullOptions,
+) (hash *ObjectHash, err error) {
 	mediaType, params, err := mime.ParseMediaType(contentType)
 	if err != nil {
 		return nil, InputError{fmt.Sprintf("invalid content type: %s", contentType)}
@@ -155,61 +415,184 @@ func (p Project) PullBranchFrom(branch string, in io.Reader, contentType, user s
 		return nil, InputError{fmt.Sprintf("invalid content type: %s", contentType)}
 	}
 
-	currentHash, err := p.GetBranch(branch)
-	if err != nil {
-		return nil, err
-	}
-
-	temp, err := createTempProject(p)
+	transfer := incomingTransfer{Ref: ref}
+	transfer.Base, err = getRef(p.gudPath, ref)
 	if err != nil {
 		return nil, err
 	}
+	transfer.Head = transfer.Base
 	defer func() {
-		_ = os.RemoveAll(temp.Path)
+		if err != nil && transfer.ID != "" {
+			_ = dumpIncoming(p.gudPath, transfer)
+		}
 	}()
 
-	files := list.New()
-	objs := multipart.NewReader(in, params["boundary"])
-	for {
-		hash, err := pullVersion(temp.gudPath, user, objs, currentHash, files)
+	var progress Progress
+	counter := &countingReader{Reader: in}
+	objs := multipart.NewReader(counter, params["boundary"])
+	for first := true; ; first = false {
+		part, err := objs.NextPart()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return nil, err
+			return nil, InputError{"invalid multipart data"}
 		}
-		if hash == nil {
-			break
+
+		if first && part.Header.Get("Content-Type") == transferContentType {
+			progress.Total, err = p.resumeIncoming(&transfer, part, options)
+			progress.Objects = transfer.Parts
+			_ = part.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
 		}
-		currentHash = hash
-	}
 
-	for e := files.Front(); e != nil; e = e.Next() {
-		name := e.Value.(string)
-		err = copyFile(filepath.Join(temp.gudPath, objectsPath, name), filepath.Join(p.gudPath, objectsPath, name))
+		switch part.Header.Get("Content-Type") {
+		case versionContentType:
+			var hash *ObjectHash
+			hash, err = pullVersion(p.gudPath, options.User, part, transfer.Head)
+			if err == nil {
+				transfer.Head = hash
+			}
+		case treeContentType:
+			err = pullTree(p.gudPath, part)
+		case blobContentType:
+			err = pullBlob(p.gudPath, part)
+		default:
+			err = InputError{fmt.Sprintf("invalid content type: %s", part.Header.Get("Content-Type"))}
+		}
+		_ = part.Close()
 		if err != nil {
 			return nil, err
 		}
+		transfer.Parts++
+		if transfer.ID != "" && transfer.Parts%incomingSaveInterval == 0 {
+			err = dumpIncoming(p.gudPath, transfer)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		progress.Objects++
+		progress.Bytes = counter.n
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
 	}
 
-	if currentHash != nil {
-		err = dumpBranch(p.gudPath, branch, *currentHash)
+	if transfer.Head != nil {
+		err = dumpRef(p.gudPath, ref, *transfer.Head)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return currentHash, nil
+	err = os.Remove(incomingPath(p.gudPath, ref))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return transfer.Head, nil
+}
+
+// IncomingTransfer returns the ID of the interrupted transfer of a branch into the project,
+// and the number of objects that were received, for the sender to resume it.
+// The ID is empty if there is no transfer to resume.
+func (p Project) IncomingTransfer(branch string) (string, int, error) {
+	return p.incomingTransfer(filepath.Join(branchesPath, branch))
 }
 
-func pullVersion(gudPath, user string, reader *multipart.Reader, prevHash *ObjectHash, files *list.List,
-) (hash *ObjectHash, err error) {
-	part, err := reader.NextPart()
-	if err == io.EOF {
-		return nil, nil
+func (p Project) incomingTransfer(ref string) (string, int, error) {
+	transfer, err := p.loadIncoming(ref)
+	if err != nil || transfer == nil {
+		return "", 0, err
+	}
+
+	return transfer.ID, transfer.Parts, nil
+}
+
+// resumeIncoming reads the header of a transfer, and continues the interrupted transfer it resumes.
+// It returns the number of objects in the whole transfer.
+func (p Project) resumeIncoming(transfer *incomingTransfer, part *multipart.Part, options PullOptions) (int, error) {
+	var header transferHeader
+	err := gob.NewDecoder(part).Decode(&header)
+	if err != nil {
+		return 0, InputError{"invalid transfer header"}
+	}
+
+	head, err := p.acceptTransfer(*transfer, header, options)
+	if err != nil {
+		return 0, err
 	}
+
+	if header.Skip == 0 {
+		transfer.ID = header.ID
+		transfer.Head = head
+		return header.Total, nil
+	}
+
+	saved, err := p.loadIncoming(transfer.Ref)
 	if err != nil {
-		return nil, InputError{"invalid multipart data"}
+		return 0, err
+	}
+	if saved == nil || saved.ID != header.ID || saved.Parts != header.Skip {
+		return 0, InputError{"the transfer cannot be resumed"}
+	}
+
+	*transfer = *saved
+	return header.Total, nil
+}
+
+// acceptTransfer checks that a transfer moves a reference forward, or that it may replace it if it is forced,
+// and returns the version the transferred versions follow.
+func (p Project) acceptTransfer(transfer incomingTransfer, header transferHeader, options PullOptions,
+) (*ObjectHash, error) {
+	if !header.Force {
+		if !sameVersion(header.Start, transfer.Base) {
+			return nil, NonFastForwardError{refName(transfer.Ref)}
+		}
+		return transfer.Base, nil
+	}
+
+	if options.ForbidForce {
+		return nil, ErrForceForbidden
+	}
+	if header.Lease != nil && !sameVersion(header.Lease, transfer.Base) {
+		return nil, NonFastForwardError{refName(transfer.Ref)}
+	}
+	if header.Start != nil {
+		_, err := loadVersion(p.gudPath, *header.Start)
+		if os.IsNotExist(err) {
+			return nil, InputError{fmt.Sprintf("missing object: %s", header.Start)}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return header.Start, nil
+}
+
+// sameVersion returns whether two versions, which are nil before the first version of a branch, are the same.
+func sameVersion(a, b *ObjectHash) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// refName returns the name of the branch or the remote-tracking branch of a reference,
+// like "master" or "origin/master".
+func refName(ref string) string {
+	name := filepath.ToSlash(ref)
+	if strings.HasPrefix(name, branchesPath+"/") {
+		return strings.TrimPrefix(name, branchesPath+"/")
 	}
-	defer part.Close()
+	return strings.TrimPrefix(name, remotesPath+"/")
+}
 
+// pullVersion receives a version, which must follow the version prevHash and come after its tree.
+func pullVersion(gudPath, user string, part *multipart.Part, prevHash *ObjectHash) (hash *ObjectHash, err error) {
 	hash, exists, err := validatePart(gudPath, part, versionContentType)
 	if err != nil {
 		return
@@ -217,15 +600,17 @@ func pullVersion(gudPath, user string, reader *multipart.Reader, prevHash *Objec
 
 	var src io.Reader = part
 	if !exists {
-		dst, err := os.Create(objectPath(gudPath, *hash))
+		var dst *atomicFile
+		dst, err = createAtomic(gudPath, objectPath(gudPath, *hash))
 		if err != nil {
-			return nil, err
+			return
 		}
 		defer func() {
-			cerr := dst.Close()
-			if err == nil {
-				err = cerr
+			if err != nil {
+				dst.Discard()
+				return
 			}
+			err = dst.Commit()
 		}()
 
 		src = io.TeeReader(part, dst)
@@ -233,133 +618,114 @@ func pullVersion(gudPath, user string, reader *multipart.Reader, prevHash *Objec
 		user = "" // only check new version authors
 	}
 
-	current, err := versionFromReader(src)
+	version, err := versionFromReader(src)
 	if err != nil {
 		return
 	}
-
-	err = validateVersion(gudPath, user, *current, *hash, prevHash)
+	_, err = io.Copy(ioutil.Discard, src)
 	if err != nil {
 		return
 	}
 
-	files.PushBack(part.FileName())
+	err = validateVersion(gudPath, user, *version, *hash, prevHash)
+	if err != nil {
+		return
+	}
 
-	err = pullTree(gudPath, reader, current.TreeHash, files)
+	if !exists {
+		err = checkObject(gudPath, version.TreeHash)
+	}
 	return
 }
 
-func pullTree(
-	gudPath string, reader *multipart.Reader, expectedHash ObjectHash, files *list.List) error {
-	part, err := reader.NextPart()
-	if err != nil {
-		return InputError{"invalid multipart data"}
+// pullTree receives a tree, which must come after all the objects in it.
+func pullTree(gudPath string, part *multipart.Part) (err error) {
+	hash, exists, err := validatePart(gudPath, part, treeContentType)
+	if err != nil || exists {
+		return
 	}
-	defer part.Close()
 
-	hash, exists, err := validatePart(gudPath, part, treeContentType)
+	dst, err := createAtomic(gudPath, objectPath(gudPath, *hash))
 	if err != nil {
-		return err
-	}
-	if *hash != expectedHash {
-		return InputError{fmt.Sprintf("unexpected tree: expected %s, got %s", expectedHash, hash)}
+		return
 	}
-
-	var src io.Reader = part
-	if !exists {
-		dst, err := os.Create(objectPath(gudPath, *hash))
+	defer func() {
 		if err != nil {
-			return err
+			dst.Discard()
+			return
 		}
-		defer func() {
-			cerr := dst.Close()
-			if err == nil {
-				err = cerr
-			}
-		}()
-
-		src = io.TeeReader(part, dst)
-	}
+		err = dst.Commit()
+	}()
 
+	src := io.TeeReader(part, dst)
 	var current tree
 	err = readGobObject(src, &current)
 	if err != nil {
 		return InputError{fmt.Sprintf("invalid tree object: %s", hash)}
 	}
+	_, err = io.Copy(ioutil.Discard, src)
+	if err != nil {
+		return
+	}
 
 	if !sort.IsSorted(current) {
 		return InputError{fmt.Sprintf("invalid tree: %s", hash)}
 	}
-
-	files.PushBack(part.FileName())
-
 	for _, obj := range current {
-		switch obj.Type {
-		case typeBlob:
-			err = pullBlob(gudPath, reader, obj.Hash, files)
-			if err != nil {
-				return err
-			}
-
-		case typeTree:
-			err = pullTree(gudPath, reader, obj.Hash, files)
-			if err != nil {
-				return err
-			}
-
-		default:
+		if obj.Type != typeBlob && obj.Type != typeTree {
 			return InputError{fmt.Sprintf("invalid tree: %s", hash)}
 		}
+		err = checkObject(gudPath, obj.Hash)
+		if err != nil {
+			return
+		}
 	}
 
 	return nil
 }
 
-func pullBlob(gudPath string, reader *multipart.Reader, expectedHash ObjectHash, files *list.List) error {
-	part, err := reader.NextPart()
-	if err != nil {
-		return InputError{"invalid multipart data"}
-	}
-	defer part.Close()
-
+func pullBlob(gudPath string, part *multipart.Part) (err error) {
 	hash, exists, err := validatePart(gudPath, part, blobContentType)
-	if err != nil {
-		return err
-	}
-	if *hash != expectedHash {
-		return InputError{fmt.Sprintf("unexpected blob: expected %s, got %s", expectedHash, hash)}
-	}
-	if exists {
-		return nil
+	if err != nil || exists {
+		return
 	}
 
-	name := part.FileName()
-	dst, err := os.Create(objectPath(gudPath, *hash))
+	dst, err := createAtomic(gudPath, objectPath(gudPath, *hash))
 	if err != nil {
-		return err
+		return
 	}
 	defer func() {
-		cerr := dst.Close()
-		if err == nil {
-			err = cerr
+		if err != nil {
+			dst.Discard()
+			return
 		}
+		err = dst.Commit()
 	}()
 
 	zip, err := zlib.NewReader(io.TeeReader(part, dst))
 	if err != nil {
-		return err
+		return InputError{fmt.Sprintf("invalid blob: %s", hash)}
 	}
 	defer zip.Close()
 
 	_, err = ioutil.ReadAll(zip)
 	if err != nil {
-		return err
+		return InputError{fmt.Sprintf("invalid blob: %s", hash)}
 	}
 
-	files.PushBack(name)
 	return nil
 }
 
+// checkObject makes sure that an object a received object refers to is in the project.
+// Every object is only written after the objects it refers to, so the ones the project has are whole.
+func checkObject(gudPath string, hash ObjectHash) error {
+	_, err := os.Stat(objectPath(gudPath, hash))
+	if os.IsNotExist(err) {
+		return InputError{fmt.Sprintf("missing object: %s", hash)}
+	}
+	return err
+}
+
 func validatePart(gudPath string, part *multipart.Part, expectedType string) (*ObjectHash, bool, error) {
 	name := part.FileName()
 	var hash ObjectHash
@@ -380,8 +746,12 @@ func validatePart(gudPath string, part *multipart.Part, expectedType string) (*O
 }
 
 func validateVersion(rootPath, user string, v Version, hash ObjectHash, prevHash *ObjectHash) error {
-	if user != "" && v.Author != user {
-		return InputError{fmt.Sprintf("expected user %s, got %s", user, v.Author)}
+	saver := v.Author
+	if v.Committer != "" {
+		saver = v.Committer
+	}
+	if user != "" && saver != user {
+		return InputError{fmt.Sprintf("expected user %s, got %s", user, saver)}
 	}
 
 	if prevHash == nil {
@@ -436,57 +806,84 @@ func versionFromReader(in io.Reader) (*Version, error) {
 	return &ret, nil
 }
 
-func createTempProject(p Project) (temp *Project, err error) {
-	tempDir, err := ioutil.TempDir(os.TempDir(), "")
+// incomingTransfer is the state of a transfer into the project, which is kept when it is interrupted.
+type incomingTransfer struct {
+	Ref   string // the reference the transfer moves
+	ID    string
+	Parts int         // the number of objects that were received
+	Base  *ObjectHash // the reference before the transfer
+	Head  *ObjectHash // the last version that was received
+}
+
+// loadIncoming returns the interrupted transfer into a reference, or nil if there is none
+// or the reference was moved since it started.
+func (p Project) loadIncoming(ref string) (*incomingTransfer, error) {
+	file, err := os.Open(incomingPath(p.gudPath, ref))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			_ = os.RemoveAll(tempDir)
-		}
-	}()
+	defer file.Close()
 
-	dstGud := filepath.Join(tempDir, DefaultPath)
-	dst := filepath.Join(dstGud, objectsPath)
-	err = os.MkdirAll(dst, 0700)
+	var transfer incomingTransfer
+	err = gob.NewDecoder(file).Decode(&transfer)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if transfer.Ref != ref {
+		return nil, nil
 	}
 
-	src := filepath.Join(p.gudPath, objectsPath)
-	objs, err := ioutil.ReadDir(src)
+	current, err := getRef(p.gudPath, ref)
 	if err != nil {
-		return
+		return nil, err
 	}
-	for _, obj := range objs {
-		err = copyFile(filepath.Join(src, obj.Name()), filepath.Join(dst, obj.Name()))
-		if err != nil {
-			return
-		}
+	if !sameHash(current, transfer.Base) {
+		return nil, nil
 	}
-
-	return &Project{tempDir, dstGud}, nil
+	return &transfer, nil
 }
 
-func copyFile(srcPath, dstPath string) (err error) {
-	src, err := os.Open(srcPath)
+// dumpIncoming keeps the state of an interrupted transfer. It is not journaled,
+// so it stays when the transaction that received it is aborted.
+func dumpIncoming(gudPath string, transfer incomingTransfer) error {
+	path := incomingPath(gudPath, transfer.Ref)
+	err := os.MkdirAll(filepath.Dir(path), dirPerm)
 	if err != nil {
-		return
+		return err
 	}
-	defer src.Close()
 
-	dst, err := os.Create(dstPath)
-	if err != nil {
-		return
-	}
-	defer func() {
-		cerr := dst.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
+	return writeDurable(gudPath, path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(transfer)
+	})
+}
 
-	_, err = io.Copy(dst, src)
-	return
+// incomingPath returns the path of the state of a transfer into a reference. Each reference has its own,
+// so transfers into different references can be resumed.
+func incomingPath(gudPath, ref string) string {
+	return filepath.Join(gudPath, incomingFilePath, ref)
+}
+
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.n += int64(n)
+	return n, err
 }
@@ -3,12 +3,15 @@ package gud
 import (
 	"bytes"
 	"io/ioutil"
+	"mime/multipart"
 	"os"
 	"path/filepath"
 	"testing"
 )
 
 func TestProject_PushBranch(t *testing.T) {
+	defer clearTest()
+
 	clientPath := filepath.Join(testDir, "client")
 	serverPath := filepath.Join(testDir, "server")
 	_ = os.Mkdir(clientPath, dirPerm)
@@ -26,7 +29,7 @@ func TestProject_PushBranch(t *testing.T) {
 	_, _ = client.Save(message)
 
 	var buf bytes.Buffer
-	boundary, err := client.PushBranch(&buf, FirstBranchName, nil)
+	boundary, err := client.PushBranch(&buf, FirstBranchName, PushOptions{})
 	if err != nil {
 		t.Fatal("failed to push branch: ", err)
 	}
@@ -65,3 +68,209 @@ func TestProject_PushBranch(t *testing.T) {
 		t.Fatal("invalid blob data")
 	}
 }
+
+func TestProject_PushBranchMissingObjects(t *testing.T) {
+	defer clearTest()
+
+	clientPath := filepath.Join(testDir, "client")
+	serverPath := filepath.Join(testDir, "server")
+	_ = os.Mkdir(clientPath, dirPerm)
+	_ = os.Mkdir(serverPath, dirPerm)
+
+	client, _ := Start(clientPath)
+	server, _ := StartHeadless(serverPath)
+
+	_ = os.Mkdir(filepath.Join(clientPath, "dir"), dirPerm)
+	_ = ioutil.WriteFile(filepath.Join(clientPath, "dir", "inner"), []byte("inner"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(clientPath, testFile), []byte("first"), 0644)
+	_ = client.AddAll()
+	_, _ = client.Save("first")
+
+	var buf bytes.Buffer
+	boundary, _ := client.PushBranch(&buf, FirstBranchName, PushOptions{})
+	_, err := server.PullBranch(FirstBranchName, &buf, "multipart/mixed; boundary="+boundary)
+	if err != nil {
+		t.Fatal("failed to pull the first version:", err)
+	}
+	start, _ := server.GetBranch(FirstBranchName)
+
+	for _, data := range []string{"second", "third"} {
+		_ = ioutil.WriteFile(filepath.Join(clientPath, testFile), []byte(data), 0644)
+		_ = client.AddAll()
+		_, _ = client.Save(data)
+	}
+
+	haves, err := server.Haves()
+	if err != nil || len(haves) != 1 || haves[0] != *start {
+		t.Fatal("unexpected haves:", haves, err)
+	}
+
+	buf.Reset()
+	boundary, err = client.PushBranch(&buf, FirstBranchName, PushOptions{Start: start, Haves: haves})
+	if err != nil {
+		t.Fatal("failed to push branch:", err)
+	}
+
+	// The header, and two versions with their root trees and changed files, but not the unchanged directory
+	reader := multipart.NewReader(bytes.NewReader(buf.Bytes()), boundary)
+	count := 0
+	for {
+		_, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		count++
+	}
+	if count != 7 {
+		t.Errorf("%d parts were sent instead of 7", count)
+	}
+
+	hash, err := server.PullBranch(FirstBranchName, &buf, "multipart/mixed; boundary="+boundary)
+	if err != nil {
+		t.Fatal("failed to pull the missing objects:", err)
+	}
+	clientHash, _ := client.CurrentHash()
+	if *hash != *clientHash {
+		t.Error("the branch was not moved to the pushed version")
+	}
+	if _, err = server.savedFiles(filepath.Join("dir", "inner"), *hash); err != nil {
+		t.Error("the pushed version is not whole:", err)
+	}
+
+	// A receiver that lacks the objects the sender skipped rejects the versions
+	otherPath := filepath.Join(testDir, "other")
+	_ = os.Mkdir(otherPath, dirPerm)
+	other, _ := StartHeadless(otherPath)
+	buf.Reset()
+	boundary, _ = client.PushBranch(&buf, FirstBranchName, PushOptions{Haves: []ObjectHash{*start}})
+	_, err = other.PullBranch(FirstBranchName, &buf, "multipart/mixed; boundary="+boundary)
+	if _, ok := err.(InputError); !ok {
+		t.Error("versions without their objects were accepted:", err)
+	}
+}
+
+func TestProject_PullBranchResume(t *testing.T) {
+	defer clearTest()
+
+	clientPath := filepath.Join(testDir, "client")
+	serverPath := filepath.Join(testDir, "server")
+	_ = os.Mkdir(clientPath, dirPerm)
+	_ = os.Mkdir(serverPath, dirPerm)
+
+	client, _ := Start(clientPath)
+	server, _ := StartHeadless(serverPath)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		_ = ioutil.WriteFile(filepath.Join(clientPath, name), []byte("data of "+name), 0644)
+	}
+	_ = client.AddAll()
+	_, _ = client.Save("first")
+
+	var buf bytes.Buffer
+	boundary, err := client.PushBranch(&buf, FirstBranchName, PushOptions{})
+	if err != nil {
+		t.Fatal("failed to push branch:", err)
+	}
+
+	// The connection is lost in the middle of the transfer
+	_, err = server.PullBranch(FirstBranchName, bytes.NewReader(buf.Bytes()[:buf.Len()/2]),
+		"multipart/mixed; boundary="+boundary)
+	if err == nil {
+		t.Fatal("a partial transfer was accepted")
+	}
+	if hash, _ := server.GetBranch(FirstBranchName); hash != nil {
+		t.Error("the branch was moved by a partial transfer")
+	}
+
+	id, skip, err := server.IncomingTransfer(FirstBranchName)
+	if err != nil {
+		t.Fatal("failed to load the incoming transfer:", err)
+	}
+	if id == "" || skip == 0 {
+		t.Fatal("the received objects were not kept:", id, skip)
+	}
+
+	buf.Reset()
+	var progress []Progress
+	boundary, err = client.PushBranch(&buf, FirstBranchName, PushOptions{
+		Resume:   id,
+		Skip:     skip,
+		Progress: func(p Progress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal("failed to resume the push:", err)
+	}
+	if len(progress) == 0 || progress[0].Objects != skip+1 {
+		t.Error("the received objects were sent again:", progress)
+	}
+	last := progress[len(progress)-1]
+	if last.Objects != last.Total || last.Bytes == 0 || last.Bytes > int64(buf.Len()) {
+		t.Error("invalid progress:", last, buf.Len())
+	}
+
+	hash, err := server.PullBranch(FirstBranchName, &buf, "multipart/mixed; boundary="+boundary)
+	if err != nil {
+		t.Fatal("failed to resume the pull:", err)
+	}
+	clientHash, _ := client.CurrentHash()
+	if *hash != *clientHash {
+		t.Error("the branch was not moved to the pushed version")
+	}
+	if id, _, _ = server.IncomingTransfer(FirstBranchName); id != "" {
+		t.Error("the finished transfer was kept")
+	}
+}
+
+func TestProject_PullBranchResumeOtherBranch(t *testing.T) {
+	defer clearTest()
+
+	clientPath := filepath.Join(testDir, "client")
+	serverPath := filepath.Join(testDir, "server")
+	_ = os.Mkdir(clientPath, dirPerm)
+	_ = os.Mkdir(serverPath, dirPerm)
+
+	client, _ := Start(clientPath)
+	server, _ := StartHeadless(serverPath)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		_ = ioutil.WriteFile(filepath.Join(clientPath, name), []byte("data of "+name), 0644)
+	}
+	_ = client.AddAll()
+	_, _ = client.Save("first")
+
+	var buf bytes.Buffer
+	boundary, _ := client.PushBranch(&buf, FirstBranchName, PushOptions{})
+	_, _ = server.PullBranch(FirstBranchName, bytes.NewReader(buf.Bytes()[:buf.Len()/2]),
+		"multipart/mixed; boundary="+boundary)
+	id, _, _ := server.IncomingTransfer(FirstBranchName)
+	if id == "" {
+		t.Fatal("the interrupted transfer was not kept")
+	}
+
+	// A transfer into another branch does not replace the interrupted one
+	_ = client.CreateBranch("other")
+	buf.Reset()
+	boundary, _ = client.PushBranch(&buf, "other", PushOptions{})
+	_, _ = server.PullBranch("other", bytes.NewReader(buf.Bytes()[:buf.Len()/2]),
+		"multipart/mixed; boundary="+boundary)
+	if other, _, _ := server.IncomingTransfer("other"); other == "" {
+		t.Error("the interrupted transfer into the other branch was not kept:", other)
+	}
+	if resumed, _, _ := server.IncomingTransfer(FirstBranchName); resumed != id {
+		t.Error("the interrupted transfer was replaced by a transfer into another branch:", resumed)
+	}
+
+	// A pull of another branch does not remove it either
+	buf.Reset()
+	boundary, _ = client.PushBranch(&buf, "other", PushOptions{})
+	_, err := server.PullBranch("other", &buf, "multipart/mixed; boundary="+boundary)
+	if err != nil {
+		t.Fatal("failed to pull the other branch:", err)
+	}
+	if other, _, _ := server.IncomingTransfer("other"); other != "" {
+		t.Error("the finished transfer was kept")
+	}
+	if resumed, _, _ := server.IncomingTransfer(FirstBranchName); resumed != id {
+		t.Error("the interrupted transfer was removed by a pull of another branch:", resumed)
+	}
+}
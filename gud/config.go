@@ -1,6 +1,7 @@
 package gud
 
 import (
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -16,12 +17,18 @@ const defaultDomainServer = "https://gud.codes"
 type Config struct {
 	ProjectName string
 	OwnerName   string
-	Checkpoints int
+	Checkpoints int // the number of checkpoints kept, if there is no retention policy
 	AutoPush    bool
+	QuietPeriod int               // seconds without changes before gud watch saves a checkpoint, 0 for the default
+	Retention   []RetentionRule   // the checkpoints that are kept by their age, kept if any rule keeps them
+	Remotes     map[string]Remote `toml:"remotes,omitempty"` // the projects it is pushed to and pulled from, by name
 }
 
 type GlobalConfig struct {
 	Name, Token, ServerDomain string
+	MergeTool, DiffTool       string // a known tool name, or a command line with $BASE, $LOCAL, $REMOTE and $MERGED
+	ExcludesFile              string // patterns ignored in every project, ~/.gudExcludes if empty
+	Parallelism               int    // files hashed and compressed at once, one for each CPU if not positive
 }
 
 func (config GlobalConfig) GetPath() string {
@@ -33,11 +40,19 @@ func (config GlobalConfig) GetPath() string {
 }
 
 func (p Project) ConfigInit() (err error) {
-	return p.WriteConfig(Config{filepath.Base(p.Path), "", 3, false})
+	return p.WriteConfig(Config{ProjectName: filepath.Base(p.Path), Checkpoints: 3, Retention: DefaultRetention})
 }
 
 func (p *Project) WriteConfig(config Config) (err error) {
-	return WriteConfig(config, filepath.Join(p.gudPath, localConfigPath))
+	b, err := toml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return writeMetadata(p.gudPath, localConfigPath, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
 }
 
 func WriteConfig(config interface{}, path string) (err error) {
@@ -93,7 +108,7 @@ func ReadConfig(path string) ([]byte, error) {
 			return nil, err
 		}
 
-		err = WriteConfig(GlobalConfig{"", "", defaultDomainServer}, GlobalConfig{}.GetPath())
+		err = WriteConfig(GlobalConfig{ServerDomain: defaultDomainServer}, GlobalConfig{}.GetPath())
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,441 @@
+package gud
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const diffContext = 3
+
+// FileDiff is a file that differs between two states of the project.
+type FileDiff struct {
+	Path     string
+	State    FileState
+	From     string // the path the file was moved from if it was renamed
+	Old, New string // the contents before and after the change, empty if the file does not exist
+	Binary   bool   // true if the changes should not be shown line by line
+}
+
+// Conflict is a file left conflicting in the index by a merge, a cherry-pick or a rebase.
+type Conflict struct {
+	Path string
+	// The blobs of the file in the common version, the current branch and the merged version,
+	// nil where the file does not exist
+	Base, Ours, Theirs *ObjectHash
+}
+
+// Diff returns the changes in the working tree that were not added to the index.
+// Files that are not tracked at all are not included.
+func (p Project) Diff() ([]FileDiff, error) {
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := p.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	root, err := loadTree(p.gudPath, version.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.diffWorkTree(root, index)
+}
+
+// diffWorkTree returns the changes in the working tree compared to a tree with the changes staged in index.
+func (p Project) diffWorkTree(root tree, index []indexEntry) ([]FileDiff, error) {
+	s, err := p.loadScan()
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []FileDiff
+	err = p.compareTree(".", root, index, s,
+		func(relPath string, state FileState, hash *ObjectHash, isDir bool) error {
+			if isDir || hash == nil {
+				return nil
+			}
+
+			old, err := readBlob(p.gudPath, *hash)
+			if err != nil {
+				return err
+			}
+
+			attrs, err := s.attributer().lookup(relPath)
+			if err != nil {
+				return err
+			}
+
+			diff := FileDiff{Path: relPath, State: state, Old: old}
+			if state != StateRemoved {
+				data, err := ioutil.ReadFile(filepath.Join(p.Path, relPath))
+				if err != nil {
+					return err
+				}
+				// Compared as it would be saved, so converted line endings are not changes
+				diff.New = string(attrs.toBlob(data))
+			}
+			diff.Binary = attrs.binaryDiff(diff.Old, diff.New)
+
+			diffs = append(diffs, diff)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.dumpScan(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffs, nil
+}
+
+// DiffStaged returns the changes in the index, compared to the current version.
+func (p Project) DiffStaged() ([]FileDiff, error) {
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := p.CurrentHash()
+	if err != nil {
+		return nil, err
+	}
+
+	at := p.loadAttributer()
+	moved := renames(index)
+	sources := make(map[string]bool)
+	for _, from := range moved {
+		sources[from] = true
+	}
+
+	var diffs []FileDiff
+	for _, entry := range index {
+		if entry.State == StateConflict || sources[entry.Path] {
+			continue
+		}
+
+		diff := FileDiff{Path: entry.Path, State: entry.State}
+		if from, ok := moved[entry.Path]; ok {
+			diff.State = StateRenamed
+			diff.From = from
+			diff.Old, err = readBlob(p.gudPath, entry.FromHash)
+			if err != nil {
+				return nil, err
+			}
+		} else if entry.State != StateNew {
+			obj, err := p.findObject(entry.Path, *current)
+			if err != nil {
+				return nil, err
+			}
+			if obj != nil {
+				diff.Old, err = readBlob(p.gudPath, obj.Hash)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if entry.State != StateRemoved {
+			diff.New, err = readBlob(p.gudPath, entry.Hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = at.markBinary(&diff)
+		if err != nil {
+			return nil, err
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+// DiffVersions returns the changes made from the version from to the version to.
+func (p Project) DiffVersions(from, to ObjectHash) ([]FileDiff, error) {
+	var trees [2]tree
+	for i, hash := range []ObjectHash{from, to} {
+		version, err := loadVersion(p.gudPath, hash)
+		if err != nil {
+			return nil, err
+		}
+		trees[i], err = loadTree(p.gudPath, version.TreeHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	at := p.loadAttributer()
+	var diffs []FileDiff
+	err := diffTrees(p.gudPath, "", trees[0], trees[1], func(relPath string, state FileState, obj object) error {
+		diff := FileDiff{Path: relPath, State: state}
+		var err error
+
+		switch state {
+		case StateRemoved:
+			diff.Old, err = readBlob(p.gudPath, obj.Hash)
+		case StateNew:
+			diff.New, err = readBlob(p.gudPath, obj.Hash)
+		case StateModified:
+			var old *object
+			old, err = p.findObject(relPath, from)
+			if err != nil {
+				return err
+			}
+			diff.Old, err = readBlob(p.gudPath, old.Hash)
+			if err != nil {
+				return err
+			}
+			diff.New, err = readBlob(p.gudPath, obj.Hash)
+		}
+		if err != nil {
+			return err
+		}
+		err = at.markBinary(&diff)
+		if err != nil {
+			return err
+		}
+
+		diffs = append(diffs, diff)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	moved, err := p.versionRenames(from, to)
+	if err != nil {
+		return nil, err
+	}
+	diffs = pairRenames(diffs, moved)
+	for i := range diffs {
+		if diffs[i].State == StateRenamed {
+			err = at.markBinary(&diffs[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return diffs, nil
+}
+
+// Conflicts returns the files that still have conflicts.
+func (p Project) Conflicts() ([]Conflict, error) {
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []Conflict
+	for _, entry := range index {
+		if entry.State == StateConflict {
+			conflicts = append(conflicts, Conflict{
+				Path:   entry.Path,
+				Base:   entry.Base,
+				Ours:   entry.Ours,
+				Theirs: entry.Theirs,
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// ReadBlob returns the contents of a file saved in the project.
+func (p Project) ReadBlob(hash ObjectHash) (string, error) {
+	return readBlob(p.gudPath, hash)
+}
+
+// DiffLine is a line of a diff.
+type DiffLine struct {
+	Op   byte // ' ' for an unchanged line, '-' for a removed one and '+' for an added one
+	Text string
+}
+
+// Hunk is a group of nearby changed lines, with a few unchanged lines around them.
+type Hunk struct {
+	// The first line and the number of lines the hunk covers in the old and the new file.
+	// An empty range starts at the line before it.
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []DiffLine
+}
+
+// Hunks returns the changes to a file, split to hunks.
+func (diff FileDiff) Hunks() []Hunk {
+	return splitHunks(lineDiff(diff.Old, diff.New))
+}
+
+// WriteDiff writes the changes line by line in the unified format,
+// with a few unchanged lines around every change. Of binary files, only the names are written.
+func WriteDiff(w io.Writer, diffs []FileDiff) error {
+	for _, diff := range diffs {
+		if diff.Binary {
+			oldName, newName := diffNames(diff)
+			_, err := fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := WriteDiffHeader(w, diff)
+		if err != nil {
+			return err
+		}
+
+		for _, hunk := range diff.Hunks() {
+			err = WriteHunk(w, hunk)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteDiffHeader writes the names of the old and the new file that start the diff of a file.
+func WriteDiffHeader(w io.Writer, diff FileDiff) error {
+	oldName, newName := diffNames(diff)
+	_, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	return err
+}
+
+func diffNames(diff FileDiff) (oldName, newName string) {
+	oldName, newName = "a/"+diff.Path, "b/"+diff.Path
+	if diff.State == StateRenamed {
+		oldName = "a/" + diff.From
+	}
+	if diff.State == StateNew {
+		oldName = "/dev/null"
+	}
+	if diff.State == StateRemoved {
+		newName = "/dev/null"
+	}
+	return oldName, newName
+}
+
+// WriteHunk writes a hunk in the unified format.
+func WriteHunk(w io.Writer, hunk Hunk) error {
+	_, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range hunk.Lines {
+		_, err = fmt.Fprintf(w, "%c%s", line.Op, line.Text)
+		if err == nil && !strings.HasSuffix(line.Text, "\n") {
+			_, err = fmt.Fprint(w, "\n\\ No newline at end of file\n")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lineDiff(old, new string) []DiffLine {
+	var lines []DiffLine
+
+	dmp := diffmatchpatch.New()
+	wSrc, wDst, wArr := dmp.DiffLinesToChars(old, new)
+	for _, diff := range dmp.DiffCharsToLines(dmp.DiffMain(wSrc, wDst, false), wArr) {
+		op := byte(' ')
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+
+		for _, text := range strings.SplitAfter(diff.Text, "\n") {
+			if text != "" {
+				lines = append(lines, DiffLine{Op: op, Text: text})
+			}
+		}
+	}
+
+	return lines
+}
+
+func splitHunks(lines []DiffLine) []Hunk {
+	// The line numbers in the old and the new file before each line
+	oldNums := make([]int, len(lines)+1)
+	newNums := make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldNums[i+1], newNums[i+1] = oldNums[i], newNums[i]
+		if line.Op != '+' {
+			oldNums[i+1]++
+		}
+		if line.Op != '-' {
+			newNums[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].Op == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk until the next change is too far to share the context
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(lines) && lines[next].Op == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				end += diffContext
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = next
+		}
+
+		hunk := Hunk{Lines: lines[start:end]}
+		hunk.OldStart, hunk.OldLines = hunkRange(oldNums[start], oldNums[end])
+		hunk.NewStart, hunk.NewLines = hunkRange(newNums[start], newNums[end])
+		hunks = append(hunks, hunk)
+
+		i = end
+	}
+
+	return hunks
+}
+
+func hunkRange(before, after int) (start, n int) {
+	if after == before { // an empty range is numbered by the line before it
+		return before, 0
+	}
+	return before + 1, after - before
+}
@@ -0,0 +1,74 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDiff(t *testing.T) {
+	var out strings.Builder
+	err := WriteDiff(&out, []FileDiff{{
+		Path:  testFile,
+		State: StateModified,
+		Old:   "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+		New:   "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\neleven\n12",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "--- a/testFile\n+++ b/testFile\n" +
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+		"@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n-12\n+eleven\n+12\n\\ No newline at end of file\n"
+	if out.String() != expected {
+		t.Errorf("wrong diff:\n%s", out.String())
+	}
+}
+
+func TestProject_Conflicts(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("base\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("base")
+	_ = p.CreateBranch("feature")
+
+	_ = ioutil.WriteFile(testPath, []byte("master\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("master change")
+
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("feature\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("feature change")
+
+	_ = p.CheckoutBranch(FirstBranchName)
+	_, err := p.MergeBranch("feature", MergeOptions{})
+	if err != ErrMergeConflict {
+		t.Fatal("expected a conflict, got:", err)
+	}
+
+	conflicts, err := p.Conflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Path != testFile {
+		t.Fatal("wrong conflicts:", conflicts)
+	}
+	for expected, hash := range map[string]*ObjectHash{
+		"base\n":    conflicts[0].Base,
+		"master\n":  conflicts[0].Ours,
+		"feature\n": conflicts[0].Theirs,
+	} {
+		if hash == nil {
+			t.Fatal("missing version of", expected)
+		}
+		if content, _ := p.ReadBlob(*hash); content != expected {
+			t.Errorf("expected %q, got %q", expected, content)
+		}
+	}
+}
@@ -12,3 +12,15 @@ func (e Error) Error() string {
 var ErrMergeConflict = Error{"there are merge conflicts. please resolve them and save the changes"}
 var ErrUnstagedChanges = Error{"the index must be empty when checking out"}
 var ErrUnsavedChanges = Error{"unsaved changes must be cleaned before checking out"}
+var ErrForceForbidden = Error{"the branch is protected from force pushes"}
+
+// NonFastForwardError is returned when the versions a transfer would move a branch to do not follow the version
+// it is at, so the versions only the branch has would be lost, unless the transfer is forced.
+// A forced transfer with a lease returns it when the branch is not at the version of the lease.
+type NonFastForwardError struct {
+	Branch string
+}
+
+func (e NonFastForwardError) Error() string {
+	return "non-fast-forward update of branch: " + e.Branch
+}
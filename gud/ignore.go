@@ -0,0 +1,368 @@
+package gud
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const IgnoreFileName = ".gudignore"
+const defaultExcludesFile = ".gudExcludes"
+
+// IgnoreRule is a single pattern of an ignore file.
+type IgnoreRule struct {
+	Source  string // the ignore file the rule was read from
+	Line    int
+	Pattern string // the pattern as written in the file
+
+	base     string   // the directory the pattern is relative to, "" for the whole project
+	segments []string // the pattern split on slashes
+	negate   bool     // the pattern starts with ! and un-ignores what it matches
+	dirOnly  bool     // the pattern ends with / and matches only directories
+	anchored bool     // the pattern contains a slash, so it matches whole paths and not only names
+}
+
+// Negates returns true if the rule un-ignores the paths it matches.
+func (r IgnoreRule) Negates() bool {
+	return r.negate
+}
+
+// ignorer decides which untracked files are ignored.
+// Every directory may have an ignore file, with patterns relative to it that take precedence
+// over the ones of its parents, and all of them take precedence over the global excludes file.
+type ignorer struct {
+	root   string
+	global []IgnoreRule
+	dirs   map[string][]IgnoreRule // the rules of each directory, read when first needed
+	parent map[string]*IgnoreRule  // the rules that decided each directory
+}
+
+// CheckIgnore returns the rule that decides whether a path is ignored, or nil if no rule matches it.
+// The path is ignored if a rule was found and it does not negate.
+func (p Project) CheckIgnore(path string) (*IgnoreRule, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(p.Path, abs)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	ig, err := p.loadIgnorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return ig.match(rel, info != nil && info.IsDir())
+}
+
+// DeletedFiles returns the files and directories under a path that rm deletes from the working tree after
+// removing them from the index, which must be called before. They are the tracked files and the untracked
+// files that are not ignored, so ignored files in removed directories are kept.
+// Directories are returned before the files and directories they hold.
+func (p Project) DeletedFiles(path string) (files, dirs []string, err error) {
+	tracked, err := p.trackedPaths()
+	if err != nil {
+		return nil, nil, err
+	}
+	ig, err := p.loadIgnorer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := path
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == root {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p.Path, abs)
+		if err != nil {
+			return err
+		}
+
+		if !tracked[rel] {
+			ignored, err := ig.ignored(rel, info.IsDir())
+			if err != nil {
+				return err
+			}
+			if ignored && info.IsDir() {
+				return filepath.SkipDir
+			}
+			if ignored {
+				return nil
+			}
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, dirs, err
+}
+
+// trackedPaths returns the files of the current version and of the index, with the directories that hold them.
+func (p Project) trackedPaths() (map[string]bool, error) {
+	tracked := make(map[string]bool)
+	mark := func(relPath string) {
+		for ; relPath != "." && !tracked[relPath]; relPath = filepath.Dir(relPath) {
+			tracked[relPath] = true
+		}
+	}
+
+	entries, err := loadIndex(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		mark(entry.Path)
+	}
+
+	hash, err := p.CurrentHash()
+	if err != nil || hash == nil {
+		return tracked, err
+	}
+	version, err := loadVersion(p.gudPath, *hash)
+	if err != nil {
+		return nil, err
+	}
+	root, err := loadTree(p.gudPath, version.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+	err = walkObjects(p.gudPath, "", root, func(relPath string, obj object) error {
+		if obj.Type == typeBlob {
+			mark(relPath)
+		}
+		return nil
+	})
+	return tracked, err
+}
+
+func (p Project) loadIgnorer() (*ignorer, error) {
+	var gConf GlobalConfig
+	err := LoadConfig(&gConf, gConf.GetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	excludesPath := gConf.ExcludesFile
+	if excludesPath == "" {
+		excludesPath = filepath.Join(filepath.Dir(gConf.GetPath()), defaultExcludesFile)
+	}
+
+	global, err := readIgnoreFile(excludesPath, excludesPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ignorer{
+		root:   p.Path,
+		global: global,
+		dirs:   make(map[string][]IgnoreRule),
+		parent: make(map[string]*IgnoreRule),
+	}, nil
+}
+
+// ignored returns true if an untracked path should not be reported or added.
+// A nil ignorer ignores nothing.
+func (ig *ignorer) ignored(relPath string, isDir bool) (bool, error) {
+	if ig == nil {
+		return false, nil
+	}
+
+	rule, err := ig.match(relPath, isDir)
+	if err != nil {
+		return false, err
+	}
+	return rule != nil && !rule.negate, nil
+}
+
+// match returns the last rule that matches a path, which decides whether it is ignored.
+func (ig *ignorer) match(relPath string, isDir bool) (*IgnoreRule, error) {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." || relPath == DefaultPath || strings.HasPrefix(relPath, DefaultPath+"/") {
+		return nil, nil
+	}
+
+	// Nothing inside an ignored directory can be un-ignored
+	if dir := path.Dir(relPath); dir != "." {
+		rule, ok := ig.parent[dir]
+		if !ok {
+			var err error
+			rule, err = ig.match(dir, true)
+			if err != nil {
+				return nil, err
+			}
+			ig.parent[dir] = rule
+		}
+		if rule != nil && !rule.negate {
+			return rule, nil
+		}
+	}
+
+	var res *IgnoreRule
+	check := func(rules []IgnoreRule) {
+		for i := range rules {
+			if rules[i].matches(relPath, isDir) {
+				res = &rules[i]
+			}
+		}
+	}
+
+	// The ignore files from the root of the project down to the directory of the path
+	dirs := []string{"."}
+	for i, c := range relPath {
+		if c == '/' {
+			dirs = append(dirs, relPath[:i])
+		}
+	}
+
+	check(ig.global)
+	for _, dir := range dirs {
+		rules, err := ig.rules(dir)
+		if err != nil {
+			return nil, err
+		}
+		check(rules)
+	}
+
+	return res, nil
+}
+
+func (ig *ignorer) rules(dir string) ([]IgnoreRule, error) {
+	rules, ok := ig.dirs[dir]
+	if ok {
+		return rules, nil
+	}
+
+	base := dir
+	if base == "." {
+		base = ""
+	}
+
+	source := filepath.Join(filepath.FromSlash(dir), IgnoreFileName)
+	rules, err := readIgnoreFile(filepath.Join(ig.root, source), source, base)
+	if err != nil {
+		return nil, err
+	}
+
+	ig.dirs[dir] = rules
+	return rules, nil
+}
+
+func (r IgnoreRule) matches(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	if r.base != "" {
+		if !strings.HasPrefix(relPath, r.base+"/") {
+			return false
+		}
+		relPath = relPath[len(r.base)+1:]
+	}
+
+	parts := strings.Split(relPath, "/")
+	if !r.anchored {
+		parts = parts[len(parts)-1:]
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches a path to a pattern, one name at a time. ** matches any number of names.
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], parts[0])
+	return matched && matchSegments(pattern[1:], parts[1:])
+}
+
+// readIgnoreFile reads the rules of an ignore file, which match paths relative to base.
+// A missing file has no rules.
+func readIgnoreFile(path, source, base string) ([]IgnoreRule, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []IgnoreRule
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		rule, ok := parseIgnoreRule(scanner.Text())
+		if ok {
+			rule.Source = source
+			rule.Line = lineNum
+			rule.base = base
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+func parseIgnoreRule(line string) (IgnoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return IgnoreRule{}, false
+	}
+
+	rule := IgnoreRule{Pattern: line}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return IgnoreRule{}, false
+	}
+
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
@@ -0,0 +1,113 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_Ignore(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	for path, content := range map[string]string{
+		IgnoreFileName:                           "build/\n*.log\n!keep.log\n/root.txt\n",
+		filepath.Join("src", IgnoreFileName):     "gen/**/*.go\n",
+		filepath.Join("build", "out"):            "ignored",
+		filepath.Join("src", "main.go"):          "added",
+		filepath.Join("src", "gen", "a", "x.go"): "ignored",
+		filepath.Join("src", "root.txt"):         "added",
+		"root.txt":                               "ignored",
+		"debug.log":                              "ignored",
+		"keep.log":                               "added",
+	} {
+		path = filepath.Join(testDir, path)
+		_ = os.MkdirAll(filepath.Dir(path), dirPerm)
+		_ = ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	err := p.AddAll()
+	if err != nil {
+		t.Fatal("failed to add:", err)
+	}
+
+	index, _ := loadIndex(p.gudPath)
+	var added []string
+	for _, entry := range index {
+		added = append(added, entry.Path)
+	}
+	expected := []string{
+		IgnoreFileName,
+		"keep.log",
+		filepath.Join("src", IgnoreFileName),
+		filepath.Join("src", "main.go"),
+		filepath.Join("src", "root.txt"),
+	}
+	if len(added) != len(expected) {
+		t.Fatal("wrong files added:", added)
+	}
+	for i := range expected {
+		if added[i] != expected[i] {
+			t.Fatal("wrong files added:", added)
+		}
+	}
+
+	_, _ = p.Save("ignore")
+	err = p.assertNoChanges()
+	if err != nil {
+		t.Error("ignored files are reported:", err)
+	}
+
+	err = p.Add(filepath.Join(testDir, "debug.log"))
+	if err == nil {
+		t.Error("added an ignored file")
+	}
+
+	rule, err := p.CheckIgnore(filepath.Join(testDir, "build", "out"))
+	if err != nil || rule == nil || rule.Pattern != "build/" || rule.Line != 1 {
+		t.Error("wrong rule for a file in an ignored directory:", rule, err)
+	}
+	rule, _ = p.CheckIgnore(filepath.Join(testDir, "keep.log"))
+	if rule == nil || !rule.Negates() {
+		t.Error("wrong rule for a negated file:", rule)
+	}
+}
+
+func TestProject_DeletedFiles(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	_ = os.Mkdir(filepath.Join(testDir, "dir"), dirPerm)
+	_ = ioutil.WriteFile(filepath.Join(testDir, "dir", "tracked.log"), []byte("tracked"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(testDir, "dir", "file"), []byte("tracked"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("tracked")
+
+	// The tracked log file is ignored once it is in the project
+	_ = ioutil.WriteFile(filepath.Join(testDir, IgnoreFileName), []byte("*.log\n"), 0644)
+
+	_ = ioutil.WriteFile(filepath.Join(testDir, "dir", "untracked.log"), []byte("ignored"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(testDir, "dir", "untracked"), []byte("untracked"), 0644)
+
+	files, dirs, err := p.DeletedFiles(filepath.Join(testDir, "dir"))
+	if err != nil {
+		t.Fatal("failed to list the deleted files:", err)
+	}
+	expected := []string{
+		filepath.Join(testDir, "dir", "file"),
+		filepath.Join(testDir, "dir", "tracked.log"),
+		filepath.Join(testDir, "dir", "untracked"),
+	}
+	if len(files) != len(expected) {
+		t.Fatal("wrong files deleted:", files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Fatal("wrong files deleted:", files)
+		}
+	}
+	if len(dirs) != 1 || dirs[0] != filepath.Join(testDir, "dir") {
+		t.Error("wrong directories deleted:", dirs)
+	}
+}
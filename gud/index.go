@@ -2,6 +2,7 @@ package gud
 
 import (
 	"encoding/gob"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -17,14 +18,24 @@ const (
 	StateRemoved
 	StateModified
 	StateConflict
+	StateRenamed   // only reported, for a file that was moved from another path
+	StateUnchanged // only used by FileStatus, for the side of a file that has no changes
 )
 
 type indexEntry struct {
-	Path  string
-	Hash  ObjectHash
-	State FileState
-	Mtime time.Time
-	Size  int64
+	Path   string
+	Hash   ObjectHash
+	State  FileState
+	Mtime  time.Time
+	Size   int64
+	Shared bool // the object belongs to a saved version and must outlive the entry
+
+	// The versions of a conflicting file, nil where the file does not exist
+	Base, Ours, Theirs *ObjectHash
+
+	// The path and the saved blob of a file that was moved here, empty if it was not moved
+	From     string
+	FromHash ObjectHash
 }
 
 type indexFile struct {
@@ -45,6 +56,16 @@ func (p Project) Add(paths ...string) error {
 		return err
 	}
 
+	s, err := p.loadScan()
+	if err != nil {
+		return err
+	}
+
+	pool, err := p.workerPool()
+	if err != nil {
+		return err
+	}
+
 	for _, path := range paths {
 		abs, err := filepath.Abs(path)
 		if err != nil {
@@ -64,6 +85,16 @@ func (p Project) Add(paths ...string) error {
 			return err
 		}
 
+		if _, staged := findEntry(entries, rel); prev == nil && !staged {
+			ignored, err := s.ignored(rel, info.IsDir())
+			if err != nil {
+				return err
+			}
+			if ignored {
+				return Error{"the path is ignored: " + path}
+			}
+		}
+
 		if info.IsDir() {
 			var prevTree tree
 			if prev != nil && prev.Type == typeTree {
@@ -73,24 +104,27 @@ func (p Project) Add(paths ...string) error {
 				}
 			}
 
+			var changes []fileChange
 			err = p.compareTree(
-				rel, prevTree, entries, // TODO: might need to replace entries with nil
+				rel, prevTree, entries, s, // TODO: might need to replace entries with nil
 				func(relPath string, state FileState, hash *ObjectHash, isDir bool) error {
 					if !isDir {
-						entries, err = p.addIndexEntry(relPath, state, entries)
-						if err != nil {
-							return err
-						}
+						changes = append(changes, fileChange{relPath, state})
 					}
 					return nil
 				})
 			if err != nil {
 				return err
 			}
+
+			entries, err = p.addIndexEntries(changes, entries, s.attributer(), pool)
+			if err != nil {
+				return err
+			}
 		} else {
 			var state FileState
 			if prev != nil && prev.Type != typeTree {
-				unchanged, err := p.compareToObject(rel, prev.Hash)
+				unchanged, err := p.sameAsObject(s, rel, prev.Hash)
 				if err != nil {
 					return err
 				}
@@ -112,13 +146,22 @@ func (p Project) Add(paths ...string) error {
 				state = StateNew
 			}
 
-			entries, err = p.addIndexEntry(rel, state, entries)
+			hash, err := p.createBlob(rel, s.attributer())
+			if err != nil {
+				return err
+			}
+			entries, err = p.addIndexEntry(rel, state, hash, entries)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	err = p.dumpScan(s)
+	if err != nil {
+		return err
+	}
+
 	return dumpIndex(p.gudPath, entries)
 }
 
@@ -153,13 +196,16 @@ func (p Project) Remove(paths ...string) error {
 			return err
 		}
 		if prev == nil {
-			if _, found := findEntry(entries, rel); !found {
+			ind, found := findEntry(entries, rel)
+			if !found {
 				return Error{"untracked file: " + path}
 			}
-			entries, err = p.addIndexEntry(rel, StateRemoved, entries)
+			err = removeEntry(p.gudPath, entries[ind])
 			if err != nil {
 				return err
 			}
+			copy(entries[ind:], entries[ind+1:])
+			entries = entries[:len(entries)-1]
 		} else {
 			if prev.Type == typeTree {
 				entries, err = p.removeDirFromIndex(rel, prev.Hash, entries)
@@ -167,7 +213,7 @@ func (p Project) Remove(paths ...string) error {
 					return err
 				}
 			} else {
-				entries, err = p.addIndexEntry(rel, StateRemoved, entries)
+				entries, err = p.addIndexEntry(rel, StateRemoved, nil, entries)
 				if err != nil {
 					return err
 				}
@@ -186,7 +232,7 @@ func (p Project) removeDirFromIndex(relPath string, prevHash ObjectHash, index [
 
 	err = walkObjects(p.gudPath, relPath, prevTree, func(relPath string, obj object) error {
 		if obj.Type != typeTree {
-			index, err = p.addIndexEntry(relPath, StateRemoved, index)
+			index, err = p.addIndexEntry(relPath, StateRemoved, nil, index)
 			if err != nil {
 				return err
 			}
@@ -200,7 +246,39 @@ func (p Project) removeDirFromIndex(relPath string, prevHash ObjectHash, index [
 	return index, err
 }
 
-func (p Project) addIndexEntry(relPath string, state FileState, index []indexEntry) ([]indexEntry, error) {
+// fileChange is a change to a file that is about to be staged.
+type fileChange struct {
+	relPath string
+	state   FileState
+}
+
+// addIndexEntries stages changes to files, saving their contents concurrently.
+func (p Project) addIndexEntries(changes []fileChange, index []indexEntry, at *attributer, pool *workerPool) ([]indexEntry, error) {
+	hashes := make([]*ObjectHash, len(changes))
+	err := pool.each(len(changes), func(i int) error {
+		if changes[i].state == StateRemoved {
+			return nil
+		}
+		var err error
+		hashes[i], err = p.createBlob(changes[i].relPath, at)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, change := range changes {
+		index, err = p.addIndexEntry(change.relPath, change.state, hashes[i], index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return index, nil
+}
+
+// addIndexEntry stages a change to a file, whose contents were saved as the blob hash unless it was removed.
+func (p Project) addIndexEntry(relPath string, state FileState, hash *ObjectHash, index []indexEntry) ([]indexEntry, error) {
 	var mtime time.Time
 	var n int64
 	if state != StateRemoved {
@@ -218,23 +296,19 @@ func (p Project) addIndexEntry(relPath string, state FileState, index []indexEnt
 		prevEntry := index[ind]
 		if prevEntry.State != StateRemoved {
 			if state == StateRemoved {
-				err := removeEntry(p.gudPath, prevEntry.Hash)
+				err := removeEntry(p.gudPath, prevEntry)
 				if err != nil {
 					return nil, err
 				}
-				copy(index[ind:], index[ind+1:])
-				return index[:len(index)-1], nil
-			}
-
-			if prevEntry.Mtime.Before(mtime) {
-				unchanged, err := p.compareToObject(relPath, prevEntry.Hash)
-				if err != nil {
-					return nil, err
+				if prevEntry.State == StateNew {
+					copy(index[ind:], index[ind+1:])
+					return index[:len(index)-1], nil
 				}
-				if unchanged {
+			} else if prevEntry.State != StateConflict {
+				if prevEntry.Hash == *hash { // blobs of the same file have the same hash only if they are the same
 					return index, nil
 				}
-				err = removeEntry(p.gudPath, prevEntry.Hash)
+				err := removeEntry(p.gudPath, prevEntry)
 				if err != nil {
 					return nil, err
 				}
@@ -245,21 +319,22 @@ func (p Project) addIndexEntry(relPath string, state FileState, index []indexEnt
 		copy(index[ind+1:], index[ind:])
 	}
 
-	hash := &nullHash
-	if state != StateRemoved {
-		var err error
-		hash, err = p.createBlob(relPath)
-		if err != nil {
-			return nil, err
-		}
+	var from string
+	var fromHash ObjectHash
+	if state == StateRemoved {
+		hash = &nullHash
+	} else if found { // a moved file stays moved when it is added again
+		from, fromHash = index[ind].From, index[ind].FromHash
 	}
 
 	index[ind] = indexEntry{
-		Path:  relPath,
-		Hash:  *hash,
-		State: state,
-		Mtime: mtime,
-		Size:  n,
+		Path:     relPath,
+		Hash:     *hash,
+		State:    state,
+		Mtime:    mtime,
+		Size:     n,
+		From:     from,
+		FromHash: fromHash,
 	}
 	return index, nil
 }
@@ -294,25 +369,17 @@ func loadIndex(gudPath string) ([]indexEntry, error) {
 }
 
 func dumpIndex(gudPath string, entries []indexEntry) error {
-	file, err := os.Create(filepath.Join(gudPath, indexFilePath))
-	if err != nil {
-		return err
-	}
-
-	err = gob.NewEncoder(file).Encode(indexFile{
-		Version: GetVersion(),
-		Entries: entries,
+	return writeMetadata(gudPath, indexFilePath, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(indexFile{
+			Version: GetVersion(),
+			Entries: entries,
+		})
 	})
-	if err != nil {
-		return err
-	}
-
-	return file.Close()
 }
 
-func removeEntry(gudPath string, hash ObjectHash) error {
-	if hash != nullHash {
-		return os.Remove(objectPath(gudPath, hash))
+func removeEntry(gudPath string, entry indexEntry) error {
+	if entry.Hash != nullHash && !entry.Shared {
+		return removeObject(gudPath, entry.Hash)
 	}
 	return nil
 }
@@ -325,3 +392,14 @@ func findEntry(entries []indexEntry, relPath string) (int, bool) {
 
 	return ind, ind < l && relPath == entries[ind].Path
 }
+
+// setIndexEntry adds entry to the index, or replaces the entry with the same path.
+func setIndexEntry(index []indexEntry, entry indexEntry) []indexEntry {
+	ind, found := findEntry(index, entry.Path)
+	if !found {
+		index = append(index, indexEntry{})
+		copy(index[ind+1:], index[ind:])
+	}
+	index[ind] = entry
+	return index
+}
@@ -0,0 +1,123 @@
+package gud
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const lockFilePath = "lock"
+
+// lockInfo tells which process holds the lock of a project.
+type lockInfo struct {
+	PID  int
+	Host string
+	Time time.Time
+}
+
+// stale returns true if the process that took the lock is gone.
+// Locks of other hosts are never known to be stale.
+func (info lockInfo) stale(host string) bool {
+	return info.Host == host && !processExists(info.PID)
+}
+
+// lock locks the project for the current process, taking over a stale lock.
+func (p Project) lock() error {
+	holder, err := p.tryLock()
+	if err != nil {
+		return err
+	}
+	if holder != nil {
+		return Error{fmt.Sprintf("the project is locked by process %d on %s since %s",
+			holder.PID, holder.Host, holder.Time.Format("2006-01-02 15:04:05"))}
+	}
+	return nil
+}
+
+// tryLock locks the project for the current process, or returns the lock of the process that holds it.
+func (p Project) tryLock() (*lockInfo, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	info := lockInfo{PID: os.Getpid(), Host: host, Time: time.Now()}
+	path := filepath.Join(p.gudPath, lockFilePath)
+
+	for {
+		// The lock is written whole and then linked into place, which fails if it is already held
+		file, err := createAtomic(p.gudPath, path)
+		if err != nil {
+			return nil, err
+		}
+		err = gob.NewEncoder(file).Encode(info)
+		cerr := file.Close()
+		if err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Link(file.Name(), path)
+		}
+		_ = os.Remove(file.Name())
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		holder, err := loadLock(path)
+		if os.IsNotExist(err) { // released in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !holder.stale(host) {
+			return holder, nil
+		}
+
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// unlock releases the lock of the current process.
+func (p Project) unlock() error {
+	path := filepath.Join(p.gudPath, lockFilePath)
+	holder, err := loadLock(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	if holder.PID != os.Getpid() || holder.Host != host {
+		return Error{"the project is locked by another process"}
+	}
+	return os.Remove(path)
+}
+
+func loadLock(path string) (*lockInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var info lockInfo
+	err = gob.NewDecoder(file).Decode(&info)
+	if err == io.EOF {
+		return nil, Error{"the lock of the project is corrupted"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
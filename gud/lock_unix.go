@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package gud
+
+import "syscall"
+
+// processExists returns true if a process with the id is running, which it is
+// if it can be signaled, or if it belongs to another user.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package gud
+
+import "syscall"
+
+const processQueryLimitedInformation = 0x1000
+const stillActive = 259
+
+// processExists returns true if a process with the id is running.
+func processExists(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	err = syscall.GetExitCodeProcess(handle, &code)
+	return err != nil || code == stillActive
+}
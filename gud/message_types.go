@@ -53,8 +53,21 @@ type Issue struct {
 
 type PullRequest struct {
 	Issue
-	From string `json:"from"`
-	To   string `json:"to"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Mergeable bool   `json:"mergeable"`
+}
+
+type MergePrRequest struct {
+	NoFastForward   bool   `json:"noFastForward"`
+	FastForwardOnly bool   `json:"fastForwardOnly"`
+	Squash          bool   `json:"squash"`
+	Message         string `json:"message"`
+}
+
+// BranchProtection is how a branch in a server is protected from pushes.
+type BranchProtection struct {
+	ForbidForce bool `json:"forbidForce"`
 }
 
 type UpdateIssueRequest struct {
@@ -70,6 +83,7 @@ type Job struct {
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // identifies errors that clients handle, like NonFastForwardCode
 }
 
 type MultiErrorResponse struct {
@@ -77,3 +91,12 @@ type MultiErrorResponse struct {
 }
 
 const PasswordLenMin = 8
+
+// ResumeHeader and SkipHeader are sent with a branch by a server that has an interrupted push of it,
+// with the ID of the transfer and the number of objects it received, for the client to resume it.
+const ResumeHeader = "X-Gud-Resume"
+const SkipHeader = "X-Gud-Skip"
+
+// NonFastForwardCode is the code of the error a server reports, with the status 409 Conflict,
+// for a push or a pull that would lose versions of a branch, see NonFastForwardError.
+const NonFastForwardCode = "non-fast-forward"
@@ -0,0 +1,334 @@
+package gud
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Move moves a file or a directory in the working tree, and stages the move of the files it has.
+// If dst is a directory, src is moved into it.
+// The moved files keep the blobs they were saved with in the index, so the changes show them
+// as renamed instead of removed and added.
+func (p Project) Move(src, dst string) error {
+	srcRel, err := p.relPath(src)
+	if err != nil {
+		return err
+	}
+	dstRel, err := p.relPath(dst)
+	if err != nil {
+		return err
+	}
+	if srcRel == "." {
+		return Error{"cannot move the project"}
+	}
+
+	if info, err := os.Stat(filepath.Join(p.Path, dstRel)); err == nil && info.IsDir() {
+		dstRel = filepath.Join(dstRel, filepath.Base(srcRel))
+	}
+	if dstRel == srcRel || strings.HasPrefix(dstRel, srcRel+string(os.PathSeparator)) {
+		return Error{"cannot move a path into itself: " + src}
+	}
+	if _, err := os.Lstat(filepath.Join(p.Path, dstRel)); err == nil {
+		return Error{"the destination already exists: " + dst}
+	}
+
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return err
+	}
+	current, err := p.CurrentHash()
+	if err != nil {
+		return err
+	}
+
+	files, err := p.trackedFiles(srcRel, index, *current)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return Error{"untracked file: " + src}
+	}
+	for _, file := range files {
+		if ind, found := findEntry(index, file); found && index[ind].State == StateConflict {
+			return Error{"the file has conflicts: " + file}
+		}
+		if _, err := os.Lstat(filepath.Join(p.Path, file)); err != nil {
+			return err
+		}
+	}
+
+	dstPath := filepath.Join(p.Path, dstRel)
+	err = os.MkdirAll(filepath.Dir(dstPath), dirPerm)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(filepath.Join(p.Path, srcRel), dstPath)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		rel, err := filepath.Rel(srcRel, file)
+		if err != nil {
+			return err
+		}
+		index, err = p.moveEntry(file, filepath.Join(dstRel, rel), index, *current)
+		if err != nil {
+			return err
+		}
+	}
+
+	return dumpIndex(p.gudPath, index)
+}
+
+// trackedFiles returns the files at or under relPath that are saved in the version and not removed,
+// or staged, sorted by path.
+func (p Project) trackedFiles(relPath string, index []indexEntry, version ObjectHash) ([]string, error) {
+	tracked, err := p.savedFiles(relPath, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range index {
+		if !underPath(entry.Path, relPath) {
+			continue
+		}
+		if entry.State == StateRemoved {
+			delete(tracked, entry.Path)
+		} else {
+			tracked[entry.Path] = object{}
+		}
+	}
+
+	files := make([]string, 0, len(tracked))
+	for file := range tracked {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// savedFiles returns the blobs of the files at or under relPath in a version, by their paths.
+func (p Project) savedFiles(relPath string, version ObjectHash) (map[string]object, error) {
+	files := make(map[string]object)
+	obj, err := p.findObject(relPath, version)
+	if err != nil || obj == nil {
+		return files, err
+	}
+	if obj.Type == typeBlob {
+		files[relPath] = *obj
+		return files, nil
+	}
+
+	root, err := loadTree(p.gudPath, obj.Hash)
+	if err != nil {
+		return nil, err
+	}
+	err = walkObjects(p.gudPath, relPath, root, func(file string, obj object) error {
+		if obj.Type == typeBlob {
+			files[file] = obj
+		}
+		return nil
+	})
+	return files, err
+}
+
+// underPath returns true if a path is dir or is inside it.
+func underPath(relPath, dir string) bool {
+	return dir == "." || relPath == dir || strings.HasPrefix(relPath, dir+string(os.PathSeparator))
+}
+
+// moveEntry stages the move of a tracked file that was moved in the working tree.
+func (p Project) moveEntry(from, to string, index []indexEntry, version ObjectHash) ([]indexEntry, error) {
+	saved, err := p.findObject(from, version)
+	if err != nil {
+		return nil, err
+	}
+	if saved != nil && saved.Type != typeBlob {
+		saved = nil
+	}
+
+	// The file is moved with its staged content, and remembers where it was saved
+	var content, origin string
+	var originHash ObjectHash
+	ind, staged := findEntry(index, from)
+	if staged {
+		entry := index[ind]
+		content, err = readBlob(p.gudPath, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		origin, originHash = entry.From, entry.FromHash
+
+		err = removeEntry(p.gudPath, entry)
+		if err != nil {
+			return nil, err
+		}
+		copy(index[ind:], index[ind+1:])
+		index = index[:len(index)-1]
+	} else {
+		content, err = readBlob(p.gudPath, saved.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if origin == "" && saved != nil {
+		origin, originHash = from, saved.Hash
+	}
+	if saved != nil {
+		index = setIndexEntry(index, indexEntry{Path: from, Hash: nullHash, State: StateRemoved})
+	}
+
+	prev, err := p.findObject(to, version)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && prev.Type != typeBlob {
+		return nil, Error{"the destination is a saved directory: " + to}
+	}
+	if ind, found := findEntry(index, to); found {
+		err = removeEntry(p.gudPath, index[ind])
+		if err != nil {
+			return nil, err
+		}
+		copy(index[ind:], index[ind+1:])
+		index = index[:len(index)-1]
+	}
+
+	hash, err := writeBlob(p.gudPath, to, strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	state := StateNew
+	if prev != nil {
+		if *hash == prev.Hash { // moved back to where it was saved, unchanged
+			return index, nil
+		}
+		state = StateModified
+	}
+	if origin == to {
+		origin, originHash = "", nullHash
+	}
+
+	info, err := os.Stat(filepath.Join(p.Path, to))
+	if err != nil {
+		return nil, err
+	}
+
+	return setIndexEntry(index, indexEntry{
+		Path:     to,
+		Hash:     *hash,
+		State:    state,
+		Mtime:    info.ModTime(),
+		Size:     info.Size(),
+		From:     origin,
+		FromHash: originHash,
+	}), nil
+}
+
+// renames returns the paths that the moved files of the index were moved from, by the paths they were moved to.
+// A file is renamed only while the file it was moved from is removed, otherwise it was copied.
+func renames(index []indexEntry) map[string]string {
+	res := make(map[string]string)
+	sources := make(map[string]bool)
+	for _, entry := range index {
+		if entry.From == "" || entry.State == StateRemoved || entry.State == StateConflict || sources[entry.From] {
+			continue
+		}
+		if ind, found := findEntry(index, entry.From); found && index[ind].State == StateRemoved {
+			res[entry.Path] = entry.From
+			sources[entry.From] = true
+		}
+	}
+	return res
+}
+
+// indexRenames returns the renames of the index, as they are saved in a version.
+func indexRenames(index []indexEntry) []Rename {
+	var res []Rename
+	moved := renames(index)
+	for _, entry := range index { // in the order of the index
+		if from, ok := moved[entry.Path]; ok {
+			res = append(res, Rename{From: from, To: entry.Path})
+		}
+	}
+	return res
+}
+
+// versionRenames returns the files that were moved from the version from to the version to,
+// by the paths they were moved to. It knows only the moves of the versions between them,
+// so it returns nothing if to does not descend from from.
+func (p Project) versionRenames(from, to ObjectHash) (map[string]string, error) {
+	res := make(map[string]string)
+	var versions []Version
+	for hash := to; hash != from; {
+		version, err := loadVersion(p.gudPath, hash)
+		if err != nil {
+			return nil, err
+		}
+		if !version.HasPrev() {
+			return res, nil
+		}
+		versions = append(versions, *version)
+		hash = *version.prev
+	}
+
+	// From the latest version back, so every file is followed to where it was first moved from
+	for _, version := range versions {
+		for _, rename := range version.renames {
+			chained := false
+			for dst, src := range res {
+				if src == rename.To {
+					res[dst] = rename.From
+					chained = true
+				}
+			}
+			if !chained {
+				res[rename.To] = rename.From
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// pairRenames joins the removal and the addition of every file that was renamed to one diff.
+func pairRenames(diffs []FileDiff, renames map[string]string) []FileDiff {
+	if len(renames) == 0 {
+		return diffs
+	}
+
+	removed := make(map[string]int)
+	for i, diff := range diffs {
+		if diff.State == StateRemoved {
+			removed[diff.Path] = i
+		}
+	}
+
+	paired := make(map[int]bool)
+	for i := range diffs {
+		from, ok := renames[diffs[i].Path]
+		if !ok || diffs[i].State != StateNew {
+			continue
+		}
+		j, ok := removed[from]
+		if !ok || paired[j] {
+			continue
+		}
+		diffs[i].State = StateRenamed
+		diffs[i].From = from
+		diffs[i].Old = diffs[j].Old
+		paired[j] = true
+	}
+
+	res := diffs[:0]
+	for i, diff := range diffs {
+		if !paired[i] {
+			res = append(res, diff)
+		}
+	}
+	return res
+}
@@ -0,0 +1,70 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_Move(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	_ = os.Mkdir(filepath.Join(testDir, "dir"), dirPerm)
+	_ = ioutil.WriteFile(filepath.Join(testDir, "dir", "inner"), []byte("inner\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(testDir, testFile), []byte("data\n"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("first")
+	first, _ := p.CurrentHash()
+
+	err := p.Move(filepath.Join(testDir, testFile), filepath.Join(testDir, "moved"))
+	if err != nil {
+		t.Fatal("failed to move the file:", err)
+	}
+	err = p.Move(filepath.Join(testDir, "dir"), filepath.Join(testDir, "other"))
+	if err != nil {
+		t.Fatal("failed to move the directory:", err)
+	}
+	if _, err = os.Stat(filepath.Join(testDir, "other", "inner")); err != nil {
+		t.Error("the directory was not moved:", err)
+	}
+
+	status, err := p.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"moved": testFile, filepath.Join("other", "inner"): filepath.Join("dir", "inner")}
+	if len(status.Files) != len(expected) {
+		t.Fatal("unexpected changes:", status.Files)
+	}
+	for _, file := range status.Files {
+		if file.Staged != StateRenamed || file.From != expected[file.Path] || file.Working != StateUnchanged {
+			t.Errorf("unexpected change %+v", file)
+		}
+	}
+
+	diffs, _ := p.DiffStaged()
+	if len(diffs) != 2 || diffs[0].State != StateRenamed || diffs[0].Old != "data\n" || diffs[0].New != "data\n" {
+		t.Error("the staged move was not diffed as a rename:", diffs)
+	}
+
+	version, err := p.Save("move")
+	if err != nil {
+		t.Fatal("failed to save the move:", err)
+	}
+	if len(version.Renames()) != 2 {
+		t.Error("the renames were not saved:", version.Renames())
+	}
+	current, _ := p.CurrentHash()
+
+	diffs, _ = p.DiffVersions(*first, *current)
+	if len(diffs) != 2 || diffs[0].State != StateRenamed || diffs[0].From != testFile {
+		t.Error("the saved move was not diffed as a rename:", diffs)
+	}
+
+	status, _ = p.Status()
+	if len(status.Files) != 0 {
+		t.Error("changes left after saving the move:", status.Files)
+	}
+}
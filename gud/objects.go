@@ -60,21 +60,32 @@ func (t tree) Swap(i, j int) {
 
 // Version is a representation of a project version.
 type Version struct {
-	Message  string
-	Author   string
-	Time     time.Time
-	TreeHash ObjectHash
-	prev     *ObjectHash
-	merged   *ObjectHash
+	Message   string
+	Author    string
+	Committer string // set when the version was saved by someone other than its author
+	Time      time.Time
+	TreeHash  ObjectHash
+	prev      *ObjectHash
+	merged    *ObjectHash
+	picked    *ObjectHash
+	renames   []Rename
+}
+
+// Rename is a file that a version moved from one path to another.
+type Rename struct {
+	From, To string
 }
 
 type gobVersion struct {
-	Message  string
-	Author   string
-	Time     time.Time
-	TreeHash ObjectHash
-	Prev     *ObjectHash
-	Merged   *ObjectHash
+	Message   string
+	Author    string
+	Committer string
+	Time      time.Time
+	TreeHash  ObjectHash
+	Prev      *ObjectHash
+	Merged    *ObjectHash
+	Picked    *ObjectHash
+	Renames   []Rename
 }
 
 func init() {
@@ -86,23 +97,29 @@ func init() {
 
 func versionToGob(v Version) gobVersion {
 	return gobVersion{
-		Message:  v.Message,
-		Author:   v.Author,
-		Time:     v.Time,
-		TreeHash: v.TreeHash,
-		Prev:     v.prev,
-		Merged:   v.merged,
+		Message:   v.Message,
+		Author:    v.Author,
+		Committer: v.Committer,
+		Time:      v.Time,
+		TreeHash:  v.TreeHash,
+		Prev:      v.prev,
+		Merged:    v.merged,
+		Picked:    v.picked,
+		Renames:   v.renames,
 	}
 }
 
 func gobToVersion(v gobVersion) Version {
 	return Version{
-		Message:  v.Message,
-		Author:   v.Author,
-		Time:     v.Time,
-		TreeHash: v.TreeHash,
-		prev:     v.Prev,
-		merged:   v.Merged,
+		Message:   v.Message,
+		Author:    v.Author,
+		Committer: v.Committer,
+		Time:      v.Time,
+		TreeHash:  v.TreeHash,
+		prev:      v.Prev,
+		merged:    v.Merged,
+		picked:    v.Picked,
+		renames:   v.Renames,
 	}
 }
 
@@ -115,6 +132,17 @@ func (v Version) IsMergeVersion() bool {
 	return v.merged != nil
 }
 
+// Renames returns the files the version moved.
+func (v Version) Renames() []Rename {
+	return v.renames
+}
+
+// PickedFrom returns the hash of the version this version was cherry-picked from,
+// or nil if it was not cherry-picked.
+func (v Version) PickedFrom() *ObjectHash {
+	return v.picked
+}
+
 type dirStructure struct {
 	Name    string
 	Objects tree
@@ -130,41 +158,75 @@ func objectPath(gudPath string, hash ObjectHash) string {
 }
 
 func (p Project) saveVersion(message, branch string, tree ObjectHash, prev, merged *ObjectHash) (*Version, error) {
-	var gConf GlobalConfig
-	err := LoadConfig(&gConf, gConf.GetPath())
-	if err != nil {
-		return nil, err
-	}
-
-	v := Version{
+	hash, v, err := p.writeVersion(Version{
 		Message:  message,
-		Author:   gConf.Name,
-		Time:     time.Now(),
 		TreeHash: tree,
 		prev:     prev,
 		merged:   merged,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	obj, err := createVersion(p.gudPath, v)
+	err = dumpBranch(p.gudPath, branch, *hash)
 	if err != nil {
 		return nil, err
 	}
 
-	err = dumpBranch(p.gudPath, branch, obj.Hash)
+	return v, err
+}
+
+// writeVersion stores a new version without moving any branch.
+// The author defaults to the current user, who is recorded as the committer otherwise.
+func (p Project) writeVersion(v Version) (*ObjectHash, *Version, error) {
+	var gConf GlobalConfig
+	err := LoadConfig(&gConf, gConf.GetPath())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return &v, err
+	if v.Author == "" {
+		v.Author = gConf.Name
+	} else if v.Author != gConf.Name {
+		v.Committer = gConf.Name
+	}
+	v.Time = time.Now()
+
+	obj, err := createVersion(p.gudPath, v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &obj.Hash, &v, nil
 }
 
-func (p Project) createBlob(relPath string) (h *ObjectHash, err error) {
-	src, err := os.Open(filepath.Join(p.Path, relPath))
+// createBlob saves the contents of a file, converted by its attributes.
+func (p Project) createBlob(relPath string, at *attributer) (*ObjectHash, error) {
+	attrs, err := at.lookup(relPath)
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer src.Close()
 
+	file, err := os.Open(filepath.Join(p.Path, relPath))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var src io.Reader = file
+	if attrs.converts() {
+		content, err := ioutil.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		src = bytes.NewReader(attrs.toBlob(content))
+	}
+
+	return writeBlob(p.gudPath, relPath, src)
+}
+
+// writeBlob saves the contents of a file that are read from src.
+func writeBlob(gudPath, relPath string, src io.Reader) (h *ObjectHash, err error) {
 	dst, err := newObjectWriter(relPath)
 	if err != nil {
 		return
@@ -181,13 +243,38 @@ func (p Project) createBlob(relPath string) (h *ObjectHash, err error) {
 		return
 	}
 
-	return dst.Dump(p.gudPath)
+	return dst.Dump(gudPath)
 }
 
 func createTree(gudPath, relPath string, tree tree) (*object, error) {
 	return createGobObject(gudPath, relPath, tree, typeTree)
 }
 
+// hashTree returns the object of a tree, like createTree, without writing it to the object store.
+func hashTree(relPath string, tree tree) (*object, error) {
+	w, err := newObjectWriter(relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gob.NewEncoder(w).Encode(tree)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var h ObjectHash
+	copy(h[:], w.sha.Sum(nil))
+	return &object{
+		Name: filepath.Base(relPath),
+		Hash: h,
+		Type: typeTree,
+	}, nil
+}
+
 func createVersion(gudPath string, version Version) (*object, error) {
 	return createGobObject(gudPath, version.Message, versionToGob(version), typeVersion)
 }
@@ -251,18 +338,10 @@ func (w *objectWriter) Dump(gudPath string) (h *ObjectHash, err error) {
 	copy(ret[:], sum)
 
 	// Create the blob file
-	dst, err := os.Create(objectPath(gudPath, ret))
-	if err != nil {
-		return
-	}
-	defer func() {
-		cerr := dst.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-
-	_, err = w.data.WriteTo(dst)
+	err = writeAtomic(gudPath, objectPath(gudPath, ret), func(dst io.Writer) error {
+		_, err := w.data.WriteTo(dst)
+		return err
+	})
 	if err != nil {
 		return
 	}
@@ -291,7 +370,13 @@ func readBlob(gudPath string, hash ObjectHash) (string, error) {
 	return string(content), nil
 }
 
-func (p Project) extractBlob(relPath string, hash ObjectHash) (err error) {
+// extractBlob writes a blob to the working tree, converted by the attributes of the file.
+func (p Project) extractBlob(relPath string, hash ObjectHash, at *attributer) (err error) {
+	attrs, err := at.lookup(relPath)
+	if err != nil {
+		return
+	}
+
 	src, err := os.Open(objectPath(p.gudPath, hash))
 	if err != nil {
 		return
@@ -304,7 +389,23 @@ func (p Project) extractBlob(relPath string, hash ObjectHash) (err error) {
 	}
 	defer zip.Close()
 
-	dst, err := os.Create(filepath.Join(p.Path, relPath))
+	var content io.Reader = zip
+	if attrs.converts() {
+		var data []byte
+		data, err = ioutil.ReadAll(zip)
+		if err != nil {
+			return
+		}
+		content = bytes.NewReader(attrs.toWorkTree(data))
+	}
+
+	path := filepath.Join(p.Path, relPath)
+	err = os.MkdirAll(filepath.Dir(path), dirPerm)
+	if err != nil {
+		return
+	}
+
+	dst, err := os.Create(path)
 	if err != nil {
 		return
 	}
@@ -315,7 +416,7 @@ func (p Project) extractBlob(relPath string, hash ObjectHash) (err error) {
 		}
 	}()
 
-	_, err = io.Copy(dst, zip)
+	_, err = io.Copy(dst, content)
 	return
 }
 
@@ -363,14 +464,17 @@ func loadVersion(gudPath string, hash ObjectHash) (*Version, error) {
 }
 
 func (p Project) findObject(relPath string, versionHash ObjectHash) (*object, error) {
-	dirs := strings.Split(relPath, string(os.PathSeparator))
-
 	version, err := loadVersion(p.gudPath, versionHash)
 	if err != nil {
 		return nil, err
 	}
 
 	obj := object{Name: ".", Hash: version.TreeHash, Type: typeTree}
+	if relPath == "." {
+		return &obj, nil
+	}
+
+	dirs := strings.Split(relPath, string(os.PathSeparator))
 	for _, name := range dirs {
 		tree, err := loadTree(p.gudPath, obj.Hash)
 		if err != nil {
@@ -387,15 +491,30 @@ func (p Project) findObject(relPath string, versionHash ObjectHash) (*object, er
 	return &obj, nil
 }
 
-func (p Project) compareToObject(relPath string, hash ObjectHash) (bool, error) {
+// compareToObject returns true if a file has the contents of a blob, after it is converted by its attributes.
+func (p Project) compareToObject(relPath string, hash ObjectHash, at *attributer) (bool, error) {
 	const bufSiz = 1024
 
+	attrs, err := at.lookup(relPath)
+	if err != nil {
+		return false, err
+	}
+
 	file, err := os.Open(filepath.Join(p.Path, relPath))
 	if err != nil {
 		return false, err
 	}
 	defer file.Close()
 
+	var content io.Reader = file
+	if attrs.converts() {
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return false, err
+		}
+		content = bytes.NewReader(attrs.toBlob(data))
+	}
+
 	obj, err := os.Open(objectPath(p.gudPath, hash))
 	if err != nil {
 		return false, err
@@ -410,15 +529,13 @@ func (p Project) compareToObject(relPath string, hash ObjectHash) (bool, error)
 
 	var buf1, buf2 [bufSiz]byte
 	for {
-		// weird inconsistency: upon reaching end-of-file, the zip reader will return (n, io.EOF)
-		// while the file reader will return (n, nil) and then (0, io.EOF) on the next read
-		// shouldn't affect our code though
-		n1, err1 := file.Read(buf1[:])
-		if err1 != nil && err1 != io.EOF {
+		// both readers are read until the buffers are full, since they may return less at a time
+		n1, err1 := io.ReadFull(content, buf1[:])
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
 			return false, err1
 		}
-		n2, err2 := unzip.Read(buf2[:])
-		if err2 != nil && err2 != io.EOF {
+		n2, err2 := io.ReadFull(unzip, buf2[:])
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
 			return false, err2
 		}
 
@@ -428,7 +545,7 @@ func (p Project) compareToObject(relPath string, hash ObjectHash) (bool, error)
 		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
 			return false, nil
 		}
-		if err1 == io.EOF && err2 == io.EOF {
+		if err1 != nil || err2 != nil { // the end of one of them, so of both
 			return true, nil
 		}
 	}
@@ -470,32 +587,44 @@ func addToStructure(structure *dirStructure, entry indexEntry) {
 	}
 }
 
-func buildTree(gudPath, relPath string, root dirStructure, prev tree) (*object, error) {
+// buildTree saves the changes in root on top of the tree prev, and returns the new tree, or nil if it is empty.
+// The trees of different directories are built concurrently by pool.
+func buildTree(gudPath, relPath string, root dirStructure, prev tree, pool *workerPool) (*object, error) {
 	newTree := make(tree, len(prev), len(prev)+len(root.Objects)+len(root.Dirs))
 	copy(newTree, prev)
 
-	for _, dir := range root.Dirs {
+	dirObjs := make([]*object, len(root.Dirs))
+	err := pool.each(len(root.Dirs), func(i int) error {
+		dir := root.Dirs[i]
 		var tree tree
-		ind, found := searchTree(newTree, dir.Name)
+		ind, found := searchTree(prev, dir.Name)
 		if found {
 			var err error
-			tree, err = loadTree(gudPath, newTree[ind].Hash)
+			tree, err = loadTree(gudPath, prev[ind].Hash)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 
-		obj, err := buildTree(gudPath, filepath.Join(relPath, dir.Name), dir, tree)
-		if err != nil {
-			return nil, err
-		}
+		var err error
+		dirObjs[i], err = buildTree(gudPath, filepath.Join(relPath, dir.Name), dir, tree, pool)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if obj != nil {
+	for i, dir := range root.Dirs {
+		ind, found := searchTree(newTree, dir.Name)
+		if obj := dirObjs[i]; obj != nil {
 			if !found {
 				newTree = append(newTree, object{})
 				copy(newTree[ind+1:], newTree[ind:])
 			}
 			newTree[ind] = *obj
+		} else if found { // every file of the directory was removed
+			copy(newTree[ind:], newTree[ind+1:])
+			newTree = newTree[:len(newTree)-1]
 		}
 	}
 
@@ -524,62 +653,131 @@ func buildTree(gudPath, relPath string, root dirStructure, prev tree) (*object,
 	return createTree(gudPath, relPath, newTree)
 }
 
-func removeVersion(gudPath string, last, afterLast Version, lastHash, afterLastHash ObjectHash) (err error) {
-	afterLastObjs, err := listTree(gudPath, afterLast.TreeHash)
-	if err != nil {
-		return
+// pruneVersions removes versions from a linear history, given from the newest version to the oldest,
+// and links each kept version to the kept version before it. The newest version must be kept.
+// The objects that only the removed versions use are removed with them.
+func pruneVersions(gudPath string, hashes []ObjectHash, versions []Version, keep []bool) error {
+	var prev *ObjectHash
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !keep[i] {
+			continue
+		}
+		if !sameHash(versions[i].prev, prev) {
+			versions[i].prev = prev
+			err := rewriteVersion(gudPath, hashes[i], versions[i])
+			if err != nil {
+				return err
+			}
+		}
+		prev = &hashes[i]
 	}
 
-	lastObjs, err := listTree(gudPath, last.TreeHash)
+	reachable := make(map[ObjectHash]bool)
+	err := markReachable(gudPath, hashes[0], nil, reachable)
 	if err != nil {
-		return
+		return err
 	}
 
-	for lastE := lastObjs.Front(); lastE != nil; lastE = lastE.Next() {
-		lastObj := lastE.Value.(ObjectHash)
-		toRemove := true
-		for afterLastE := afterLastObjs.Front(); afterLastE != nil; afterLastE = afterLastE.Next() {
-			if afterLastE.Value.(ObjectHash) == lastObj {
-				afterLastObjs.Remove(afterLastE)
-				toRemove = false
-				break
-			}
+	for i := range versions {
+		if keep[i] {
+			continue
 		}
-
-		if toRemove {
-			err = os.Remove(objectPath(gudPath, lastObj))
-			if err != nil {
-				return
-			}
+		err = removeObjects(gudPath, versions[i].TreeHash, reachable)
+		if err != nil {
+			return err
+		}
+		err = removeObject(gudPath, hashes[i])
+		if err != nil {
+			return err
 		}
 	}
 
-	err = os.Remove(objectPath(gudPath, lastHash))
-	if err != nil {
-		return
-	}
+	return nil
+}
 
-	dst, err := os.Create(objectPath(gudPath, afterLastHash))
+func sameHash(a, b *ObjectHash) bool {
+	return a == b || a != nil && b != nil && *a == *b
+}
+
+// rewriteVersion stores a changed version in place of the version hash, which keeps its hash.
+func rewriteVersion(gudPath string, hash ObjectHash, version Version) error {
+	err := journalFile(gudPath, filepath.Join(objectsPath, hash.String()))
 	if err != nil {
-		return
+		return err
 	}
-	defer func() {
-		cerr := dst.Close()
+
+	return writeAtomic(gudPath, objectPath(gudPath, hash), func(dst io.Writer) error {
+		zip := zlib.NewWriter(dst)
+		err := gob.NewEncoder(zip).Encode(versionToGob(version))
+		cerr := zip.Close()
 		if err == nil {
 			err = cerr
 		}
-	}()
+		return err
+	})
+}
 
-	afterLast.prev = nil
+// removeUnreachable removes the objects of the tree hash that are no longer used by the version head
+// or the versions before it. The history is not followed past the version stop.
+func removeUnreachable(gudPath string, hash, head ObjectHash, stop *ObjectHash) error {
+	reachable := make(map[ObjectHash]bool)
+	err := markReachable(gudPath, head, stop, reachable)
+	if err != nil {
+		return err
+	}
 
-	zip := zlib.NewWriter(dst)
-	defer func() {
-		cerr := zip.Close()
-		if err == nil {
-			err = cerr
+	return removeObjects(gudPath, hash, reachable)
+}
+
+// removeObjects removes the objects of the tree hash that are not reachable, and marks them as handled.
+func removeObjects(gudPath string, hash ObjectHash, reachable map[ObjectHash]bool) error {
+	objs, err := listTree(gudPath, hash)
+	if err != nil {
+		return err
+	}
+	for e := objs.Front(); e != nil; e = e.Next() {
+		obj := e.Value.(ObjectHash)
+		if !reachable[obj] {
+			reachable[obj] = true // the same tree may appear more than once
+			err = removeObject(gudPath, obj)
+			if err != nil {
+				return err
+			}
 		}
-	}()
-	return gob.NewEncoder(zip).Encode(versionToGob(afterLast))
+	}
+
+	return nil
+}
+
+func markReachable(gudPath string, hash ObjectHash, stop *ObjectHash, reachable map[ObjectHash]bool) error {
+	for !reachable[hash] && (stop == nil || hash != *stop) {
+		reachable[hash] = true
+
+		version, err := loadVersion(gudPath, hash)
+		if err != nil {
+			return err
+		}
+		objs, err := listTree(gudPath, version.TreeHash)
+		if err != nil {
+			return err
+		}
+		for e := objs.Front(); e != nil; e = e.Next() {
+			reachable[e.Value.(ObjectHash)] = true
+		}
+
+		if version.IsMergeVersion() {
+			err = markReachable(gudPath, *version.merged, stop, reachable)
+			if err != nil {
+				return err
+			}
+		}
+		if !version.HasPrev() {
+			break
+		}
+		hash = *version.prev
+	}
+
+	return nil
 }
 
 func listTree(gudPath string, hash ObjectHash) (*list.List, error) {
@@ -631,6 +829,79 @@ func walkObjects(gudPath, relPath string, root tree, fn func(relPath string, obj
 	return nil
 }
 
+// diffTrees reports the files that differ between the trees src and dst.
+// obj is the object in dst, or the object in src for removed files.
+func diffTrees(gudPath, relPath string, src, dst tree, fn func(relPath string, state FileState, obj object) error) error {
+	srcInd := 0
+	dstInd := 0
+	for srcInd < len(src) || dstInd < len(dst) {
+		var srcObj, dstObj *object
+		if dstInd == len(dst) || (srcInd < len(src) && src[srcInd].Name < dst[dstInd].Name) {
+			srcObj = &src[srcInd]
+			srcInd++
+		} else if srcInd == len(src) || dst[dstInd].Name < src[srcInd].Name {
+			dstObj = &dst[dstInd]
+			dstInd++
+		} else {
+			srcObj, dstObj = &src[srcInd], &dst[dstInd]
+			srcInd++
+			dstInd++
+		}
+
+		err := diffObjects(gudPath, relPath, srcObj, dstObj, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func diffObjects(gudPath, parentPath string, src, dst *object, fn func(string, FileState, object) error) error {
+	if src != nil && dst != nil && src.Type == dst.Type && src.Hash == dst.Hash {
+		return nil
+	}
+
+	var relPath string
+	var srcTree, dstTree tree
+	var err error
+	if src != nil {
+		relPath = filepath.Join(parentPath, src.Name)
+		if src.Type == typeTree {
+			srcTree, err = loadTree(gudPath, src.Hash)
+			if err != nil {
+				return err
+			}
+		} else if dst == nil || dst.Type == typeTree {
+			err = fn(relPath, StateRemoved, *src)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if dst != nil {
+		relPath = filepath.Join(parentPath, dst.Name)
+		if dst.Type == typeTree {
+			dstTree, err = loadTree(gudPath, dst.Hash)
+			if err != nil {
+				return err
+			}
+		} else if src == nil || src.Type == typeTree {
+			err = fn(relPath, StateNew, *dst)
+		} else {
+			err = fn(relPath, StateModified, *dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if srcTree == nil && dstTree == nil {
+		return nil
+	}
+	return diffTrees(gudPath, relPath, srcTree, dstTree, fn)
+}
+
 func searchTree(tree tree, name string) (int, bool) {
 	l := len(tree)
 	ind := sort.Search(l, func(i int) bool {
@@ -28,7 +28,7 @@ func TestProject_createBlob(t *testing.T) {
 	p, _ := Start(testDir)
 	_ = ioutil.WriteFile(filepath.Join(testDir, testFile), []byte("hello\nthis is a test"), 0644)
 
-	hash, err := p.createBlob(testFile)
+	hash, err := p.createBlob(testFile, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,221 @@
+package gud
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const operationsFilePath = "operations"
+
+// operation is a command that changed the project. Before is the checkpoint of the project before it,
+// and After, once the operation is undone, the checkpoint it can be redone to.
+type operation struct {
+	Name   string
+	Time   time.Time
+	Before ObjectHash
+	After  ObjectHash
+}
+
+// operationLog has the operations that can be undone, and the ones that were undone and can be redone,
+// both from the oldest to the newest.
+type operationLog struct {
+	Done   []operation
+	Undone []operation
+}
+
+// logOperation records a command that is about to change the project, whose state before it is kept by a checkpoint.
+// The operations that were undone can no longer be redone.
+func (p Project) logOperation(name string, before ObjectHash) error {
+	log, err := p.loadOperations()
+	if err != nil {
+		return err
+	}
+
+	log.Done = append(log.Done, operation{Name: name, Time: time.Now(), Before: before})
+	log.Undone = nil
+	return dumpOperations(p.gudPath, *log)
+}
+
+// Undo returns the project, including its working tree, index, head and branches, to the state
+// before the last n commands that changed it. It returns the names of the undone commands, from the newest.
+func (p Project) Undo(n int) ([]string, error) {
+	log, err := p.loadOperations()
+	if err != nil {
+		return nil, err
+	}
+	if len(log.Done) == 0 {
+		return nil, Error{"nothing to undo"}
+	}
+	if n < 1 || n > len(log.Done) {
+		return nil, Error{fmt.Sprintf("there are %d commands to undo", len(log.Done))}
+	}
+
+	current, err := p.checkpoint("undo")
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, Error{"checkpoints are disabled in the config"}
+	}
+
+	undone := log.Done[len(log.Done)-n:]
+	log.Done = log.Done[:len(log.Done)-n]
+	var names []string
+	after := *current
+	for i := len(undone) - 1; i >= 0; i-- {
+		op := undone[i]
+		op.After = after
+		after = op.Before
+		log.Undone = append(log.Undone, op)
+		names = append(names, op.Name)
+	}
+
+	err = p.restoreState(undone[0].Before)
+	if err != nil {
+		return nil, err
+	}
+
+	return names, dumpOperations(p.gudPath, *log)
+}
+
+// Redo brings back the changes of the last n commands that were undone.
+// It returns the names of the redone commands, from the oldest.
+func (p Project) Redo(n int) ([]string, error) {
+	log, err := p.loadOperations()
+	if err != nil {
+		return nil, err
+	}
+	if len(log.Undone) == 0 {
+		return nil, Error{"nothing to redo"}
+	}
+	if n < 1 || n > len(log.Undone) {
+		return nil, Error{fmt.Sprintf("there are %d commands to redo", len(log.Undone))}
+	}
+
+	current, err := p.checkpoint("redo")
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, Error{"checkpoints are disabled in the config"}
+	}
+
+	var names []string
+	before := *current
+	for i := 0; i < n; i++ {
+		op := log.Undone[len(log.Undone)-1]
+		log.Undone = log.Undone[:len(log.Undone)-1]
+		op.Before = before
+		before = op.After
+		log.Done = append(log.Done, op)
+		names = append(names, op.Name)
+	}
+
+	err = p.restoreState(before)
+	if err != nil {
+		return nil, err
+	}
+
+	return names, dumpOperations(p.gudPath, *log)
+}
+
+// Rollback returns the project to its last checkpoint, and forgets the command that took it.
+// It is meant for commands that failed after taking their checkpoint, and does nothing if checkpoints are disabled.
+func (p Project) Rollback() error {
+	var config Config
+	err := p.LoadConfig(&config)
+	if err != nil {
+		return err
+	}
+	if !checkpointsEnabled(config) {
+		return nil
+	}
+
+	inner := p.innerProject()
+	hash, err := inner.CurrentHash()
+	if err != nil {
+		return err
+	}
+	err = inner.Reset()
+	if err != nil {
+		return err
+	}
+
+	log, err := p.loadOperations()
+	if err != nil {
+		return err
+	}
+	if len(log.Done) == 0 || log.Done[len(log.Done)-1].Before != *hash {
+		return nil
+	}
+	log.Done = log.Done[:len(log.Done)-1]
+	return dumpOperations(p.gudPath, *log)
+}
+
+// restoreState returns the working tree and the metadata of the project to the way they are in a checkpoint.
+// The checkpoints after it are kept, and the restored state is checkpointed again before the next command.
+func (p Project) restoreState(hash ObjectHash) error {
+	inner := p.innerProject()
+	head, err := loadHead(inner.gudPath)
+	if err != nil {
+		return err
+	}
+
+	err = inner.Checkout(hash)
+	if err != nil {
+		return err
+	}
+
+	return dumpHead(inner.gudPath, *head)
+}
+
+// loadOperations loads the operation log, without the operations whose checkpoints were removed
+// by the retention policy.
+func (p Project) loadOperations() (*operationLog, error) {
+	var log operationLog
+	file, err := os.Open(filepath.Join(p.gudPath, operationsFilePath))
+	if os.IsNotExist(err) {
+		return &log, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = gob.NewDecoder(file).Decode(&log)
+	if err != nil {
+		return nil, err
+	}
+
+	inner := p.innerProject()
+	kept := func(hash ObjectHash) bool {
+		_, err := os.Stat(objectPath(inner.gudPath, hash))
+		return err == nil
+	}
+
+	// Every operation can only be reached through the ones after it
+	for i := len(log.Done) - 1; i >= 0; i-- {
+		if !kept(log.Done[i].Before) {
+			log.Done = log.Done[i+1:]
+			break
+		}
+	}
+	for i := len(log.Undone) - 1; i >= 0; i-- {
+		if !kept(log.Undone[i].After) {
+			log.Undone = log.Undone[i+1:]
+			break
+		}
+	}
+
+	return &log, nil
+}
+
+func dumpOperations(gudPath string, log operationLog) error {
+	return writeMetadata(gudPath, operationsFilePath, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(log)
+	})
+}
@@ -0,0 +1,72 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_UndoRedo(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("data"), 0644)
+	_ = p.Checkpoint("add")
+	_ = p.Add(testPath)
+	_ = p.Checkpoint("save")
+	_, _ = p.Save("first")
+	saved, _ := p.CurrentHash()
+
+	_ = p.Checkpoint("branch")
+	_ = p.CreateBranch("other")
+	_ = p.Checkpoint("rm")
+	_ = p.Remove(testPath)
+
+	names, err := p.Undo(2)
+	if err != nil {
+		t.Fatal("failed to undo:", err)
+	}
+	if len(names) != 2 || names[0] != "rm" || names[1] != "branch" {
+		t.Error("unexpected undone commands:", names)
+	}
+	if _, err = os.Stat(testPath); err != nil {
+		t.Error("the removed file was not brought back:", err)
+	}
+	if hash, _ := p.GetBranch("other"); hash != nil {
+		t.Error("the created branch was kept")
+	}
+	if index, _ := loadIndex(p.gudPath); len(index) != 0 {
+		t.Error("the removal was kept in the index:", index)
+	}
+
+	_, _ = p.Undo(1)
+	if current, _ := p.CurrentHash(); *current == *saved {
+		t.Error("the save was not undone")
+	}
+
+	names, err = p.Redo(2)
+	if err != nil {
+		t.Fatal("failed to redo:", err)
+	}
+	if len(names) != 2 || names[0] != "save" || names[1] != "branch" {
+		t.Error("unexpected redone commands:", names)
+	}
+	if hash, _ := p.GetBranch("other"); hash == nil || *hash != *saved {
+		t.Error("the branch was not created again")
+	}
+
+	_ = p.Checkpoint("failing")
+	err = p.Rollback()
+	if err != nil {
+		t.Fatal("failed to roll back:", err)
+	}
+	if _, err = p.Redo(1); err == nil {
+		t.Error("an undone command was redone after another command")
+	}
+	names, _ = p.Undo(1)
+	if len(names) != 1 || names[0] != "branch" {
+		t.Error("the rolled back command was kept in the log:", names)
+	}
+}
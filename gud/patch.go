@@ -0,0 +1,142 @@
+package gud
+
+import (
+	"strings"
+)
+
+// ApplyPatch stages some of the changes to a file, by applying hunks to the content staged for it.
+// The hunks must be taken in order from a diff of the staged content, like the ones Diff returns,
+// and the rest of the changes are left unstaged.
+func (p Project) ApplyPatch(relPath string, hunks []Hunk) error {
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	current, err := p.CurrentHash()
+	if err != nil {
+		return err
+	}
+	prev, err := p.findObject(relPath, *current)
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Type != typeBlob {
+		prev = nil
+	}
+
+	var saved string
+	if prev != nil {
+		saved, err = readBlob(p.gudPath, prev.Hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	staged := saved
+	ind, found := findEntry(index, relPath)
+	if found {
+		switch index[ind].State {
+		case StateConflict:
+			return Error{"the file has conflicts: " + relPath}
+		case StateRemoved:
+			staged = ""
+		default:
+			staged, err = readBlob(p.gudPath, index[ind].Hash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := applyHunks(staged, hunks)
+	if err != nil {
+		return Error{"the patch does not apply to " + relPath}
+	}
+
+	if prev != nil && content == saved { // nothing left to stage
+		if found {
+			err = removeEntry(p.gudPath, index[ind])
+			if err != nil {
+				return err
+			}
+			copy(index[ind:], index[ind+1:])
+			index = index[:len(index)-1]
+		}
+		return dumpIndex(p.gudPath, index)
+	}
+
+	hash, err := writeBlob(p.gudPath, relPath, strings.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	state := StateNew
+	if prev != nil {
+		state = StateModified
+	}
+	if found && index[ind].Hash != *hash {
+		err = removeEntry(p.gudPath, index[ind])
+		if err != nil {
+			return err
+		}
+	}
+
+	// The staged content is not the content of the file, so it has no mtime
+	entry := indexEntry{
+		Path:  relPath,
+		Hash:  *hash,
+		State: state,
+		Size:  int64(len(content)),
+	}
+	if found {
+		entry.From, entry.FromHash = index[ind].From, index[ind].FromHash
+	}
+	index = setIndexEntry(index, entry)
+	return dumpIndex(p.gudPath, index)
+}
+
+// applyHunks applies hunks of a diff of old, in order, and returns the result.
+func applyHunks(old string, hunks []Hunk) (string, error) {
+	lines := strings.SplitAfter(old, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var res strings.Builder
+	pos := 0 // the next line of old to copy
+	for _, hunk := range hunks {
+		start := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			start = hunk.OldStart
+		}
+		if start < pos || start > len(lines) {
+			return "", Error{"hunks overlap or are out of order"}
+		}
+
+		for ; pos < start; pos++ {
+			res.WriteString(lines[pos])
+		}
+
+		for _, line := range hunk.Lines {
+			if line.Op == '+' {
+				res.WriteString(line.Text)
+				continue
+			}
+
+			if pos == len(lines) || lines[pos] != line.Text {
+				return "", Error{"the file does not match the hunk"}
+			}
+			if line.Op == ' ' {
+				res.WriteString(line.Text)
+			}
+			pos++
+		}
+	}
+
+	for ; pos < len(lines); pos++ {
+		res.WriteString(lines[pos])
+	}
+
+	return res.String(), nil
+}
@@ -0,0 +1,54 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_ApplyPatch(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("numbers")
+
+	_ = ioutil.WriteFile(testPath, []byte("one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"), 0644)
+	diffs, _ := p.Diff()
+	if len(diffs) != 1 {
+		t.Fatal("wrong diffs:", diffs)
+	}
+	hunks := diffs[0].Hunks()
+	if len(hunks) != 2 {
+		t.Fatal("wrong hunks:", hunks)
+	}
+
+	err := p.ApplyPatch(testFile, hunks[1:])
+	if err != nil {
+		t.Fatal("failed to apply:", err)
+	}
+
+	staged, _ := p.DiffStaged()
+	if len(staged) != 1 || staged[0].New != "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n" {
+		t.Fatal("wrong staged content:", staged)
+	}
+	diffs, _ = p.Diff()
+	if len(diffs) != 1 || len(diffs[0].Hunks()) != 1 || diffs[0].Hunks()[0].OldStart != 1 {
+		t.Error("the rest of the changes are not unstaged:", diffs)
+	}
+
+	err = p.ApplyPatch(testFile, hunks[1:])
+	if err == nil {
+		t.Error("applied a hunk that was already staged")
+	}
+
+	err = p.ApplyPatch(testFile, diffs[0].Hunks())
+	if err != nil {
+		t.Fatal("failed to apply:", err)
+	}
+	if diffs, _ = p.Diff(); len(diffs) != 0 {
+		t.Error("changes left after staging every hunk:", diffs)
+	}
+}
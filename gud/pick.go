@@ -0,0 +1,205 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const pickFileName = "cherry-pick"
+
+var ErrPickConflict = Error{
+	"there are conflicts. please resolve them, add the files and continue the cherry-pick"}
+
+// pickState is the progress of a cherry-pick that stopped on conflicts.
+type pickState struct {
+	Branch  string
+	Orig    ObjectHash // the branch before the cherry-pick, restored on abort
+	Current ObjectHash // the version whose conflicts are being resolved
+	Todo    []ObjectHash
+}
+
+// CherryPick applies the changes each of the versions made relative to its predecessor
+// on top of the current branch, in order. Every picked version keeps the message and the author
+// of the original one.
+// When a version conflicts with the branch, the cherry-pick stops with ErrPickConflict
+// until it is continued or aborted.
+func (p Project) CherryPick(hashes ...ObjectHash) error {
+	if p.IsCherryPicking() || p.IsRebasing() {
+		return Error{"a cherry-pick or a rebase is already in progress"}
+	}
+
+	err := p.assertNoChanges()
+	if err != nil {
+		return err
+	}
+
+	head, err := loadHead(p.gudPath)
+	if err != nil {
+		return err
+	}
+	if head.IsDetached {
+		return Error{"cannot cherry-pick while head is detached"}
+	}
+
+	orig, err := loadBranch(p.gudPath, head.Branch)
+	if err != nil {
+		return err
+	}
+
+	return p.pickAll(pickState{Branch: head.Branch, Orig: *orig, Todo: hashes})
+}
+
+// ContinueCherryPick saves the resolved conflicts of the current version, and picks the rest.
+func (p Project) ContinueCherryPick() error {
+	state, err := loadPickState(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	if len(index) > 0 { // the changes were not dropped while resolving
+		picked, err := loadVersion(p.gudPath, state.Current)
+		if err != nil {
+			return err
+		}
+
+		hash, _, err := p.saveIndex(Head{Branch: state.Branch}, Version{
+			Message: picked.Message,
+			Author:  picked.Author,
+			picked:  &state.Current,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = dumpBranch(p.gudPath, state.Branch, *hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.pickAll(*state)
+}
+
+// AbortCherryPick returns the branch, the index and the working tree to their state before the cherry-pick.
+func (p Project) AbortCherryPick() error {
+	state, err := loadPickState(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	err = p.resetTo(state.Branch, state.Orig)
+	if err != nil {
+		return err
+	}
+
+	return removeMetadata(p.gudPath, pickFileName)
+}
+
+// IsCherryPicking returns true if a cherry-pick stopped on conflicts.
+func (p Project) IsCherryPicking() bool {
+	_, err := os.Stat(filepath.Join(p.gudPath, pickFileName))
+	return !os.IsNotExist(err)
+}
+
+func (p Project) pickAll(state pickState) error {
+	for len(state.Todo) > 0 {
+		state.Current = state.Todo[0]
+		state.Todo = state.Todo[1:]
+
+		err := p.pick(state.Branch, state.Current)
+		if err == ErrPickConflict {
+			dumpErr := dumpPickState(p.gudPath, state)
+			if dumpErr != nil {
+				return dumpErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err := removeMetadata(p.gudPath, pickFileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// pick applies the changes of a single version on top of a branch.
+func (p Project) pick(branch string, hash ObjectHash) error {
+	ontoHash, err := loadBranch(p.gudPath, branch)
+	if err != nil {
+		return err
+	}
+
+	treeHash, err := p.applyVersion(*ontoHash, hash, branch)
+	if err == ErrMergeConflict {
+		return ErrPickConflict
+	}
+	if err != nil {
+		return err
+	}
+	if treeHash == nil { // the changes are already in the branch
+		return nil
+	}
+
+	picked, err := loadVersion(p.gudPath, hash)
+	if err != nil {
+		return err
+	}
+
+	newHash, _, err := p.writeVersion(Version{
+		Message:  picked.Message,
+		Author:   picked.Author,
+		TreeHash: *treeHash,
+		prev:     ontoHash,
+		picked:   &hash,
+	})
+	if err != nil {
+		return err
+	}
+
+	return dumpBranch(p.gudPath, branch, *newHash)
+}
+
+// resetTo moves a branch to a version, and discards the index and any change in the working tree.
+func (p Project) resetTo(branch string, hash ObjectHash) error {
+	err := p.discardChanges(hash)
+	if err != nil {
+		return err
+	}
+
+	return dumpBranch(p.gudPath, branch, hash)
+}
+
+func dumpPickState(gudPath string, state pickState) error {
+	return writeMetadata(gudPath, pickFileName, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(state)
+	})
+}
+
+func loadPickState(gudPath string) (*pickState, error) {
+	file, err := os.Open(filepath.Join(gudPath, pickFileName))
+	if os.IsNotExist(err) {
+		return nil, Error{"no cherry-pick in progress"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var state pickState
+	err = gob.NewDecoder(file).Decode(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
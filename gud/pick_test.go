@@ -0,0 +1,115 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_CherryPick(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	fixPath := filepath.Join(testDir, "fix")
+	otherPath := filepath.Join(testDir, "other")
+
+	_ = p.CreateBranch("release")
+	_ = p.CheckoutBranch("release")
+
+	_ = ioutil.WriteFile(otherPath, []byte("not picked"), 0644)
+	_ = p.Add(otherPath)
+	_, _ = p.Save("unrelated change")
+
+	_ = ioutil.WriteFile(fixPath, []byte("fixed"), 0644)
+	_ = p.Add(fixPath)
+	fix, _ := p.Save("the fix")
+	fixHash, _ := p.CurrentHash()
+
+	_ = p.CheckoutBranch(FirstBranchName)
+	err := p.CherryPick(*fixHash)
+	if err != nil {
+		t.Fatal("failed to cherry-pick:", err)
+	}
+
+	version, err := p.CurrentVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Message != fix.Message || version.Author != fix.Author {
+		t.Error("picked version does not keep the original details")
+	}
+	if picked := version.PickedFrom(); picked == nil || *picked != *fixHash {
+		t.Error("picked version does not record its source")
+	}
+
+	data, err := ioutil.ReadFile(fixPath)
+	if err != nil || string(data) != "fixed" {
+		t.Error("picked changes were not applied")
+	}
+	if has, _ := p.HasFile("other", *fixHash); !has {
+		t.Fatal("source version lost its files")
+	}
+	current, _ := p.CurrentHash()
+	if has, _ := p.HasFile("other", *current); has {
+		t.Error("unrelated change was picked")
+	}
+}
+
+func TestProject_CherryPickConflict(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("base\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("base")
+
+	_ = p.CreateBranch("feature")
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("feature\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("feature change")
+	featureHash, _ := p.CurrentHash()
+
+	_ = p.CheckoutBranch(FirstBranchName)
+	_ = ioutil.WriteFile(testPath, []byte("master\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("master change")
+	masterHash, _ := p.CurrentHash()
+
+	err := p.CherryPick(*featureHash)
+	if err != ErrPickConflict {
+		t.Fatal("expected a conflict, got:", err)
+	}
+	if !p.IsCherryPicking() {
+		t.Fatal("cherry-pick state was not kept")
+	}
+
+	err = p.AbortCherryPick()
+	if err != nil {
+		t.Fatal("failed to abort:", err)
+	}
+	current, _ := p.CurrentHash()
+	if *current != *masterHash || p.IsCherryPicking() {
+		t.Error("abort did not restore the branch")
+	}
+	if data, _ := ioutil.ReadFile(testPath); string(data) != "master\n" {
+		t.Error("abort did not restore the working tree")
+	}
+
+	_ = p.CherryPick(*featureHash)
+	_ = ioutil.WriteFile(testPath, []byte("resolved\n"), 0644)
+	_ = p.Add(testPath)
+	err = p.ContinueCherryPick()
+	if err != nil {
+		t.Fatal("failed to continue:", err)
+	}
+
+	version, _ := p.CurrentVersion()
+	if version.Message != "feature change" || p.IsCherryPicking() {
+		t.Error("resolved version was not saved")
+	}
+	if _, prev, _ := p.Prev(*version); prev == nil || prev.Message != "master change" {
+		t.Error("resolved version does not follow the branch")
+	}
+}
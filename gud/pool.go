@@ -0,0 +1,63 @@
+package gud
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workerPool bounds the goroutines that hash and compress objects.
+// Work that finds no free worker runs on the goroutine that asked for it,
+// so work that is started by other work never waits for a worker.
+type workerPool struct {
+	workers chan struct{}
+}
+
+// newWorkerPool returns a pool that runs up to n pieces of work at once, or one for each CPU if n is not positive.
+func newWorkerPool(n int) *workerPool {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	// The goroutine that asks for the work is one of the workers
+	return &workerPool{workers: make(chan struct{}, n-1)}
+}
+
+// workerPool returns a pool with the parallelism of the global configuration.
+func (p Project) workerPool() (*workerPool, error) {
+	var gConf GlobalConfig
+	err := LoadConfig(&gConf, gConf.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	return newWorkerPool(gConf.Parallelism), nil
+}
+
+// each calls fn for every i from 0 to n-1, and waits for all of them.
+// If some of the calls fail, the error of the first one by order is returned.
+func (pool *workerPool) each(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		select {
+		case pool.workers <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-pool.workers
+					wg.Done()
+				}()
+				errs[i] = fn(i)
+			}(i)
+		default:
+			errs[i] = fn(i)
+		}
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
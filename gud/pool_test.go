@@ -0,0 +1,65 @@
+package gud
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkerPool_each(t *testing.T) {
+	pool := newWorkerPool(4)
+	results := make([]int, 100)
+	err := pool.each(len(results), func(i int) error {
+		results[i] = i * i
+		if i == 70 || i == 30 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "failed 30" {
+		t.Error("wrong error:", err)
+	}
+	for i, res := range results {
+		if res != i*i {
+			t.Fatal("missing result", i)
+		}
+	}
+}
+
+func TestProject_addIndexEntriesParallel(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	var changes []fileChange
+	for i := 0; i < 20; i++ {
+		relPath := filepath.Join(fmt.Sprint("dir", i%3), fmt.Sprint("file", i))
+		_ = os.MkdirAll(filepath.Join(testDir, filepath.Dir(relPath)), dirPerm)
+		_ = ioutil.WriteFile(filepath.Join(testDir, relPath), []byte(fmt.Sprint("data", i)), 0644)
+		changes = append(changes, fileChange{relPath, StateNew})
+	}
+
+	var trees []ObjectHash
+	for _, workers := range []int{1, 8} {
+		pool := newWorkerPool(workers)
+		index, err := p.addIndexEntries(changes, nil, nil, pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir := dirStructure{Name: "."}
+		for _, entry := range index {
+			addToStructure(&dir, entry)
+		}
+		obj, err := buildTree(p.gudPath, "", dir, nil, pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, obj.Hash)
+	}
+
+	if trees[0] != trees[1] {
+		t.Error("the tree depends on the number of workers")
+	}
+}
@@ -0,0 +1,97 @@
+package gud
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// MergePreview is the result of merging two versions in the object store.
+type MergePreview struct {
+	TreeHash  *ObjectHash // the hash the merged tree would have, nil if there are conflicts
+	Conflicts []FileConflict
+}
+
+// FileConflict is a file that was changed differently by both merged versions.
+type FileConflict struct {
+	Path  string
+	Hunks []ConflictHunk // empty if the file was removed by one of the versions
+}
+
+// ConflictHunk is a part of a conflicting file that differs between the merged versions.
+type ConflictHunk struct {
+	Line int    // the line in the target version where the hunk starts, counting from 1
+	To   string // the lines of the target version
+	From string // the lines of the merged version
+}
+
+// PreviewMerge merges the version from into the version to without touching the index,
+// the working tree, any branch or the object store, to tell whether they can be merged.
+func (p Project) PreviewMerge(to, from ObjectHash) (*MergePreview, error) {
+	merged, _, conflicts, err := mergeTreesOf(p.gudPath, to, from, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conflicts) == 0 {
+		treeObj, err := hashTree("", merged)
+		if err != nil {
+			return nil, err
+		}
+		return &MergePreview{TreeHash: &treeObj.Hash}, nil
+	}
+
+	preview := &MergePreview{Conflicts: make([]FileConflict, len(conflicts))}
+	for i, conflict := range conflicts {
+		preview.Conflicts[i].Path = conflict.Path
+		if conflict.To == nil || conflict.From == nil {
+			continue
+		}
+
+		preview.Conflicts[i].Hunks, err = conflictHunks(p.gudPath, conflict.To.Hash, conflict.From.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return preview, nil
+}
+
+// conflictHunks returns the parts of two blobs that differ, the same ones writeConflict marks.
+func conflictHunks(gudPath string, to, from ObjectHash) ([]ConflictHunk, error) {
+	toText, err := readBlob(gudPath, to)
+	if err != nil {
+		return nil, err
+	}
+	fromText, err := readBlob(gudPath, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var hunks []ConflictHunk
+	var hunk *ConflictHunk
+	line := 1
+
+	dmp := diffmatchpatch.New()
+	wSrc, wDst, wArr := dmp.DiffLinesToChars(toText, fromText)
+	for _, diff := range dmp.DiffCharsToLines(dmp.DiffMain(wSrc, wDst, false), wArr) {
+		if diff.Type == diffmatchpatch.DiffEqual {
+			hunk = nil
+			line += strings.Count(diff.Text, "\n")
+			continue
+		}
+
+		if hunk == nil {
+			hunks = append(hunks, ConflictHunk{Line: line})
+			hunk = &hunks[len(hunks)-1]
+		}
+		if diff.Type == diffmatchpatch.DiffDelete {
+			hunk.To += diff.Text
+			line += strings.Count(diff.Text, "\n")
+		} else {
+			hunk.From += diff.Text
+		}
+	}
+
+	return hunks, nil
+}
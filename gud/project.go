@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -167,7 +168,12 @@ func Load(path string) (*Project, error) {
 		gudPath := filepath.Join(path, DefaultPath)
 		info, err := os.Stat(gudPath)
 		if !os.IsNotExist(err) && info.IsDir() {
-			return &Project{path, gudPath}, nil
+			p := &Project{path, gudPath}
+			err = p.recoverTransaction()
+			if err != nil {
+				return nil, err
+			}
+			return p, nil
 		}
 		path = parent
 	}
@@ -194,6 +200,11 @@ func (p Project) CurrentVersion() (*Version, error) {
 	return loadVersion(p.gudPath, *hash)
 }
 
+// LoadVersion returns the version of the project a hash refers to.
+func (p Project) LoadVersion(hash ObjectHash) (*Version, error) {
+	return loadVersion(p.gudPath, hash)
+}
+
 func (p Project) CurrentBranch() (string, error) {
 	head, err := loadHead(p.gudPath)
 	if err != nil {
@@ -219,36 +230,61 @@ func (p Project) LatestVersion() (*Version, error) {
 
 // Save saves the current version of the project.
 func (p Project) Save(message string) (*Version, error) {
-	index, err := loadIndex(p.gudPath)
+	head, err := loadHead(p.gudPath)
 	if err != nil {
 		return nil, err
 	}
-	if len(index) == 0 {
-		return nil, Error{"no changes to commit"}
+
+	if head.IsDetached {
+		return nil, Error{"cannot save when head is detached"}
 	}
-	for _, entry := range index {
-		if entry.State == StateConflict {
-			return nil, Error{"conflicts must be solved before saving"}
-		}
+
+	hash, newVersion, err := p.saveIndex(*head, Version{Message: message, merged: head.MergedHash})
+	if err != nil {
+		return nil, err
 	}
 
-	head, err := loadHead(p.gudPath)
+	err = dumpBranch(p.gudPath, head.Branch, *hash)
 	if err != nil {
 		return nil, err
 	}
 
-	if head.IsDetached {
-		return nil, Error{"cannot save when head is detached"}
+	if head.MergedHash != nil {
+		head.MergedHash = nil
+		err = dumpHead(p.gudPath, *head)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	currentHash, err := getCurrentHash(p.gudPath, *head)
+	return newVersion, nil
+}
+
+// saveIndex stores the staged changes on top of the current version, and resets the index.
+// v holds the details of the new version, which follows the current version unless it has another predecessor.
+// No branch is moved.
+func (p Project) saveIndex(head Head, v Version) (*ObjectHash, *Version, error) {
+	index, err := loadIndex(p.gudPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if len(index) == 0 {
+		return nil, nil, Error{"no changes to commit"}
+	}
+	for _, entry := range index {
+		if entry.State == StateConflict {
+			return nil, nil, Error{"conflicts must be solved before saving"}
+		}
+	}
+
+	currentHash, err := getCurrentHash(p.gudPath, head)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	currentVersion, err := loadVersion(p.gudPath, *currentHash)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	dir := dirStructure{Name: "."}
@@ -258,41 +294,43 @@ func (p Project) Save(message string) (*Version, error) {
 
 	prev, err := loadTree(p.gudPath, currentVersion.TreeHash)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	treeObj, err := buildTree(p.gudPath, "", dir, prev)
+	pool, err := p.workerPool()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	treeObj, err := buildTree(p.gudPath, "", dir, prev, pool)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if treeObj == nil {
-		treeObj, err = createTree(p.gudPath, message, tree{})
+		treeObj, err = createTree(p.gudPath, "", tree{})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	newVersion, err := p.saveVersion(message, head.Branch, treeObj.Hash, currentHash, head.MergedHash)
+	v.TreeHash = treeObj.Hash
+	v.renames = indexRenames(index)
+	if v.prev == nil {
+		v.prev = currentHash
+	}
+	hash, newVersion, err := p.writeVersion(v)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// reset index
 	err = initIndex(p.gudPath)
 	if err != nil {
-		return nil, err
-	}
-
-	if head.MergedHash != nil {
-		head.MergedHash = nil
-		err = dumpHead(p.gudPath, *head)
-		if err != nil {
-			return nil, err
-		}
+		return nil, nil, err
 	}
 
-	return newVersion, nil
+	return hash, newVersion, nil
 }
 
 // Prev receives a version of the project and returns and it's previous one.
@@ -395,116 +433,100 @@ func (p Project) HasFile(name string, hash ObjectHash) (bool, error) {
 	return obj != nil, err
 }
 
+// Checkpoint keeps the state of the project before a command that changes it,
+// and logs the command so it can be undone.
 func (p Project) Checkpoint(message string) error {
+	hash, err := p.checkpoint(message)
+	if err != nil || hash == nil {
+		return err
+	}
+
+	return p.logOperation(message, *hash)
+}
+
+// checkpoint keeps the current state of the project, and returns the checkpoint that holds it,
+// or nil if checkpoints are disabled.
+func (p Project) checkpoint(message string) (*ObjectHash, error) {
 	var config Config
 	err := p.LoadConfig(&config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	checkpoints := config.Checkpoints
-	if checkpoints == 0 {
-		return nil
+	if !checkpointsEnabled(config) {
+		return nil, nil
 	}
 
 	inner := p.innerProject()
 	err = inner.AddAll()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	version, err := inner.Save(message)
+	index, err := loadIndex(inner.gudPath)
 	if err != nil {
-		return err
-	}
-
-	var lastHash, afterLastHash ObjectHash
-	var afterLast Version
-	last := *version
-	i := 0
-
-	for ; i < checkpoints; i++ {
-		if !last.HasPrev() {
-			break
-		}
-		tmpHash, tmp, err := inner.Prev(last)
-		if err != nil {
-			return err
-		}
-
-		afterLast, afterLastHash = last, lastHash
-		last, lastHash = *tmp, *tmpHash
+		return nil, err
 	}
-
-	if i == checkpoints {
-		err = removeVersion(inner.gudPath, last, afterLast, lastHash, afterLastHash)
-		if err != nil {
-			return err
-		}
+	if len(index) == 0 { // the last checkpoint already holds the current state
+		return inner.CurrentHash()
 	}
 
-	return nil
-}
-
-func (p Project) Undo() error {
-	inner := p.innerProject()
-
-	err := inner.assertNoChanges()
-	if err == ErrUnstagedChanges || err == ErrUnsavedChanges {
-		return inner.Reset()
-	}
+	_, err = inner.Save(message)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	head, err := loadHead(inner.gudPath)
-	if err != nil {
-		return err
-	}
-	hash, err := getCurrentHash(inner.gudPath, *head)
+	err = inner.pruneCheckpoints(config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	current, err := loadVersion(inner.gudPath, *hash)
-	if err != nil {
-		return err
-	}
+	return inner.CurrentHash()
+}
 
-	if !current.HasPrev() {
-		return Error{"nothing to undo"}
-	}
+func checkpointsEnabled(config Config) bool {
+	return config.Checkpoints > 0 || len(config.Retention) > 0
+}
 
-	prevHash, _, err := inner.Prev(*current)
-	if err != nil {
-		return err
-	}
+func (p Project) innerProject() Project {
+	return Project{p.Path, filepath.Join(p.gudPath, DefaultPath)}
+}
 
-	err = inner.Checkout(*prevHash)
-	if err != nil {
-		return err
+// localMetadata are the files of a project that belong to the running processes and caches,
+// and the operation log, which are not kept by its checkpoints.
+var localMetadata = []string{
+	statCacheFilePath, watchSocketPath, watchSyncPath, operationsFilePath, lockFilePath, journalFilePath, tmpPath,
+	incomingFilePath,
+}
+
+func isLocalMetadata(name string) bool {
+	for _, local := range localMetadata {
+		if name == local {
+			return true
+		}
 	}
+	return false
+}
 
-	tree, err := loadTree(inner.gudPath, current.TreeHash)
+// isCheckpoints returns true for the inner project that keeps the checkpoints of another.
+// It tracks the metadata of the outer project, except for the local files.
+func (p Project) isCheckpoints() bool {
+	outer := filepath.Dir(p.gudPath)
+	return filepath.Base(outer) == DefaultPath && filepath.Dir(outer) == p.Path
+}
+
+// relPath returns the path of a file relative to the root of the project.
+func (p Project) relPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = walkObjects(inner.gudPath, ".", tree, func(relPath string, obj object) error {
-		return os.Remove(objectPath(inner.gudPath, obj.Hash))
-	})
-	err = os.Remove(objectPath(inner.gudPath, *hash))
+	rel, err := filepath.Rel(p.Path, abs)
 	if err != nil {
-		return err
+		return "", err
 	}
-
-	err = dumpBranch(inner.gudPath, head.Branch, *prevHash)
-	if err != nil {
-		return err
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", Error{"the path is outside the project: " + path}
 	}
-
-	return dumpHead(inner.gudPath, *head)
-}
-
-func (p Project) innerProject() Project {
-	return Project{p.Path, filepath.Join(p.gudPath, DefaultPath)}
+	return rel, nil
 }
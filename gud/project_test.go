@@ -165,7 +165,7 @@ func TestProject_Checkpoint(t *testing.T) {
 	}
 
 	_, err = p.Save("add file")
-	err = p.Undo()
+	_, err = p.Undo(1)
 	if err != nil {
 		t.Fatal("failed to undo save:", err)
 	}
@@ -178,7 +178,7 @@ func TestProject_Checkpoint(t *testing.T) {
 		t.Fatal("current version not undo'ed")
 	}
 
-	err = p.Undo()
+	_, err = p.Undo(1)
 	if err != nil {
 		t.Fatal("failed to undo add:", err)
 	}
@@ -0,0 +1,383 @@
+package gud
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const rebaseFileName = "rebase"
+
+// The actions a rebase plan can take for each version.
+const (
+	RebasePick   = "pick"   // replay the version as is
+	RebaseReword = "reword" // replay the version with a new message
+	RebaseSquash = "squash" // meld the version into the one before it
+	RebaseDrop   = "drop"   // leave the version out
+)
+
+var ErrRebaseConflict = Error{
+	"there are conflicts. please resolve them, add the files and continue the rebase"}
+
+// RebaseStep is a single line of a rebase plan.
+type RebaseStep struct {
+	Action  string
+	Hash    ObjectHash
+	Message string // the new message of a reworded version
+}
+
+// rebaseState is the progress of a rebase that stopped on conflicts.
+type rebaseState struct {
+	Branch   string
+	Orig     ObjectHash // the branch before the rebase, kept until it finishes
+	Tip      ObjectHash // the last version replayed
+	Replayed bool       // whether Tip was created by the rebase, and can be squashed into
+	Current  RebaseStep // the step whose conflicts are being resolved
+	Todo     []RebaseStep
+}
+
+// RebasePlan returns the default plan for replaying the current branch on top of onto:
+// every version of the branch that onto does not have, oldest first.
+// Merge versions are left out, as their changes are replayed with the versions they merged.
+func (p Project) RebasePlan(onto ObjectHash) ([]RebaseStep, error) {
+	tip, err := p.CurrentHash()
+	if err != nil {
+		return nil, err
+	}
+
+	upstream := make(map[ObjectHash]bool)
+	err = markAncestors(p.gudPath, onto, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []RebaseStep
+	var visit func(hash ObjectHash) error
+	visit = func(hash ObjectHash) error {
+		if upstream[hash] {
+			return nil
+		}
+		upstream[hash] = true
+
+		version, err := loadVersion(p.gudPath, hash)
+		if err != nil {
+			return err
+		}
+		if version.HasPrev() {
+			err = visit(*version.prev)
+			if err != nil {
+				return err
+			}
+		}
+		if version.IsMergeVersion() {
+			return visit(*version.merged)
+		}
+
+		plan = append(plan, RebaseStep{Action: RebasePick, Hash: hash, Message: version.Message})
+		return nil
+	}
+
+	err = visit(*tip)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// WriteRebasePlan writes a plan in the format read by ParseRebasePlan:
+// a line for every step with its action, the version hash and the first line of its message.
+func WriteRebasePlan(w io.Writer, plan []RebaseStep) error {
+	for _, step := range plan {
+		message := strings.SplitN(step.Message, "\n", 2)[0]
+		_, err := fmt.Fprintf(w, "%s %s %s\n", step.Action, step.Hash, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, `
+# Every line is "<action> <version> [message]", and the versions are replayed from top to bottom.
+# Actions:
+# p, pick   = replay the version
+# r, reword = replay the version, with the rest of the line as its new message
+# s, squash = meld the version into the one before it, combining their messages
+# d, drop   = leave the version out (removing the line does the same)
+`)
+	return err
+}
+
+// ParseRebasePlan reads a plan written by WriteRebasePlan, after it was edited.
+// Empty lines and lines starting with # are ignored.
+func ParseRebasePlan(r io.Reader) ([]RebaseStep, error) {
+	var plan []RebaseStep
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, InputError{fmt.Sprintf("line %d: missing version", lineNum)}
+		}
+
+		var step RebaseStep
+		switch fields[0] {
+		case "p", RebasePick:
+			step.Action = RebasePick
+		case "r", RebaseReword:
+			step.Action = RebaseReword
+		case "s", RebaseSquash:
+			step.Action = RebaseSquash
+		case "d", RebaseDrop:
+			step.Action = RebaseDrop
+		default:
+			return nil, InputError{fmt.Sprintf("line %d: unknown action %s", lineNum, fields[0])}
+		}
+
+		n, err := hex.Decode(step.Hash[:], []byte(fields[1]))
+		if err != nil || n != len(step.Hash) || len(fields[1]) != hex.EncodedLen(n) {
+			return nil, InputError{fmt.Sprintf("line %d: invalid version %s", lineNum, fields[1])}
+		}
+
+		if len(fields) == 3 {
+			step.Message = strings.TrimSpace(fields[2])
+		}
+		if step.Action == RebaseReword && step.Message == "" {
+			return nil, InputError{fmt.Sprintf("line %d: missing the new message", lineNum)}
+		}
+
+		plan = append(plan, step)
+	}
+
+	return plan, scanner.Err()
+}
+
+// Rebase replays the versions of a plan on top of onto, and moves the current branch to the result.
+// The branch is moved only when the whole plan is replayed. Until then the head is detached.
+// When a version conflicts, the rebase stops with ErrRebaseConflict
+// until it is continued, skipped or aborted.
+func (p Project) Rebase(onto ObjectHash, plan []RebaseStep) error {
+	if p.IsRebasing() || p.IsCherryPicking() {
+		return Error{"a rebase or a cherry-pick is already in progress"}
+	}
+
+	head, err := loadHead(p.gudPath)
+	if err != nil {
+		return err
+	}
+	if head.IsDetached {
+		return Error{"cannot rebase while head is detached"}
+	}
+
+	for _, step := range plan {
+		_, err = loadVersion(p.gudPath, step.Hash)
+		if err != nil {
+			return Error{fmt.Sprintf("invalid version in plan: %s", step.Hash)}
+		}
+	}
+
+	orig, err := loadBranch(p.gudPath, head.Branch)
+	if err != nil {
+		return err
+	}
+
+	err = p.checkoutHash(onto)
+	if err != nil {
+		return err
+	}
+
+	return p.rebaseAll(rebaseState{Branch: head.Branch, Orig: *orig, Tip: onto, Todo: plan})
+}
+
+// ContinueRebase saves the resolved conflicts of the current version, and replays the rest of the plan.
+func (p Project) ContinueRebase() error {
+	state, err := loadRebaseState(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	if len(index) > 0 { // the changes were not dropped while resolving
+		v, squashed, err := p.rebaseDetails(*state, state.Current)
+		if err != nil {
+			return err
+		}
+
+		hash, _, err := p.saveIndex(Head{IsDetached: true, Hash: state.Tip}, v)
+		if err != nil {
+			return err
+		}
+		state.Tip = *hash
+		state.Replayed = state.Replayed || !squashed
+	}
+
+	return p.rebaseAll(*state)
+}
+
+// SkipRebase leaves the current version out, and replays the rest of the plan.
+func (p Project) SkipRebase() error {
+	state, err := loadRebaseState(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	err = p.discardChanges(state.Tip)
+	if err != nil {
+		return err
+	}
+
+	return p.rebaseAll(*state)
+}
+
+// AbortRebase returns the index and the working tree to the state of the branch before the rebase.
+func (p Project) AbortRebase() error {
+	state, err := loadRebaseState(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	err = p.discardChanges(state.Orig)
+	if err != nil {
+		return err
+	}
+
+	err = dumpHead(p.gudPath, Head{IsDetached: false, Branch: state.Branch})
+	if err != nil {
+		return err
+	}
+
+	return removeMetadata(p.gudPath, rebaseFileName)
+}
+
+// IsRebasing returns true if a rebase stopped on conflicts.
+func (p Project) IsRebasing() bool {
+	_, err := os.Stat(filepath.Join(p.gudPath, rebaseFileName))
+	return !os.IsNotExist(err)
+}
+
+func (p Project) rebaseAll(state rebaseState) error {
+	for len(state.Todo) > 0 {
+		state.Current = state.Todo[0]
+		state.Todo = state.Todo[1:]
+		if state.Current.Action == RebaseDrop {
+			continue
+		}
+
+		treeHash, err := p.applyVersion(state.Tip, state.Current.Hash, state.Tip.String())
+		if err == ErrMergeConflict {
+			err = dumpHead(p.gudPath, Head{IsDetached: true, Hash: state.Tip, Branch: state.Branch})
+			if err != nil {
+				return err
+			}
+			err = dumpRebaseState(p.gudPath, state)
+			if err != nil {
+				return err
+			}
+			return ErrRebaseConflict
+		}
+		if err != nil {
+			return err
+		}
+		if treeHash == nil { // the changes are already there
+			continue
+		}
+
+		v, squashed, err := p.rebaseDetails(state, state.Current)
+		if err != nil {
+			return err
+		}
+		v.TreeHash = *treeHash
+
+		hash, _, err := p.writeVersion(v)
+		if err != nil {
+			return err
+		}
+		state.Tip = *hash
+		state.Replayed = state.Replayed || !squashed
+	}
+
+	err := dumpBranch(p.gudPath, state.Branch, state.Tip)
+	if err != nil {
+		return err
+	}
+
+	err = dumpHead(p.gudPath, Head{IsDetached: false, Branch: state.Branch})
+	if err != nil {
+		return err
+	}
+
+	err = removeMetadata(p.gudPath, rebaseFileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// rebaseDetails returns the details of the version a step creates on top of the last replayed version,
+// and whether the step squashes into it.
+func (p Project) rebaseDetails(state rebaseState, step RebaseStep) (Version, bool, error) {
+	replayed, err := loadVersion(p.gudPath, step.Hash)
+	if err != nil {
+		return Version{}, false, err
+	}
+
+	if step.Action == RebaseSquash && state.Replayed {
+		tip, err := loadVersion(p.gudPath, state.Tip)
+		if err != nil {
+			return Version{}, false, err
+		}
+
+		return Version{
+			Message: tip.Message + "\n\n" + replayed.Message,
+			Author:  tip.Author,
+			prev:    tip.prev,
+		}, true, nil
+	}
+
+	v := Version{
+		Message: replayed.Message,
+		Author:  replayed.Author,
+		prev:    &state.Tip,
+	}
+	if step.Action == RebaseReword {
+		v.Message = step.Message
+	}
+	return v, false, nil
+}
+
+func dumpRebaseState(gudPath string, state rebaseState) error {
+	return writeMetadata(gudPath, rebaseFileName, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(state)
+	})
+}
+
+func loadRebaseState(gudPath string) (*rebaseState, error) {
+	file, err := os.Open(filepath.Join(gudPath, rebaseFileName))
+	if os.IsNotExist(err) {
+		return nil, Error{"no rebase in progress"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var state rebaseState
+	err = gob.NewDecoder(file).Decode(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
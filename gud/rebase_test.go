@@ -0,0 +1,129 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProject_Rebase(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	upstreamPath := filepath.Join(testDir, "upstream")
+	featurePath := filepath.Join(testDir, "feature")
+
+	_ = p.CreateBranch("feature")
+	_ = ioutil.WriteFile(upstreamPath, []byte("upstream"), 0644)
+	_ = p.Add(upstreamPath)
+	_, _ = p.Save("upstream change")
+	masterHash, _ := p.CurrentHash()
+
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(featurePath, []byte("one"), 0644)
+	_ = p.Add(featurePath)
+	_, _ = p.Save("first")
+	_ = ioutil.WriteFile(featurePath, []byte("two"), 0644)
+	_ = p.Add(featurePath)
+	_, _ = p.Save("second")
+
+	plan, err := p.RebasePlan(*masterHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Message != "first" || plan[1].Message != "second" {
+		t.Fatal("wrong default plan:", plan)
+	}
+
+	var buf strings.Builder
+	_ = WriteRebasePlan(&buf, plan)
+	edited := strings.Replace(buf.String(), "pick", "reword", 1)
+	edited = strings.Replace(edited, "reword "+plan[0].Hash.String()+" first",
+		"reword "+plan[0].Hash.String()+" feature", 1)
+	edited = strings.Replace(edited, "pick", "s", 1)
+	plan, err = ParseRebasePlan(strings.NewReader(edited))
+	if err != nil {
+		t.Fatal("failed to parse the plan:", err)
+	}
+
+	err = p.Rebase(*masterHash, plan)
+	if err != nil {
+		t.Fatal("failed to rebase:", err)
+	}
+
+	version, _ := p.CurrentVersion()
+	if version.Message != "feature\n\nsecond" {
+		t.Errorf("wrong message after squash: %q", version.Message)
+	}
+	if !version.HasPrev() || *version.prev != *masterHash {
+		t.Error("rebased version is not on top of the branch")
+	}
+	data, err := ioutil.ReadFile(featurePath)
+	if err != nil || string(data) != "two" {
+		t.Error("rebased changes were not applied")
+	}
+	if _, err = ioutil.ReadFile(upstreamPath); err != nil {
+		t.Error("upstream changes are missing")
+	}
+}
+
+func TestProject_RebaseConflict(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("base\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("base")
+
+	_ = p.CreateBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("master\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("master change")
+	masterHash, _ := p.CurrentHash()
+
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(testPath, []byte("feature\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("feature change")
+	featureHash, _ := p.CurrentHash()
+
+	plan, _ := p.RebasePlan(*masterHash)
+	err := p.Rebase(*masterHash, plan)
+	if err != ErrRebaseConflict {
+		t.Fatal("expected a conflict, got:", err)
+	}
+	if !p.IsRebasing() {
+		t.Fatal("rebase state was not saved")
+	}
+	if branch, _ := loadBranch(p.gudPath, "feature"); *branch != *featureHash {
+		t.Error("branch moved before the rebase finished")
+	}
+
+	err = p.AbortRebase()
+	if err != nil {
+		t.Fatal("failed to abort:", err)
+	}
+	data, _ := ioutil.ReadFile(testPath)
+	if string(data) != "feature\n" || p.IsRebasing() {
+		t.Error("abort did not restore the branch")
+	}
+
+	_ = p.Rebase(*masterHash, plan)
+	_ = ioutil.WriteFile(testPath, []byte("resolved\n"), 0644)
+	_ = p.Add(testPath)
+	err = p.ContinueRebase()
+	if err != nil {
+		t.Fatal("failed to continue:", err)
+	}
+
+	version, _ := p.CurrentVersion()
+	if version.Message != "feature change" || *version.prev != *masterHash {
+		t.Error("resolved version was not replayed on top of the branch")
+	}
+	head, _ := loadHead(p.gudPath)
+	if head.IsDetached || head.Branch != "feature" {
+		t.Error("head was not returned to the branch")
+	}
+}
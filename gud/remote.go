@@ -0,0 +1,270 @@
+package gud
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultRemote is the name of the remote the project was cloned from, which is used unless another is given.
+// Unless it is configured, it is the project OwnerName/ProjectName in the server of the global configuration.
+const DefaultRemote = "origin"
+
+const remotesPath = "remotes"
+
+// Remote is another project that the project is pushed to and pulled from, which is either in a server,
+// at a path on this computer, or served by a command, see NewLocalTransport and NewStdioTransport.
+type Remote struct {
+	Domain  string `toml:",omitempty"` // the server, the ServerDomain of the global configuration if empty
+	Owner   string `toml:",omitempty"`
+	Project string `toml:",omitempty"`
+	Path    string `toml:",omitempty"`
+	Command string `toml:",omitempty"` // a command line that runs "gud serve-stdio", like through ssh
+}
+
+// Remotes returns the remotes of the project by their names.
+func (p Project) Remotes() (map[string]Remote, error) {
+	var config Config
+	err := p.LoadConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return remotes(config), nil
+}
+
+// remotes returns the remotes in a configuration, with the default remote it implies.
+func remotes(config Config) map[string]Remote {
+	all := make(map[string]Remote, len(config.Remotes)+1)
+	for name, remote := range config.Remotes {
+		all[name] = remote
+	}
+	if _, ok := all[DefaultRemote]; !ok && config.OwnerName != "" && config.ProjectName != "" {
+		all[DefaultRemote] = Remote{Owner: config.OwnerName, Project: config.ProjectName}
+	}
+	return all
+}
+
+// GetRemote returns a remote of the project. The domain of a remote in a server is filled from the global
+// configuration if needed.
+func (p Project) GetRemote(name string) (*Remote, error) {
+	all, err := p.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	remote, ok := all[name]
+	if !ok {
+		return nil, Error{"unknown remote: " + name}
+	}
+
+	if remote.Domain == "" && remote.Path == "" && remote.Command == "" {
+		var gConfig GlobalConfig
+		err = LoadConfig(&gConfig, gConfig.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		remote.Domain = gConfig.ServerDomain
+	}
+	return &remote, nil
+}
+
+// AddRemote adds a remote to the project.
+func (p Project) AddRemote(name string, remote Remote) error {
+	err := validateRemoteName(name)
+	if err != nil {
+		return err
+	}
+	if remote.Path != "" && remote.Command != "" {
+		return Error{"a remote cannot have both a path and a command"}
+	}
+	if remote.Path == "" && remote.Command == "" && (remote.Owner == "" || remote.Project == "") {
+		return Error{"a remote must have an owner and a project"}
+	}
+
+	var config Config
+	err = p.LoadConfig(&config)
+	if err != nil {
+		return err
+	}
+	if _, ok := remotes(config)[name]; ok {
+		return Error{"remote already exists: " + name}
+	}
+
+	if config.Remotes == nil {
+		config.Remotes = make(map[string]Remote)
+	}
+	config.Remotes[name] = remote
+	return p.WriteConfig(config)
+}
+
+// RemoveRemote removes a remote and its remote-tracking branches.
+func (p Project) RemoveRemote(name string) error {
+	var config Config
+	err := p.LoadConfig(&config)
+	if err != nil {
+		return err
+	}
+
+	err = forgetRemote(&config, name)
+	if err != nil {
+		return err
+	}
+	err = p.WriteConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return p.moveRemoteBranches(name, "")
+}
+
+// RenameRemote renames a remote, with its remote-tracking branches.
+func (p Project) RenameRemote(oldName, newName string) error {
+	err := validateRemoteName(newName)
+	if err != nil {
+		return err
+	}
+
+	var config Config
+	err = p.LoadConfig(&config)
+	if err != nil {
+		return err
+	}
+	all := remotes(config)
+	remote, ok := all[oldName]
+	if !ok {
+		return Error{"unknown remote: " + oldName}
+	}
+	if _, ok = all[newName]; ok {
+		return Error{"remote already exists: " + newName}
+	}
+
+	err = forgetRemote(&config, oldName)
+	if err != nil {
+		return err
+	}
+	if config.Remotes == nil {
+		config.Remotes = make(map[string]Remote)
+	}
+	config.Remotes[newName] = remote
+	err = p.WriteConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return p.moveRemoteBranches(oldName, newName)
+}
+
+// forgetRemote removes a remote from a configuration.
+// The default remote that the configuration implies is removed by forgetting its owner.
+func forgetRemote(config *Config, name string) error {
+	if _, ok := config.Remotes[name]; ok {
+		delete(config.Remotes, name)
+		return nil
+	}
+	if _, ok := remotes(*config)[name]; ok {
+		config.OwnerName = ""
+		return nil
+	}
+	return Error{"unknown remote: " + name}
+}
+
+// moveRemoteBranches moves the remote-tracking branches of a remote to another one,
+// or removes them if the other name is empty.
+func (p Project) moveRemoteBranches(from, to string) error {
+	var branches []string
+	err := p.ListRemoteBranches(func(name string) error {
+		if strings.HasPrefix(name, from+"/") {
+			branches = append(branches, strings.TrimPrefix(name, from+"/"))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, branch := range branches {
+		if to != "" {
+			hash, err := p.GetRemoteBranch(from, branch)
+			if err != nil {
+				return err
+			}
+			err = p.SetRemoteBranch(to, branch, *hash)
+			if err != nil {
+				return err
+			}
+		}
+		err = removeMetadata(p.gudPath, remoteRef(from, branch))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateRemoteName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return Error{"invalid remote name: " + name}
+	}
+	return nil
+}
+
+// remoteRef returns the reference of the remote-tracking branch of a branch in a remote.
+func remoteRef(remote, branch string) string {
+	return filepath.Join(remotesPath, remote, branch)
+}
+
+// GetRemoteBranch returns the version a branch of a remote was at when it was last fetched,
+// or nil if it was never fetched.
+func (p Project) GetRemoteBranch(remote, branch string) (*ObjectHash, error) {
+	return getRef(p.gudPath, remoteRef(remote, branch))
+}
+
+// SetRemoteBranch records the version a branch of a remote is at, after it was pushed or pulled.
+func (p Project) SetRemoteBranch(remote, branch string, hash ObjectHash) error {
+	return dumpRef(p.gudPath, remoteRef(remote, branch), hash)
+}
+
+// ListRemoteBranches calls fn with the names of the remote-tracking branches, like "origin/master".
+func (p Project) ListRemoteBranches(fn func(name string) error) error {
+	remotesRoot := filepath.Join(p.gudPath, remotesPath)
+	return filepath.Walk(remotesRoot, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == remotesRoot {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			relPath, _ := filepath.Rel(remotesRoot, path)
+			return fn(filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+}
+
+// lookupRemoteBranch returns the version a remote-tracking branch, named like "origin/master", points to,
+// or nil if there is no such remote-tracking branch.
+func (p Project) lookupRemoteBranch(name string) (*ObjectHash, error) {
+	i := strings.IndexRune(name, '/')
+	if i <= 0 || i == len(name)-1 {
+		return nil, nil
+	}
+
+	return p.GetRemoteBranch(name[:i], name[i+1:])
+}
+
+// FetchBranch receives the versions of a branch of a remote that PushBranch wrote, and moves its remote-tracking
+// branch to the last of them. The branches of the project and its working tree are not touched.
+// See PullBranchWith.
+func (p Project) FetchBranch(remote, branch string, in io.Reader, contentType string, options PullOptions,
+) (*ObjectHash, error) {
+	return p.pullRef(remoteRef(remote, branch), in, contentType, options)
+}
+
+// IncomingFetch is IncomingTransfer for the remote-tracking branch of a branch in a remote.
+func (p Project) IncomingFetch(remote, branch string) (string, int, error) {
+	return p.incomingTransfer(remoteRef(remote, branch))
+}
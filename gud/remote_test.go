@@ -0,0 +1,136 @@
+package gud
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_FetchBranch(t *testing.T) {
+	defer clearTest()
+
+	serverPath := filepath.Join(testDir, "server")
+	clientPath := filepath.Join(testDir, "client")
+	_ = os.Mkdir(serverPath, dirPerm)
+	_ = os.Mkdir(clientPath, dirPerm)
+
+	server, _ := Start(serverPath)
+	_ = ioutil.WriteFile(filepath.Join(serverPath, testFile), []byte("first"), 0644)
+	_ = server.AddAll()
+	_, _ = server.Save("first")
+
+	client, _ := StartHeadless(clientPath)
+	var buf bytes.Buffer
+	boundary, _ := server.PushBranch(&buf, FirstBranchName, PushOptions{})
+	_, _ = client.PullBranch(FirstBranchName, &buf, "multipart/mixed; boundary="+boundary)
+	_ = client.AddHead()
+	_ = client.Reset()
+	start, _ := client.CurrentHash()
+
+	_ = ioutil.WriteFile(filepath.Join(serverPath, testFile), []byte("second"), 0644)
+	_ = server.AddAll()
+	_, _ = server.Save("second")
+	serverHash, _ := server.CurrentHash()
+
+	haves, _ := client.Haves()
+	buf.Reset()
+	boundary, _ = server.PushBranch(&buf, FirstBranchName, PushOptions{Haves: haves})
+	hash, err := client.FetchBranch(DefaultRemote, FirstBranchName, &buf, "multipart/mixed; boundary="+boundary,
+		PullOptions{})
+	if err != nil {
+		t.Fatal("failed to fetch:", err)
+	}
+	if *hash != *serverHash {
+		t.Error("the remote-tracking branch was not moved to the fetched version")
+	}
+
+	current, _ := client.CurrentHash()
+	if *current != *start {
+		t.Error("the fetch moved the local branch")
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(clientPath, testFile))
+	if string(data) != "first" {
+		t.Error("the fetch changed the working tree")
+	}
+
+	var names []string
+	_ = client.ListRemoteBranches(func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if len(names) != 1 || names[0] != DefaultRemote+"/"+FirstBranchName {
+		t.Error("unexpected remote-tracking branches:", names)
+	}
+
+	resolved, err := client.Resolve(DefaultRemote + "/" + FirstBranchName)
+	if err != nil || *resolved != *serverHash {
+		t.Error("failed to resolve the remote-tracking branch:", err)
+	}
+
+	_, err = client.MergeBranch(DefaultRemote+"/"+FirstBranchName, MergeOptions{})
+	if err != nil {
+		t.Fatal("failed to merge the remote-tracking branch:", err)
+	}
+	current, _ = client.CurrentHash()
+	if *current != *serverHash {
+		t.Error("the merge did not fast-forward to the fetched version")
+	}
+}
+
+func TestProject_Remotes(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	var config Config
+	_ = p.LoadConfig(&config)
+	config.OwnerName = "owner"
+	_ = p.WriteConfig(config)
+
+	remotes, err := p.Remotes()
+	if err != nil {
+		t.Fatal("failed to load the remotes:", err)
+	}
+	if remotes[DefaultRemote] != (Remote{Owner: "owner", Project: config.ProjectName}) {
+		t.Error("the default remote is not implied by the configuration:", remotes)
+	}
+
+	err = p.AddRemote("fork", Remote{Domain: "https://example.com", Owner: "other", Project: "fork"})
+	if err != nil {
+		t.Fatal("failed to add a remote:", err)
+	}
+	if err = p.AddRemote(DefaultRemote, Remote{Owner: "other", Project: "fork"}); err == nil {
+		t.Error("an existing remote was added again")
+	}
+
+	hash, _ := p.CurrentHash()
+	_ = p.SetRemoteBranch(DefaultRemote, FirstBranchName, *hash)
+	err = p.RenameRemote(DefaultRemote, "upstream")
+	if err != nil {
+		t.Fatal("failed to rename the remote:", err)
+	}
+	if moved, _ := p.GetRemoteBranch("upstream", FirstBranchName); moved == nil || *moved != *hash {
+		t.Error("the remote-tracking branches were not renamed")
+	}
+	if old, _ := p.GetRemoteBranch(DefaultRemote, FirstBranchName); old != nil {
+		t.Error("the remote-tracking branches of the old name were kept")
+	}
+
+	err = p.RemoveRemote("fork")
+	if err != nil {
+		t.Fatal("failed to remove the remote:", err)
+	}
+	remotes, _ = p.Remotes()
+	if len(remotes) != 1 || remotes["upstream"].Owner != "owner" {
+		t.Error("unexpected remotes:", remotes)
+	}
+
+	remote, err := p.GetRemote("upstream")
+	if err != nil || remote.Domain == "" {
+		t.Error("the domain of the remote was not filled:", remote, err)
+	}
+	if _, err = p.GetRemote("fork"); err == nil {
+		t.Error("a removed remote was found")
+	}
+}
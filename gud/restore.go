@@ -0,0 +1,207 @@
+package gud
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RestoreOptions choose where files are restored from and to.
+// The zero value restores the working tree from the index.
+type RestoreOptions struct {
+	// The version the files are restored from. If it is nil, the working tree is restored from the index,
+	// and the index from the current version, unless both are restored, which are then restored from it.
+	Source   *ObjectHash
+	Staged   bool // restore the index
+	WorkTree bool // restore the working tree, which is the default if the index is not restored
+}
+
+// Restore returns files and directories to the way they are in a version or in the index.
+// Files that are tracked under the paths but do not exist in the source are removed.
+// Untracked files are kept.
+func (p Project) Restore(options RestoreOptions, paths ...string) error {
+	if !options.Staged {
+		options.WorkTree = true
+	}
+
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return err
+	}
+	current, err := p.CurrentHash()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		relPath, err := p.relPath(path)
+		if err != nil {
+			return err
+		}
+
+		// The files the path has in the source, and the ones it has in the index and the working tree
+		var source map[string]object
+		if options.Source == nil && !options.Staged {
+			for _, entry := range index {
+				if entry.State == StateConflict && underPath(entry.Path, relPath) {
+					return Error{"the file has conflicts: " + entry.Path}
+				}
+			}
+			source, err = p.stagedFiles(relPath, index, *current)
+		} else {
+			sourceHash := current
+			if options.Source != nil {
+				sourceHash = options.Source
+			}
+			source, err = p.savedFiles(relPath, *sourceHash)
+		}
+		if err != nil {
+			return err
+		}
+		staged, err := p.stagedFiles(relPath, index, *current)
+		if err != nil {
+			return err
+		}
+		if len(source) == 0 && len(staged) == 0 {
+			return Error{"unknown path: " + path}
+		}
+
+		if options.Staged {
+			index, err = p.restoreIndex(relPath, source, index, *current)
+			if err != nil {
+				return err
+			}
+		}
+		if options.WorkTree {
+			err = p.restoreWorkTree(source, staged)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return dumpIndex(p.gudPath, index)
+}
+
+// stagedFiles returns the blobs of the files at or under relPath in the index, by their paths.
+// Files that are not staged, or have conflicts, have the blobs of the version.
+func (p Project) stagedFiles(relPath string, index []indexEntry, version ObjectHash) (map[string]object, error) {
+	files, err := p.savedFiles(relPath, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range index {
+		if !underPath(entry.Path, relPath) {
+			continue
+		}
+		switch entry.State {
+		case StateConflict: // in the working tree, until it is resolved
+		case StateRemoved:
+			delete(files, entry.Path)
+		default:
+			files[entry.Path] = object{
+				Name:  filepath.Base(entry.Path),
+				Hash:  entry.Hash,
+				Type:  typeBlob,
+				Size:  entry.Size,
+				Mtime: entry.Mtime,
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// restoreIndex stages the files under relPath as they are in source, compared to the version.
+func (p Project) restoreIndex(relPath string, source map[string]object, index []indexEntry, version ObjectHash,
+) ([]indexEntry, error) {
+	saved, err := p.savedFiles(relPath, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unstage everything under the path, and then stage what differs from the version
+	kept := index[:0]
+	for _, entry := range index {
+		if underPath(entry.Path, relPath) {
+			err = removeEntry(p.gudPath, entry)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	index = kept
+
+	for file := range saved {
+		if _, ok := source[file]; !ok {
+			index = setIndexEntry(index, indexEntry{Path: file, Hash: nullHash, State: StateRemoved})
+		}
+	}
+	for file, obj := range source {
+		savedObj, ok := saved[file]
+		if ok && savedObj.Hash == obj.Hash {
+			continue
+		}
+
+		state := StateNew
+		if ok {
+			state = StateModified
+		}
+		// The blob belongs to the source version
+		index = setIndexEntry(index, indexEntry{
+			Path:   file,
+			Hash:   obj.Hash,
+			State:  state,
+			Mtime:  obj.Mtime,
+			Size:   obj.Size,
+			Shared: true,
+		})
+	}
+
+	return index, nil
+}
+
+// restoreWorkTree writes the files of source to the working tree, and removes the files of staged that it does not have.
+// Files outside the sparse set are not touched.
+func (p Project) restoreWorkTree(source, staged map[string]object) error {
+	s, err := p.loadScan()
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	for file := range staged {
+		if _, ok := source[file]; !ok && s.includes(file) {
+			removed = append(removed, file)
+		}
+	}
+	sort.Strings(removed)
+
+	for _, file := range removed {
+		err = os.Remove(filepath.Join(p.Path, file))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// The directories the file leaves empty are removed too
+		for dir := filepath.Dir(file); dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(filepath.Join(p.Path, dir)) != nil {
+				break
+			}
+		}
+	}
+
+	for file, obj := range source {
+		if !s.includes(file) {
+			continue
+		}
+		err = p.extractBlob(file, obj.Hash, s.attributer())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
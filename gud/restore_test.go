@@ -0,0 +1,68 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_Restore(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	newPath := filepath.Join(testDir, "dir", "new")
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("first"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("first")
+	first, _ := p.CurrentHash()
+
+	_ = ioutil.WriteFile(testPath, []byte("second"), 0644)
+	_ = os.Mkdir(filepath.Dir(newPath), dirPerm)
+	_ = ioutil.WriteFile(newPath, []byte("new"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("second")
+
+	_ = ioutil.WriteFile(testPath, []byte("staged"), 0644)
+	_ = p.Add(testPath)
+	_ = ioutil.WriteFile(testPath, []byte("unstaged"), 0644)
+
+	err := p.Restore(RestoreOptions{}, testPath)
+	if err != nil {
+		t.Fatal("failed to restore from the index:", err)
+	}
+	if data, _ := ioutil.ReadFile(testPath); string(data) != "staged" {
+		t.Errorf("the file was restored to %q instead of the staged content", data)
+	}
+
+	err = p.Restore(RestoreOptions{Staged: true}, testPath)
+	if err != nil {
+		t.Fatal("failed to restore the index:", err)
+	}
+	index, _ := loadIndex(p.gudPath)
+	if len(index) != 0 {
+		t.Error("the file was not unstaged:", index)
+	}
+	if data, _ := ioutil.ReadFile(testPath); string(data) != "staged" {
+		t.Error("restoring the index changed the working tree")
+	}
+
+	err = p.Restore(RestoreOptions{Source: first, Staged: true, WorkTree: true}, testDir)
+	if err != nil {
+		t.Fatal("failed to restore from a version:", err)
+	}
+	if data, _ := ioutil.ReadFile(testPath); string(data) != "first" {
+		t.Errorf("the file was restored to %q", data)
+	}
+	if _, err = os.Stat(filepath.Dir(newPath)); !os.IsNotExist(err) {
+		t.Error("a file that is not in the version was kept")
+	}
+
+	_, _ = p.Save("restored")
+	current, _ := p.CurrentHash()
+	diffs, _ := p.DiffVersions(*first, *current)
+	if len(diffs) != 0 {
+		t.Error("the restored version differs from the source:", diffs)
+	}
+}
@@ -0,0 +1,124 @@
+package gud
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionRule keeps the checkpoints that are younger than Within, the latest one of every period of Every,
+// or all of them if Every is empty. Durations are written like "90m", "36h" or "7d".
+type RetentionRule struct {
+	Within string
+	Every  string
+}
+
+// DefaultRetention keeps every checkpoint of the last hour, one for every hour of the last day,
+// and one for every day of the last week.
+var DefaultRetention = []RetentionRule{
+	{Within: "1h"},
+	{Within: "1d", Every: "1h"},
+	{Within: "7d", Every: "1d"},
+}
+
+// parseRetentionDuration parses a duration of a retention rule, which may also be given in days.
+func parseRetentionDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, Error{"invalid duration in the retention policy: " + s}
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, Error{"invalid duration in the retention policy: " + s}
+	}
+	return d, nil
+}
+
+// retained returns which of the times of checkpoints, from the newest to the oldest, are kept by the rules at now.
+// The newest checkpoint is always kept.
+func retained(times []time.Time, rules []RetentionRule, now time.Time) ([]bool, error) {
+	keep := make([]bool, len(times))
+	if len(times) > 0 {
+		keep[0] = true
+	}
+
+	for _, rule := range rules {
+		within, err := parseRetentionDuration(rule.Within)
+		if err != nil {
+			return nil, err
+		}
+		var every time.Duration
+		if rule.Every != "" {
+			every, err = parseRetentionDuration(rule.Every)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// The periods are aligned to fixed times, so a kept checkpoint stays kept while newer ones are added
+		seen := make(map[time.Time]bool)
+		for i, t := range times {
+			if now.Sub(t) > within {
+				continue
+			}
+			if every == 0 {
+				keep[i] = true
+				continue
+			}
+			period := t.Truncate(every)
+			if !seen[period] {
+				seen[period] = true
+				keep[i] = true
+			}
+		}
+	}
+
+	return keep, nil
+}
+
+// pruneCheckpoints removes the checkpoints that the configuration does not keep.
+// Without a retention policy, only the latest Checkpoints are kept.
+func (p Project) pruneCheckpoints(config Config) error {
+	head, err := p.CurrentHash()
+	if err != nil {
+		return err
+	}
+
+	var hashes []ObjectHash
+	var versions []Version
+	for hash := head; ; {
+		version, err := loadVersion(p.gudPath, *hash)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, *hash)
+		versions = append(versions, *version)
+		if !version.HasPrev() {
+			break
+		}
+		hash = version.prev
+	}
+
+	var keep []bool
+	if len(config.Retention) > 0 {
+		times := make([]time.Time, len(versions))
+		for i, version := range versions {
+			times[i] = version.Time
+		}
+		keep, err = retained(times, config.Retention, time.Now())
+		if err != nil {
+			return err
+		}
+	} else {
+		keep = make([]bool, len(versions))
+		for i := range keep {
+			keep[i] = i < config.Checkpoints
+		}
+	}
+
+	return pruneVersions(p.gudPath, hashes, versions, keep)
+}
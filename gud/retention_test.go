@@ -0,0 +1,79 @@
+package gud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetained(t *testing.T) {
+	now := time.Date(2020, 5, 10, 12, 30, 0, 0, time.UTC)
+	times := []time.Time{
+		now.Add(-time.Minute),
+		now.Add(-10 * time.Minute),
+		now.Add(-2 * time.Hour),
+		now.Add(-150 * time.Minute), // in the same hour as the one before it
+		now.Add(-5 * time.Hour),
+		now.Add(-3 * 24 * time.Hour),
+		now.Add(-10 * 24 * time.Hour),
+	}
+	expected := []bool{true, true, true, false, true, true, false}
+
+	keep, err := retained(times, DefaultRetention, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected {
+		if keep[i] != expected[i] {
+			t.Errorf("checkpoint %d: kept is %v instead of %v", i, keep[i], expected[i])
+		}
+	}
+
+	_, err = retained(times, []RetentionRule{{Within: "a week"}}, now)
+	if err == nil {
+		t.Error("invalid duration was accepted")
+	}
+}
+
+func TestProject_CheckpointRetention(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	var config Config
+	_ = p.LoadConfig(&config)
+	config.Retention = []RetentionRule{{Within: "0s"}} // only the latest checkpoint
+	_ = p.WriteConfig(config)
+
+	for _, data := range []string{"first", "second", "third"} {
+		_ = ioutil.WriteFile(testPath, []byte(data), 0644)
+		err := p.Checkpoint(data)
+		if err != nil {
+			t.Fatal("failed to checkpoint:", err)
+		}
+	}
+
+	inner := p.innerProject()
+	version, err := inner.CurrentVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Message != "third" || version.HasPrev() {
+		t.Error("old checkpoints were kept")
+	}
+
+	config.Retention = nil
+	config.Checkpoints = 2
+	_ = p.WriteConfig(config)
+	for _, data := range []string{"fourth", "fifth", "sixth"} {
+		_ = ioutil.WriteFile(testPath, []byte(data), 0644)
+		_ = p.Checkpoint(data)
+	}
+
+	version, _ = inner.CurrentVersion()
+	_, prev, err := inner.Prev(*version)
+	if err != nil || prev.Message != "fifth" || prev.HasPrev() {
+		t.Error("the checkpoints were not limited to the configured number")
+	}
+}
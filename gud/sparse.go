@@ -0,0 +1,144 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const sparseFilePath = "sparse"
+
+// sparseSet is the paths of the project that are checked out, with everything inside them.
+// An empty set has the whole project.
+type sparseSet []string
+
+// SparsePaths returns the paths the working tree is limited to, or nil if it has the whole project.
+func (p Project) SparsePaths() ([]string, error) {
+	return loadSparse(p.gudPath)
+}
+
+// SetSparse limits the working tree to some paths of the project, or returns it to the whole project
+// if there are none. Files outside the paths are removed from the working tree, but stay in the versions,
+// and are kept unchanged by saves, checkouts and merges.
+func (p Project) SetSparse(paths ...string) error {
+	err := p.assertNoChanges()
+	if err != nil {
+		return err
+	}
+
+	prev, err := loadSparse(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	var set sparseSet
+	for _, path := range paths {
+		rel, err := p.relPath(path)
+		if err != nil {
+			return err
+		}
+		if rel == "." { // the whole project
+			set = nil
+			break
+		}
+		set = append(set, rel)
+	}
+	sort.Strings(set)
+
+	version, err := p.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	root, err := loadTree(p.gudPath, version.TreeHash)
+	if err != nil {
+		return err
+	}
+
+	// Remove the files that leave the set, and then the directories they leave empty
+	err = walkObjects(p.gudPath, "", root, func(relPath string, obj object) error {
+		if !prev.includes(relPath) || set.includes(relPath) {
+			return nil
+		}
+
+		err := os.Remove(filepath.Join(p.Path, relPath))
+		if obj.Type == typeTree || os.IsNotExist(err) { // directories are kept if other files are left in them
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = dumpSparse(p.gudPath, set)
+	if err != nil {
+		return err
+	}
+
+	// Bring the files that enter the set
+	return p.removeChanges(root, nil)
+}
+
+// includes returns true if a path is in the set.
+func (set sparseSet) includes(relPath string) bool {
+	if len(set) == 0 {
+		return true
+	}
+	for _, path := range set {
+		if relPath == path || strings.HasPrefix(relPath, path+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible returns true if a path is in the set, or is a directory that has paths of the set inside it.
+func (set sparseSet) visible(relPath string, isDir bool) bool {
+	if set.includes(relPath) {
+		return true
+	}
+	if !isDir {
+		return false
+	}
+	for _, path := range set {
+		if relPath == "." || strings.HasPrefix(path, relPath+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadSparse(gudPath string) (sparseSet, error) {
+	file, err := os.Open(filepath.Join(gudPath, sparseFilePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var set sparseSet
+	err = gob.NewDecoder(file).Decode(&set)
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func dumpSparse(gudPath string, set sparseSet) error {
+	if len(set) == 0 {
+		err := removeMetadata(gudPath, sparseFilePath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return writeMetadata(gudPath, sparseFilePath, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(set)
+	})
+}
@@ -0,0 +1,64 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_SetSparse(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	insidePath := filepath.Join(testDir, "inside", "file")
+	outsidePath := filepath.Join(testDir, "outside", "file")
+	_ = os.MkdirAll(filepath.Dir(insidePath), dirPerm)
+	_ = os.MkdirAll(filepath.Dir(outsidePath), dirPerm)
+	_ = ioutil.WriteFile(insidePath, []byte("inside"), 0644)
+	_ = ioutil.WriteFile(outsidePath, []byte("outside"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("both")
+
+	_ = p.CreateBranch("feature")
+	_ = p.CheckoutBranch("feature")
+	_ = ioutil.WriteFile(outsidePath, []byte("feature"), 0644)
+	_ = p.Add(outsidePath)
+	_, _ = p.Save("feature change")
+	_ = p.CheckoutBranch(FirstBranchName)
+
+	err := p.SetSparse(filepath.Join(testDir, "inside"))
+	if err != nil {
+		t.Fatal("failed to set the sparse paths:", err)
+	}
+	if _, err = os.Stat(filepath.Dir(outsidePath)); !os.IsNotExist(err) {
+		t.Error("a path outside the set was left in the working tree")
+	}
+
+	_ = ioutil.WriteFile(insidePath, []byte("changed"), 0644)
+	_ = p.AddAll()
+	_, err = p.Save("inside change")
+	if err != nil {
+		t.Fatal("failed to save:", err)
+	}
+	current, _ := p.CurrentHash()
+	if obj, _ := p.findObject(filepath.Join("outside", "file"), *current); obj == nil {
+		t.Error("the tree outside the set was not carried forward")
+	}
+
+	_, err = p.MergeBranch("feature", MergeOptions{})
+	if err != nil {
+		t.Fatal("failed to merge:", err)
+	}
+	if _, err = os.Stat(outsidePath); !os.IsNotExist(err) {
+		t.Error("a merge wrote outside the set")
+	}
+
+	err = p.SetSparse()
+	if err != nil {
+		t.Fatal("failed to disable the sparse paths:", err)
+	}
+	if data, _ := ioutil.ReadFile(outsidePath); string(data) != "feature" {
+		t.Error("the merged file outside the set was not checked out")
+	}
+}
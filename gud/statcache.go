@@ -0,0 +1,150 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const statCacheFilePath = "stat-cache"
+
+// racyWindow is the coarsest timestamp granularity of the supported file systems.
+// A file modified this close to the time its content was checked might have been modified again
+// without its mtime changing, so it is said to be racily clean and its content is always compared.
+const racyWindow = 2 * time.Second
+
+// statEntry records that a file had the contents of the blob Hash while it had the rest of the fields.
+// Text and Eol are the attributes the file was normalized with, which change what the same file is saved as.
+type statEntry struct {
+	Hash    ObjectHash
+	Size    int64
+	Mtime   time.Time
+	Inode   uint64
+	Text    textMode
+	Eol     string
+	Checked time.Time // when the content of the file was compared to the blob
+}
+
+type statCacheFile struct {
+	Version PackageVersion
+	Entries map[string]statEntry
+}
+
+// statCache lets unchanged tracked files be found without reading them.
+// A nil cache caches nothing.
+type statCache struct {
+	entries map[string]statEntry
+	seen    map[string]bool // the paths that were looked up since the cache was loaded
+	dirty   bool
+}
+
+func (p Project) loadStatCache() (*statCache, error) {
+	cache := &statCache{
+		entries: make(map[string]statEntry),
+		seen:    make(map[string]bool),
+	}
+
+	file, err := os.Open(filepath.Join(p.gudPath, statCacheFilePath))
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cacheFile statCacheFile
+	err = gob.NewDecoder(file).Decode(&cacheFile)
+	if err != nil || cacheFile.Version != GetVersion() { // a broken cache is rebuilt
+		return cache, nil
+	}
+	if cacheFile.Entries != nil {
+		cache.entries = cacheFile.Entries
+	}
+
+	return cache, nil
+}
+
+func (p Project) dumpStatCache(cache *statCache) error {
+	if cache == nil || !cache.dirty {
+		return nil
+	}
+
+	return writeAtomic(p.gudPath, filepath.Join(p.gudPath, statCacheFilePath), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(statCacheFile{
+			Version: GetVersion(),
+			Entries: cache.entries,
+		})
+	})
+}
+
+// prune forgets the files that were not looked up, after the whole working tree was compared.
+func (cache *statCache) prune() {
+	if cache == nil {
+		return
+	}
+
+	for relPath := range cache.entries {
+		if !cache.seen[relPath] {
+			delete(cache.entries, relPath)
+			cache.dirty = true
+		}
+	}
+}
+
+// sameAsObject returns true if a file has the contents of a blob.
+// The file is read only if the cache does not already know that it is unchanged.
+func (p Project) sameAsObject(s *scan, relPath string, hash ObjectHash) (bool, error) {
+	cache := s.cache()
+	if cache == nil {
+		return p.compareToObject(relPath, hash, s.attributer())
+	}
+	cache.seen[relPath] = true
+
+	attrs, err := s.attributer().lookup(relPath)
+	if err != nil {
+		return false, err
+	}
+
+	// The file is inspected before it is read, so a change made while reading it changes its mtime
+	info, err := os.Stat(filepath.Join(p.Path, relPath))
+	if err != nil {
+		return false, err
+	}
+	stat := statEntry{
+		Hash:  hash,
+		Size:  info.Size(),
+		Mtime: info.ModTime(),
+		Inode: inode(info),
+		Text:  attrs.text,
+		Eol:   attrs.eol,
+	}
+
+	entry, ok := cache.entries[relPath]
+	if ok && entry.Hash == hash && entry.Size == stat.Size && entry.Mtime.Equal(stat.Mtime) &&
+		entry.Inode == stat.Inode && entry.Text == stat.Text && entry.Eol == stat.Eol && !entry.racy() {
+		return true, nil
+	}
+
+	same, err := p.compareToObject(relPath, hash, s.attributer())
+	if err != nil {
+		return false, err
+	}
+
+	if same {
+		stat.Checked = time.Now()
+		cache.entries[relPath] = stat
+		cache.dirty = true
+	} else if ok {
+		delete(cache.entries, relPath)
+		cache.dirty = true
+	}
+
+	return same, nil
+}
+
+func (entry statEntry) racy() bool {
+	return !entry.Mtime.Add(racyWindow).Before(entry.Checked)
+}
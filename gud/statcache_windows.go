@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package gud
+
+import "os"
+
+// inode returns 0, since file IDs are not part of the file info on Windows.
+// Replaced files are still noticed by their size and mtime.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -5,19 +5,155 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
 type ChangeCallback func(relPath string, state FileState) error
 type cmpCallback func(relPath string, state FileState, hash *ObjectHash, isDir bool) error
 
-func (p Project) Status(trackedFn, untrackedFn ChangeCallback) error {
+// ProjectStatus is the state of the project: where its head is, which operation is waiting to be finished,
+// and the changes made from the current version.
+type ProjectStatus struct {
+	Head                       // Hash is the current version, even when head is not detached
+	CherryPicking bool         // a cherry-pick stopped on conflicts
+	Rebasing      bool         // a rebase stopped on conflicts
+	Files         []FileStatus // sorted by path
+}
+
+// FileStatus is a file that changed from the current version.
+type FileStatus struct {
+	Path     string
+	From     string    // the path the file was moved from if Staged is StateRenamed
+	Staged   FileState // the change added to the index
+	Working  FileState // the change in the working tree that was not added
+	Conflict *Conflict // the versions of the file if Staged is StateConflict
+}
+
+// IsMerging returns true if a merge is waiting to be saved.
+func (status ProjectStatus) IsMerging() bool {
+	return status.MergedHash != nil
+}
+
+// Status returns the state of the project and its changes.
+func (p Project) Status() (*ProjectStatus, error) {
+	changes, err := p.findChanges()
+	if err != nil {
+		return nil, err
+	}
+	return p.newStatus(*changes)
+}
+
+func (p Project) findChanges() (*statusChanges, error) {
+	var changes statusChanges
+	err := p.walkStatus(
+		func(relPath string, state FileState) error {
+			changes.Tracked = append(changes.Tracked, Change{relPath, state})
+			return nil
+		},
+		func(relPath string, state FileState) error {
+			changes.Untracked = append(changes.Untracked, Change{relPath, state})
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
+
+// statusChanges are the changes found by walkStatus, in the order it finds them.
+type statusChanges struct {
+	Tracked, Untracked []Change
+}
+
+// Change is a file that changed.
+type Change struct {
+	Path  string
+	State FileState
+}
+
+// newStatus returns the status of the project with some changes.
+func (p Project) newStatus(changes statusChanges) (*ProjectStatus, error) {
+	head, err := loadHead(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+	current, err := p.CurrentHash()
+	if err != nil {
+		return nil, err
+	}
+	index, err := loadIndex(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := p.Conflicts()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ProjectStatus{
+		Head:          *head,
+		CherryPicking: p.IsCherryPicking(),
+		Rebasing:      p.IsRebasing(),
+	}
+	status.Hash = *current
+
+	files := make(map[string]*FileStatus)
+	file := func(relPath string) *FileStatus {
+		if files[relPath] == nil {
+			files[relPath] = &FileStatus{Path: relPath, Staged: StateUnchanged, Working: StateUnchanged}
+		}
+		return files[relPath]
+	}
+	moved := renames(index)
+	for _, change := range changes.Tracked {
+		file(change.Path).Staged = change.State
+		if change.State == StateRenamed {
+			file(change.Path).From = moved[change.Path]
+		}
+	}
+	for _, change := range changes.Untracked {
+		file(change.Path).Working = change.State
+	}
+	for i := range conflicts {
+		file(conflicts[i].Path).Conflict = &conflicts[i]
+	}
+
+	status.Files = make([]FileStatus, 0, len(files))
+	for _, file := range files {
+		status.Files = append(status.Files, *file)
+	}
+	sort.Slice(status.Files, func(i, j int) bool {
+		return status.Files[i].Path < status.Files[j].Path
+	})
+
+	return status, nil
+}
+
+// walkStatus calls trackedFn for every change in the index, and then untrackedFn for every change
+// in the working tree that was not added to it.
+func (p Project) walkStatus(trackedFn, untrackedFn ChangeCallback) error {
 	index, err := loadIndex(p.gudPath)
 	if err != nil {
 		return err
 	}
 
+	// A renamed file is reported once, without the removal of the path it was moved from
+	moved := renames(index)
+	sources := make(map[string]bool)
+	for _, from := range moved {
+		sources[from] = true
+	}
 	for _, entry := range index {
-		err = trackedFn(entry.Path, entry.State)
+		state := entry.State
+		if sources[entry.Path] {
+			continue
+		}
+		if _, ok := moved[entry.Path]; ok {
+			state = StateRenamed
+		}
+
+		err = trackedFn(entry.Path, state)
 		if err != nil {
 			return err
 		}
@@ -33,14 +169,88 @@ func (p Project) Status(trackedFn, untrackedFn ChangeCallback) error {
 		return err
 	}
 
-	return p.compareTree(".", root, index,
+	s, err := p.loadScan()
+	if err != nil {
+		return err
+	}
+
+	err = p.compareTree(".", root, index, s,
 		func(relPath string, state FileState, hash *ObjectHash, isDir bool) error {
 			return untrackedFn(relPath, state)
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	s.cache().prune()
+	return p.dumpScan(s)
+}
+
+// scan holds what comparing the working tree to a tree needs besides them:
+// the ignore rules of untracked files, the stat cache, the sparse set and the attributes of files.
+// A nil scan ignores nothing, reads every file, sees the whole project and converts nothing.
+type scan struct {
+	ig     *ignorer
+	stats  *statCache
+	sparse sparseSet
+	attrs  *attributer
+}
+
+func (p Project) loadScan() (*scan, error) {
+	ig, err := p.loadIgnorer()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := p.loadStatCache()
+	if err != nil {
+		return nil, err
+	}
+	sparse, err := loadSparse(p.gudPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scan{ig: ig, stats: stats, sparse: sparse, attrs: p.loadAttributer()}, nil
+}
+
+func (p Project) dumpScan(s *scan) error {
+	return p.dumpStatCache(s.cache())
+}
+
+func (s *scan) ignored(relPath string, isDir bool) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	return s.ig.ignored(relPath, isDir)
+}
+
+func (s *scan) cache() *statCache {
+	if s == nil {
+		return nil
+	}
+	return s.stats
+}
+
+func (s *scan) attributer() *attributer {
+	if s == nil {
+		return nil
+	}
+	return s.attrs
+}
+
+func (s *scan) visible(relPath string, isDir bool) bool {
+	return s == nil || s.sparse.visible(relPath, isDir)
 }
 
-func (p Project) compareTree(relPath string, root tree, index []indexEntry, fn cmpCallback) error {
+func (s *scan) includes(relPath string) bool {
+	return s == nil || s.sparse.includes(relPath)
+}
+
+// compareTree reports the differences between a directory in the working tree and a tree.
+// Untracked paths that are ignored are not reported, files that are known to be unchanged are not read,
+// and paths outside the sparse set are skipped on both sides.
+func (p Project) compareTree(relPath string, root tree, index []indexEntry, s *scan, fn cmpCallback) error {
 	dir, err := ioutil.ReadDir(filepath.Join(p.Path, relPath))
 	if err != nil {
 		return err
@@ -49,14 +259,35 @@ func (p Project) compareTree(relPath string, root tree, index []indexEntry, fn c
 	// dont enter .gud
 	relGudPath, _ := filepath.Rel(p.Path, p.gudPath)
 	if relPath == filepath.Dir(relGudPath) {
-		gudBasePath := filepath.Base(relGudPath)
-		ind := sort.Search(len(dir), func(i int) bool {
-			return gudBasePath <= dir[i].Name()
-		})
-		if ind < len(dir) && dir[ind].Name() == gudBasePath {
-			copy(dir[ind:], dir[ind+1:])
-			dir = dir[:len(dir)-1]
+		skipped := []string{filepath.Base(relGudPath)}
+		if p.isCheckpoints() { // the local files of the outer project are next to .gud
+			skipped = append(skipped, localMetadata...)
+		}
+		for _, name := range skipped {
+			ind := sort.Search(len(dir), func(i int) bool {
+				return name <= dir[i].Name()
+			})
+			if ind < len(dir) && dir[ind].Name() == name {
+				copy(dir[ind:], dir[ind+1:])
+				dir = dir[:len(dir)-1]
+			}
+		}
+	}
+
+	if s != nil && len(s.sparse) > 0 {
+		var visibleDir []os.FileInfo
+		for _, info := range dir {
+			if s.visible(filepath.Join(relPath, info.Name()), info.IsDir()) {
+				visibleDir = append(visibleDir, info)
+			}
 		}
+		var visibleRoot tree
+		for _, obj := range root {
+			if s.visible(filepath.Join(relPath, obj.Name), obj.Type == typeTree) {
+				visibleRoot = append(visibleRoot, obj)
+			}
+		}
+		dir, root = visibleDir, visibleRoot
 	}
 
 	fileInd := 0
@@ -68,14 +299,14 @@ func (p Project) compareTree(relPath string, root tree, index []indexEntry, fn c
 		childPath := filepath.Join(relPath, basePath)
 
 		if basePath < obj.Name { // new file/dir
-			err = p.reportNew(childPath, info.IsDir(), index, fn)
+			err = p.reportNew(childPath, info.IsDir(), index, s, fn)
 			if err != nil {
 				return err
 			}
 
 			fileInd++
 		} else if obj.Name < basePath { // removed file/dir
-			err = reportRemoved(p.gudPath, relPath, obj, index, fn)
+			err = reportRemoved(p.gudPath, relPath, obj, index, s, fn)
 			if err != nil {
 				return err
 			}
@@ -83,33 +314,33 @@ func (p Project) compareTree(relPath string, root tree, index []indexEntry, fn c
 			objInd++
 		} else {
 			if obj.Type == typeBlob && info.IsDir() { // removed file and added directory
-				err = fn(relPath, StateRemoved, &obj.Hash, false)
+				err = fn(childPath, StateRemoved, &obj.Hash, false)
 				if err != nil {
 					return err
 				}
-				err = p.reportNewDir(childPath, index, fn)
+				err = p.reportNewDir(childPath, index, s, fn)
 				if err != nil {
 					return err
 				}
 
 			} else if obj.Type == typeTree && !info.IsDir() { // removed directory and added file
-				err = reportRemovedDir(p.gudPath, childPath, obj.Hash, index, fn)
+				err = reportRemovedDir(p.gudPath, childPath, obj.Hash, index, s, fn)
 				if err != nil {
 					return err
 				}
-				err = fn(relPath, StateNew, nil, false)
+				err = p.reportNewFile(childPath, index, s, fn)
 				if err != nil {
 					return err
 				}
 
 			} else if info.IsDir() {
-				err = p.compareDir(childPath, obj.Hash, index, fn)
+				err = p.compareDir(childPath, obj.Hash, index, s, fn)
 				if err != nil {
 					return err
 				}
 
 			} else {
-				err = p.compareFile(childPath, obj.Hash, index, fn)
+				err = p.compareFile(childPath, obj.Hash, index, s, fn)
 				if err != nil {
 					return err
 				}
@@ -122,41 +353,61 @@ func (p Project) compareTree(relPath string, root tree, index []indexEntry, fn c
 
 	for ; fileInd < len(dir); fileInd++ {
 		info := dir[fileInd]
-		err = p.reportNew(filepath.Join(relPath, info.Name()), info.IsDir(), index, fn)
+		err = p.reportNew(filepath.Join(relPath, info.Name()), info.IsDir(), index, s, fn)
+		if err != nil {
+			return err
+		}
 	}
 	for ; objInd < len(root); objInd++ {
 		obj := root[objInd]
-		err = reportRemoved(p.gudPath, relPath, obj, index, fn)
+		err = reportRemoved(p.gudPath, relPath, obj, index, s, fn)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (p Project) reportNew(relPath string, isDir bool, index []indexEntry, fn cmpCallback) error {
+func (p Project) reportNew(relPath string, isDir bool, index []indexEntry, s *scan, fn cmpCallback) error {
 	if isDir {
-		return p.reportNewDir(relPath, index, fn)
+		return p.reportNewDir(relPath, index, s, fn)
 	}
-	return p.reportNewFile(relPath, index, fn)
+	return p.reportNewFile(relPath, index, s, fn)
 }
 
-func reportRemoved(gudPath, parentPath string, obj object, index []indexEntry, fn cmpCallback) error {
+func reportRemoved(gudPath, parentPath string, obj object, index []indexEntry, s *scan, fn cmpCallback) error {
 	relPath := filepath.Join(parentPath, obj.Name)
 	if obj.Type == typeTree {
-		return reportRemovedDir(gudPath, relPath, obj.Hash, index, fn)
+		return reportRemovedDir(gudPath, relPath, obj.Hash, index, s, fn)
 	}
 	return reportRemovedFile(relPath, obj.Hash, index, fn)
 }
 
-func (p Project) compareDir(relPath string, hash ObjectHash, index []indexEntry, fn cmpCallback) error {
+func (p Project) compareDir(relPath string, hash ObjectHash, index []indexEntry, s *scan, fn cmpCallback) error {
 	inner, err := loadTree(p.gudPath, hash)
 	if err != nil {
 		return err
 	}
 
-	return p.compareTree(relPath, inner, index, fn)
+	return p.compareTree(relPath, inner, index, s, fn)
 }
 
-func (p Project) reportNewDir(relPath string, index []indexEntry, fn cmpCallback) error {
+// reportNewDir reports the files of a new directory.
+// Directories are reported before the first file in them, so ones that hold only ignored files are not.
+func (p Project) reportNewDir(relPath string, index []indexEntry, s *scan, fn cmpCallback) error {
+	var pending []string // the new directories above the current path that were not reported yet
+	report := func(relPath string, state FileState, hash *ObjectHash, isDir bool) error {
+		for _, dir := range pending {
+			err := fn(dir, StateNew, nil, true)
+			if err != nil {
+				return err
+			}
+		}
+		pending = nil
+		return fn(relPath, state, hash, isDir)
+	}
+
 	return filepath.Walk(filepath.Join(p.Path, relPath), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -164,25 +415,55 @@ func (p Project) reportNewDir(relPath string, index []indexEntry, fn cmpCallback
 		if path == p.gudPath {
 			return filepath.SkipDir
 		}
+		if p.isCheckpoints() && filepath.Dir(path) == filepath.Dir(p.gudPath) && isLocalMetadata(filepath.Base(path)) {
+			return nil
+		}
 
 		newRelPath, err := filepath.Rel(p.Path, path)
 		if err != nil {
 			return err
 		}
 
+		for len(pending) > 0 && !strings.HasPrefix(newRelPath, pending[len(pending)-1]+string(os.PathSeparator)) {
+			pending = pending[:len(pending)-1]
+		}
+
 		if info.IsDir() {
-			return fn(newRelPath, StateNew, nil, true)
+			if !s.visible(newRelPath, true) {
+				return filepath.SkipDir
+			}
+			ignored, err := s.ignored(newRelPath, true)
+			if err != nil {
+				return err
+			}
+			if ignored {
+				return filepath.SkipDir
+			}
+			pending = append(pending, newRelPath)
+			return nil
 		}
-		return p.reportNewFile(newRelPath, index, fn)
+		if !s.includes(newRelPath) {
+			return nil
+		}
+		return p.reportNewFile(newRelPath, index, s, report)
 	})
 }
 
-func (p Project) reportNewFile(relPath string, index []indexEntry, fn cmpCallback) error {
+func (p Project) reportNewFile(relPath string, index []indexEntry, s *scan, fn cmpCallback) error {
 	ind, tracked := findEntry(index, relPath)
+	if !tracked {
+		ignored, err := s.ignored(relPath, false)
+		if err != nil || ignored {
+			return err
+		}
+	}
 	if tracked {
 		entry := index[ind]
+		if entry.State == StateConflict { // reported with the index
+			return nil
+		}
 		if entry.State == StateNew || entry.State == StateModified {
-			same, err := p.compareToObject(relPath, entry.Hash)
+			same, err := p.sameAsObject(s, relPath, entry.Hash)
 			if err != nil {
 				return err
 			}
@@ -205,37 +486,56 @@ func reportRemovedFile(relPath string, hash ObjectHash, index []indexEntry, fn c
 	return nil
 }
 
-func reportRemovedDir(gudPath, relPath string, hash ObjectHash, index []indexEntry, fn cmpCallback) error {
+func reportRemovedDir(gudPath, relPath string, hash ObjectHash, index []indexEntry, s *scan, fn cmpCallback) error {
 	tree, err := loadTree(gudPath, hash)
 	if err != nil {
 		return err
 	}
 
+	// A directory is reported only if some of its files are, and not if they were all removed in the index
+	root := relPath
+	changed := make(map[string]bool)
+	report := func(relPath string, state FileState, hash *ObjectHash, isDir bool) error {
+		for dir := filepath.Dir(relPath); !changed[dir] && dir != filepath.Dir(root); dir = filepath.Dir(dir) {
+			changed[dir] = true
+		}
+		return fn(relPath, state, hash, isDir)
+	}
+
 	err = walkObjects(gudPath, relPath, tree, func(relPath string, obj object) error {
+		if !s.includes(relPath) {
+			return nil
+		}
 		if obj.Type == typeBlob {
-			return reportRemovedFile(relPath, obj.Hash, index, fn)
+			return reportRemovedFile(relPath, obj.Hash, index, report)
+		}
+		if !changed[relPath] {
+			return nil
 		}
 		return fn(relPath, StateRemoved, &obj.Hash, true)
 	})
-	if err != nil {
+	if err != nil || !s.includes(relPath) || !changed[relPath] {
 		return err
 	}
 
 	return fn(relPath, StateRemoved, &hash, true)
 }
 
-func (p Project) compareFile(relPath string, hash ObjectHash, index []indexEntry, fn cmpCallback) error {
+func (p Project) compareFile(relPath string, hash ObjectHash, index []indexEntry, s *scan, fn cmpCallback) error {
 	ind, tracked := findEntry(index, relPath)
 	if tracked {
 		entry := index[ind]
 		if entry.State == StateRemoved { // file was deleted and then added
 			return fn(relPath, StateNew, nil, false)
 		}
+		if entry.State == StateConflict { // reported with the index
+			return nil
+		}
 
 		hash = entry.Hash
 	}
 
-	same, err := p.compareToObject(relPath, hash)
+	same, err := p.sameAsObject(s, relPath, hash)
 	if err != nil {
 		return err
 	}
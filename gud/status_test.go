@@ -3,8 +3,10 @@ package gud
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestProject_Status(t *testing.T) {
@@ -16,49 +18,114 @@ func TestProject_Status(t *testing.T) {
 	p, _ := Start(testDir)
 	_ = ioutil.WriteFile(testPath, data, 0644)
 
-	err := p.Status(
-		func(relPath string, state FileState) error {
-			return fmt.Errorf("file %s (%d) is not tracked", relPath, state)
-		},
-		func(relPath string, state FileState) error {
-			if relPath != testFile || state != StateNew {
-				return fmt.Errorf("unexpected %s (%d)", relPath, state)
+	expect := func(staged, working FileState) error {
+		status, err := p.Status()
+		if err != nil {
+			return err
+		}
+		if status.Branch != FirstBranchName || status.IsDetached || status.IsMerging() {
+			return fmt.Errorf("unexpected head %+v", status.Head)
+		}
+		if staged == StateUnchanged && working == StateUnchanged {
+			if len(status.Files) != 0 {
+				return fmt.Errorf("unexpected changes %+v", status.Files)
 			}
 			return nil
-		},
-	)
+		}
+		if len(status.Files) != 1 || status.Files[0].Path != testFile ||
+			status.Files[0].Staged != staged || status.Files[0].Working != working {
+			return fmt.Errorf("unexpected changes %+v", status.Files)
+		}
+		return nil
+	}
+
+	err := expect(StateUnchanged, StateNew)
 	if err != nil {
 		t.Error(err)
 	}
 
 	_ = p.Add(testPath)
 
-	err = p.Status(
-		func(relPath string, state FileState) error {
-			if relPath != testFile || state != StateNew {
-				return fmt.Errorf("unexpected %s (%d)", relPath, state)
-			}
-			return nil
-		},
-		func(relPath string, state FileState) error {
-			return fmt.Errorf("file %s (%d) is tracked", relPath, state)
-		},
-	)
+	err = expect(StateNew, StateUnchanged)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_ = ioutil.WriteFile(testPath, []byte("changed after it was added"), 0644)
+
+	err = expect(StateNew, StateModified)
 	if err != nil {
 		t.Error(err)
 	}
 
+	_ = p.Add(testPath)
 	_, _ = p.Save("added test file")
 
-	err = p.Status(
-		func(relPath string, state FileState) error {
-			return fmt.Errorf("unexpected tracked %s (%d)", relPath, state)
-		},
-		func(relPath string, state FileState) error {
-			return fmt.Errorf("unexpected untracked %s (%d)", relPath, state)
-		},
-	)
+	err = expect(StateUnchanged, StateUnchanged)
 	if err != nil {
 		t.Error(err)
 	}
 }
+
+func TestProject_StatusStatCache(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("first"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("first")
+
+	var modified []string
+	untracked := func(relPath string, state FileState) error {
+		if state == StateModified {
+			modified = append(modified, relPath)
+		}
+		return nil
+	}
+	tracked := func(relPath string, state FileState) error { return nil }
+
+	// A file changed right after it was checked keeps its mtime on coarse file systems
+	mtime := time.Now()
+	_ = os.Chtimes(testPath, mtime, mtime)
+	_ = p.walkStatus(tracked, untracked)
+	_ = ioutil.WriteFile(testPath, []byte("racy!"), 0644)
+	_ = os.Chtimes(testPath, mtime, mtime)
+	_ = p.walkStatus(tracked, untracked)
+	if len(modified) != 1 {
+		t.Error("racily clean file was trusted:", modified)
+	}
+
+	// An old enough file is not read again while it looks the same
+	modified = nil
+	_ = ioutil.WriteFile(testPath, []byte("first"), 0644)
+	mtime = time.Now().Add(-time.Hour)
+	_ = os.Chtimes(testPath, mtime, mtime)
+	_ = p.walkStatus(tracked, untracked)
+	_ = ioutil.WriteFile(testPath, []byte("other"), 0644)
+	_ = os.Chtimes(testPath, mtime, mtime)
+	_ = p.walkStatus(tracked, untracked)
+	if len(modified) != 0 {
+		t.Error("cached file was read again:", modified)
+	}
+
+	mtime = mtime.Add(time.Second)
+	_ = os.Chtimes(testPath, mtime, mtime)
+	_ = p.walkStatus(tracked, untracked)
+	if len(modified) != 1 {
+		t.Error("changed mtime did not invalidate the cache:", modified)
+	}
+
+	// Attributes change what the same file is saved as
+	_ = ioutil.WriteFile(testPath, []byte("a\r\nb\r\n"), 0644)
+	_ = p.Add(testPath)
+	_, _ = p.Save("crlf")
+	modified = nil
+	_ = os.Chtimes(testPath, mtime, mtime)
+	_ = p.walkStatus(tracked, untracked)
+	_ = ioutil.WriteFile(filepath.Join(testDir, AttributesFileName), []byte("* text\n"), 0644)
+	_ = p.walkStatus(tracked, untracked)
+	if len(modified) != 1 {
+		t.Error("changed attributes did not invalidate the cache:", modified)
+	}
+}
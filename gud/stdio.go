@@ -0,0 +1,335 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io"
+	"os/exec"
+)
+
+// The requests of a stdio transport
+const (
+	stdioBranches = "branches"
+	stdioIncoming = "incoming"
+	stdioReceive  = "receive"
+	stdioSend     = "send"
+)
+
+// stdioChunkSize is the most data sent in a chunk of a stream.
+const stdioChunkSize = 32 * 1024
+
+// stdioRequest is a request of a stdio transport. The requests and responses are gob encoded, and the streams
+// of versions that follow receive requests and send responses are written as chunks, which are byte slices
+// that end with an empty one. After a sent stream, another response reports whether it was written whole.
+type stdioRequest struct {
+	Op          string
+	Branch      string
+	Options     PushOptions
+	ContentType string
+}
+
+type stdioResponse struct {
+	Error          string
+	Input          bool   // the error is an InputError
+	NonFastForward string // the branch of a NonFastForwardError
+	Branches       map[string]ObjectHash
+	ID             string
+	Skip           int
+	ContentType    string
+}
+
+func errorResponse(err error) stdioResponse {
+	if err == nil {
+		return stdioResponse{}
+	}
+	_, input := err.(InputError)
+	resp := stdioResponse{Error: err.Error(), Input: input}
+	if nonFastForward, ok := err.(NonFastForwardError); ok {
+		resp.NonFastForward = nonFastForward.Branch
+	}
+	return resp
+}
+
+func (r stdioResponse) err() error {
+	if r.Error == "" {
+		return nil
+	}
+	if r.NonFastForward != "" {
+		return NonFastForwardError{r.NonFastForward}
+	}
+	if r.Input {
+		return InputError{r.Error}
+	}
+	return Error{r.Error}
+}
+
+// stdioTransport reaches a project through a process that serves it with ServeStdio,
+// like "gud serve-stdio" run locally or through a tunnel.
+type stdioTransport struct {
+	enc   *gob.Encoder
+	dec   *gob.Decoder
+	in    io.Closer
+	wait  func() error
+	reply *chunkReader // the stream that is being read
+}
+
+// NewStdioTransport starts a command that serves a project on its standard input and output,
+// and returns a transport to it.
+func NewStdioTransport(cmd *exec.Cmd) (Transport, error) {
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return newStdioTransport(out, in, cmd.Wait), nil
+}
+
+func newStdioTransport(r io.Reader, w io.WriteCloser, wait func() error) *stdioTransport {
+	return &stdioTransport{enc: gob.NewEncoder(w), dec: gob.NewDecoder(r), in: w, wait: wait}
+}
+
+// call sends a request, after the rest of the stream that is being read, and returns its response.
+func (t *stdioTransport) call(req stdioRequest) (*stdioResponse, error) {
+	if t.reply != nil {
+		err := t.reply.Close()
+		t.reply = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := t.enc.Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.response()
+}
+
+func (t *stdioTransport) response() (*stdioResponse, error) {
+	var resp stdioResponse
+	err := t.dec.Decode(&resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, resp.err()
+}
+
+func (t *stdioTransport) Branches() (map[string]ObjectHash, error) {
+	resp, err := t.call(stdioRequest{Op: stdioBranches})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Branches, nil
+}
+
+func (t *stdioTransport) IncomingTransfer(branch string) (string, int, error) {
+	resp, err := t.call(stdioRequest{Op: stdioIncoming, Branch: branch})
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.ID, resp.Skip, nil
+}
+
+func (t *stdioTransport) Receive(branch string, in io.Reader, contentType string) error {
+	if t.reply != nil {
+		err := t.reply.Close()
+		t.reply = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	err := t.enc.Encode(stdioRequest{Op: stdioReceive, Branch: branch, ContentType: contentType})
+	if err != nil {
+		return err
+	}
+
+	// The stream is ended even if it could not be read whole, for the other project to keep what it received
+	readErr := writeChunks(t.enc, in)
+	_, err = t.response()
+	if readErr != nil {
+		return readErr
+	}
+	return err
+}
+
+func (t *stdioTransport) Send(branch string, options PushOptions) (io.ReadCloser, string, error) {
+	resp, err := t.call(stdioRequest{Op: stdioSend, Branch: branch, Options: options})
+	if err != nil {
+		return nil, "", err
+	}
+
+	t.reply = &chunkReader{dec: t.dec, end: func() error {
+		_, err := t.response()
+		return err
+	}}
+	return t.reply, resp.ContentType, nil
+}
+
+// Close ends the requests, and waits for the serving process to exit.
+func (t *stdioTransport) Close() error {
+	err := t.in.Close()
+	werr := t.wait()
+	if err == nil {
+		err = werr
+	}
+	return err
+}
+
+// ServeStdio serves the requests of a stdio transport from in with another transport, usually a local one,
+// until in is closed.
+func ServeStdio(t Transport, in io.Reader, out io.Writer) error {
+	dec := gob.NewDecoder(in)
+	enc := gob.NewEncoder(out)
+	for {
+		var req stdioRequest
+		err := dec.Decode(&req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var resp stdioResponse
+		switch req.Op {
+		case stdioBranches:
+			var branches map[string]ObjectHash
+			branches, err = t.Branches()
+			resp = errorResponse(err)
+			resp.Branches = branches
+		case stdioIncoming:
+			var id string
+			var skip int
+			id, skip, err = t.IncomingTransfer(req.Branch)
+			resp = errorResponse(err)
+			resp.ID, resp.Skip = id, skip
+		case stdioReceive:
+			stream := &chunkReader{dec: dec}
+			err = t.Receive(req.Branch, stream, req.ContentType)
+			cerr := stream.Close()
+			if cerr != nil {
+				return cerr
+			}
+			resp = errorResponse(err)
+		case stdioSend:
+			err = serveSend(t, enc, req)
+			if err != nil {
+				return err
+			}
+			continue
+		default:
+			resp = stdioResponse{Error: "unknown request: " + req.Op}
+		}
+
+		err = enc.Encode(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// serveSend writes the response to a send request and its stream. It only fails if the response cannot be written.
+func serveSend(t Transport, enc *gob.Encoder, req stdioRequest) error {
+	stream, contentType, err := t.Send(req.Branch, req.Options)
+	if err != nil {
+		return enc.Encode(errorResponse(err))
+	}
+	defer stream.Close()
+
+	err = enc.Encode(stdioResponse{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+
+	err = writeChunks(enc, stream)
+	return enc.Encode(errorResponse(err))
+}
+
+// writeChunks writes what can be read from in as a stream of chunks, which is ended even if reading fails.
+func writeChunks(enc *gob.Encoder, in io.Reader) error {
+	buf := make([]byte, stdioChunkSize)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			eerr := enc.Encode(buf[:n])
+			if eerr != nil {
+				return eerr
+			}
+		}
+		if err == io.EOF {
+			return enc.Encode([]byte{})
+		}
+		if err != nil {
+			_ = enc.Encode([]byte{})
+			return err
+		}
+	}
+}
+
+// chunkReader reads a stream of chunks.
+type chunkReader struct {
+	dec   *gob.Decoder
+	chunk []byte
+	end   func() error // called when the stream ends, for an error the sender reported after it
+	err   error        // io.EOF once the stream ended
+}
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		var chunk []byte
+		err := r.dec.Decode(&chunk)
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			r.err = io.EOF
+			if r.end != nil {
+				err = r.end()
+				if err != nil {
+					r.err = err
+				}
+			}
+			return 0, r.err
+		}
+		r.chunk = chunk
+	}
+
+	n := copy(b, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// Close reads the rest of the stream, so what follows it can be read.
+func (r *chunkReader) Close() error {
+	for r.err == nil {
+		r.chunk = nil
+		_, _ = r.Read(nil)
+	}
+
+	if r.err == io.EOF {
+		return nil
+	}
+	if _, ok := r.err.(Error); ok { // reported by the sender, the transport is still usable
+		return nil
+	}
+	if _, ok := r.err.(InputError); ok {
+		return nil
+	}
+	if _, ok := r.err.(NonFastForwardError); ok {
+		return nil
+	}
+	return r.err
+}
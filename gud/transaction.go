@@ -0,0 +1,381 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const tmpPath = "tmp"
+const journalFilePath = "journal"
+
+// staleTmpAge is how old a temporary file must be for it to be known as left by an interrupted process.
+const staleTmpAge = 24 * time.Hour
+
+// atomicFile is written under a temporary name, and replaces its path only when it is committed,
+// so the file is never seen partly written.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// createAtomic creates a file that will replace path, in the temporary directory of the project,
+// which is on the same file system so the file can be renamed into place.
+func createAtomic(gudPath, path string) (*atomicFile, error) {
+	dir := filepath.Join(gudPath, tmpPath)
+	err := os.MkdirAll(dir, dirPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile(dir, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{file, path}, nil
+}
+
+// Commit closes the file and moves it in place of its path.
+func (f *atomicFile) Commit() error {
+	err := f.File.Close()
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// Discard closes the file and removes it, leaving its path as it was.
+func (f *atomicFile) Discard() {
+	_ = f.File.Close()
+	_ = os.Remove(f.Name())
+}
+
+// writeAtomic replaces a file with what write writes, or leaves it as it was if write fails.
+func writeAtomic(gudPath, path string, write func(w io.Writer) error) error {
+	file, err := createAtomic(gudPath, path)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if err != nil {
+		file.Discard()
+		return err
+	}
+	return file.Commit()
+}
+
+// writeDurable is writeAtomic for files that must be whole even if the system crashes,
+// which are synced before they are moved into place.
+func writeDurable(gudPath, path string, write func(w io.Writer) error) error {
+	file, err := createAtomic(gudPath, path)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Discard()
+		return err
+	}
+	return file.Commit()
+}
+
+// writeMetadata replaces a metadata file of the project durably, after journaling it if a transaction is running.
+func writeMetadata(gudPath, relPath string, write func(w io.Writer) error) error {
+	err := journalFile(gudPath, relPath)
+	if err != nil {
+		return err
+	}
+
+	return writeDurable(gudPath, filepath.Join(gudPath, relPath), write)
+}
+
+// removeMetadata removes a metadata file of the project, after journaling it if a transaction is running.
+func removeMetadata(gudPath, relPath string) error {
+	err := journalFile(gudPath, relPath)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(filepath.Join(gudPath, relPath))
+}
+
+// journalEntry is a metadata file as it was before the running transaction changed it.
+type journalEntry struct {
+	Path    string // relative to the gud directory
+	Existed bool
+	Data    []byte
+}
+
+// journal has the metadata files that the running transaction changed, to roll them back if it is interrupted,
+// and the objects it removed, which are kept aside until it is committed so the rolled back metadata can use them.
+// The paths are relative to the gud directory.
+type journal struct {
+	Entries []journalEntry
+	Removed []string
+}
+
+// Begin starts a transaction. The project is locked, so other processes cannot start transactions until it is
+// committed or aborted, and the metadata files it changes are journaled. If the process is interrupted,
+// the next Load rolls back the changes.
+func (p Project) Begin() error {
+	err := p.lock()
+	if err != nil {
+		return err
+	}
+
+	return p.startJournal()
+}
+
+// tryBegin starts a transaction like Begin, unless another process holds the lock of the project,
+// in which case it returns false.
+func (p Project) tryBegin() (bool, error) {
+	holder, err := p.tryLock()
+	if err != nil || holder != nil {
+		return false, err
+	}
+
+	return true, p.startJournal()
+}
+
+// startJournal starts the journal of a transaction once the project is locked, or unlocks it if it cannot.
+func (p Project) startJournal() error {
+	err := dumpJournal(p.gudPath, journal{})
+	if err != nil {
+		_ = p.unlock()
+	}
+	return err
+}
+
+// Commit finishes the transaction, keeping its changes, and unlocks the project.
+func (p Project) Commit() error {
+	j, err := loadJournal(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(p.gudPath, journalFilePath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Once the journal is gone the transaction cannot be rolled back, and the objects it removed are dropped.
+	// The ones that are left behind are removed with the other stale temporary files.
+	if j != nil {
+		for _, relPath := range j.Removed {
+			_ = os.Remove(removedObjectPath(p.gudPath, relPath))
+		}
+	}
+	return p.unlock()
+}
+
+// Abort rolls back the metadata files that the transaction changed, and unlocks the project.
+func (p Project) Abort() error {
+	err := rollbackJournal(p.gudPath)
+	if err != nil {
+		return err
+	}
+
+	return p.unlock()
+}
+
+// recoverTransaction rolls back a transaction that was interrupted, if the process that ran it is gone.
+func (p Project) recoverTransaction() error {
+	_, err := os.Stat(filepath.Join(p.gudPath, journalFilePath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	holder, err := p.tryLock()
+	if err != nil || holder != nil { // the transaction is still running
+		return err
+	}
+
+	err = rollbackJournal(p.gudPath)
+	if err != nil {
+		_ = p.unlock()
+		return err
+	}
+
+	err = removeStaleTmp(p.gudPath)
+	if err != nil {
+		_ = p.unlock()
+		return err
+	}
+
+	return p.unlock()
+}
+
+// journalFile records a metadata file in the journal before the running transaction changes it,
+// unless it was already recorded. Without a transaction, it does nothing.
+func journalFile(gudPath, relPath string) error {
+	gudPath, relPath = journalPath(gudPath, relPath)
+	j, err := loadJournal(gudPath)
+	if err != nil || j == nil {
+		return err
+	}
+
+	for _, entry := range j.Entries {
+		if entry.Path == relPath {
+			return nil
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(gudPath, relPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	j.Entries = append(j.Entries, journalEntry{Path: relPath, Existed: err == nil, Data: data})
+	return dumpJournal(gudPath, *j)
+}
+
+// removeObject removes an object of the project. While a transaction is running, the object is journaled and
+// moved aside instead, until the transaction is committed.
+func removeObject(gudPath string, hash ObjectHash) error {
+	path := objectPath(gudPath, hash)
+	gudPath, relPath := journalPath(gudPath, filepath.Join(objectsPath, hash.String()))
+	j, err := loadJournal(gudPath)
+	if err != nil {
+		return err
+	}
+	if j == nil {
+		return os.Remove(path)
+	}
+
+	err = os.MkdirAll(filepath.Join(gudPath, tmpPath), dirPerm)
+	if err != nil {
+		return err
+	}
+
+	// The object is journaled first, so it is brought back even if the process is interrupted while moving it
+	journaled := false
+	for _, removed := range j.Removed {
+		journaled = journaled || removed == relPath
+	}
+	if !journaled {
+		j.Removed = append(j.Removed, relPath)
+		err = dumpJournal(gudPath, *j)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(path, removedObjectPath(gudPath, relPath))
+}
+
+// removedObjectPath returns where a removed object is kept until the transaction is committed.
+func removedObjectPath(gudPath, relPath string) string {
+	return filepath.Join(gudPath, tmpPath, "removed-"+strings.ReplaceAll(filepath.ToSlash(relPath), "/", "-"))
+}
+
+// journalPath returns the gud directory whose journal has a file of the gud directory gudPath, and the path of
+// the file relative to it. The inner project that keeps the checkpoints changes in the transactions of the outer one.
+func journalPath(gudPath, relPath string) (string, string) {
+	outer := filepath.Dir(gudPath)
+	if filepath.Base(gudPath) == DefaultPath && filepath.Base(outer) == DefaultPath {
+		return outer, filepath.Join(DefaultPath, relPath)
+	}
+	return gudPath, relPath
+}
+
+// rollbackJournal returns the objects and the metadata files in the journal to the way they were, and removes it.
+func rollbackJournal(gudPath string) error {
+	j, err := loadJournal(gudPath)
+	if err != nil || j == nil {
+		return err
+	}
+
+	for _, relPath := range j.Removed {
+		err = os.Rename(removedObjectPath(gudPath, relPath), filepath.Join(gudPath, relPath))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for _, entry := range j.Entries {
+		path := filepath.Join(gudPath, entry.Path)
+		if !entry.Existed {
+			err = os.Remove(path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		data := entry.Data
+		err = writeDurable(gudPath, path, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(filepath.Join(gudPath, journalFilePath))
+}
+
+// removeStaleTmp removes the temporary files that were left by interrupted processes.
+func removeStaleTmp(gudPath string) error {
+	dir := filepath.Join(gudPath, tmpPath)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		if time.Since(info.ModTime()) < staleTmpAge {
+			continue
+		}
+		err = os.Remove(filepath.Join(dir, info.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadJournal returns the journal of the running transaction, or nil if there is none.
+func loadJournal(gudPath string) (*journal, error) {
+	file, err := os.Open(filepath.Join(gudPath, journalFilePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var j journal
+	err = gob.NewDecoder(file).Decode(&j)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func dumpJournal(gudPath string, j journal) error {
+	return writeDurable(gudPath, filepath.Join(gudPath, journalFilePath), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(j)
+	})
+}
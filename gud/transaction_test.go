@@ -0,0 +1,202 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProject_Transaction(t *testing.T) {
+	defer clearTest()
+
+	p, _ := Start(testDir)
+	head, _ := p.CurrentHash()
+
+	err := p.Begin()
+	if err != nil {
+		t.Fatal("failed to begin a transaction:", err)
+	}
+	if err = p.Begin(); err == nil {
+		t.Error("a locked project was locked again")
+	}
+
+	_ = p.CreateBranch("interrupted")
+	_ = dumpIndex(p.gudPath, []indexEntry{{Path: testFile, State: StateRemoved}})
+
+	// An interrupted transaction is rolled back once its process is gone
+	if _, err = Load(testDir); err != nil {
+		t.Fatal("failed to load the project while the transaction runs:", err)
+	}
+	if hash, _ := p.GetBranch("interrupted"); hash == nil {
+		t.Fatal("a running transaction was rolled back")
+	}
+	writeLock(t, p, exitedProcess(t))
+
+	p2, err := Load(testDir)
+	if err != nil {
+		t.Fatal("failed to recover the transaction:", err)
+	}
+	if hash, _ := p2.GetBranch("interrupted"); hash != nil {
+		t.Error("the created branch was not rolled back")
+	}
+	if index, _ := loadIndex(p2.gudPath); len(index) != 0 {
+		t.Error("the index was not rolled back:", index)
+	}
+	if current, _ := p2.CurrentHash(); *current != *head {
+		t.Error("the head was changed")
+	}
+
+	err = p2.Begin()
+	if err != nil {
+		t.Fatal("failed to begin a transaction after the recovery:", err)
+	}
+	_ = p2.CreateBranch("committed")
+	err = p2.Commit()
+	if err != nil {
+		t.Fatal("failed to commit:", err)
+	}
+	if hash, _ := p2.GetBranch("committed"); hash == nil {
+		t.Error("the committed branch was lost")
+	}
+
+	_ = p2.Begin()
+	_ = p2.CreateBranch("aborted")
+	err = p2.Abort()
+	if err != nil {
+		t.Fatal("failed to abort:", err)
+	}
+	if hash, _ := p2.GetBranch("aborted"); hash != nil {
+		t.Error("the aborted branch was kept")
+	}
+	if _, err = os.Stat(filepath.Join(p2.gudPath, lockFilePath)); !os.IsNotExist(err) {
+		t.Error("the project was left locked")
+	}
+}
+
+func TestProject_TransactionRemovedObjects(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	_ = ioutil.WriteFile(testPath, []byte("data"), 0644)
+	_ = p.Add(testPath)
+	index, _ := loadIndex(p.gudPath)
+	if len(index) != 1 {
+		t.Fatal("the file was not added:", index)
+	}
+	blob := objectPath(p.gudPath, index[0].Hash)
+
+	// Adding the file again replaces the object of its entry
+	_ = p.Begin()
+	_ = ioutil.WriteFile(testPath, []byte("changed"), 0644)
+	err := p.Add(testPath)
+	if err != nil {
+		t.Fatal("failed to add:", err)
+	}
+	_ = p.Abort()
+	if _, err = os.Stat(blob); err != nil {
+		t.Error("the object of the rolled back index was removed:", err)
+	}
+
+	// An interrupted transaction brings back the objects it removed
+	_ = p.Begin()
+	_ = p.Add(testPath)
+	writeLock(t, p, exitedProcess(t))
+	if _, err = Load(testDir); err != nil {
+		t.Fatal("failed to recover the transaction:", err)
+	}
+	if _, err = os.Stat(blob); err != nil {
+		t.Error("the object of the recovered index was removed:", err)
+	}
+
+	_ = p.Begin()
+	_ = p.Add(testPath)
+	err = p.Commit()
+	if err != nil {
+		t.Fatal("failed to commit:", err)
+	}
+	if _, err = os.Stat(blob); !os.IsNotExist(err) {
+		t.Error("the object of the removed entry was kept")
+	}
+	if files, _ := ioutil.ReadDir(filepath.Join(p.gudPath, tmpPath)); len(files) != 0 {
+		t.Error("the removed objects were left behind")
+	}
+}
+
+func TestProject_TransactionPrunedCheckpoints(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	var config Config
+	_ = p.LoadConfig(&config)
+	config.Retention = nil
+	config.Checkpoints = 1
+	_ = p.WriteConfig(config)
+
+	_ = ioutil.WriteFile(testPath, []byte("first"), 0644)
+	_ = p.Checkpoint("first")
+	inner := p.innerProject()
+	first, _ := inner.CurrentHash()
+
+	// The next checkpoint prunes the first one
+	_ = p.Begin()
+	_ = ioutil.WriteFile(testPath, []byte("second"), 0644)
+	err := p.Checkpoint("second")
+	if err != nil {
+		t.Fatal("failed to checkpoint:", err)
+	}
+	if _, err = os.Stat(objectPath(inner.gudPath, *first)); !os.IsNotExist(err) {
+		t.Fatal("the first checkpoint was not pruned")
+	}
+	err = p.Abort()
+	if err != nil {
+		t.Fatal("failed to abort:", err)
+	}
+
+	if current, _ := inner.CurrentHash(); *current != *first {
+		t.Error("the checkpoints were not rolled back")
+	}
+	if _, err = os.Stat(objectPath(inner.gudPath, *first)); err != nil {
+		t.Error("the pruned checkpoint was removed:", err)
+	}
+	checkpoints, err := p.Checkpoints()
+	if err != nil || len(checkpoints) == 0 {
+		t.Error("the rolled back checkpoints cannot be read:", err)
+	}
+}
+
+// exitedProcess returns the id of a process that is no longer running.
+func exitedProcess(t *testing.T) int {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The tests run in another directory, so they do not clear the test project
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Dir = dir
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeLock(t *testing.T, p *Project, pid int) {
+	host, _ := os.Hostname()
+	file, err := os.Create(filepath.Join(p.gudPath, lockFilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = gob.NewEncoder(file).Encode(lockInfo{PID: pid, Host: host, Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,239 @@
+package gud
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+)
+
+// Transport reaches another project, to push branches to it and pull them from it.
+type Transport interface {
+	// Branches returns the branches of the other project, and the versions they point to.
+	Branches() (map[string]ObjectHash, error)
+	// IncomingTransfer returns the interrupted transfer of a branch into the other project,
+	// see Project.IncomingTransfer.
+	IncomingTransfer(branch string) (id string, skip int, err error)
+	// Receive makes the other project pull a branch from what PushBranch wrote to in.
+	Receive(branch string, in io.Reader, contentType string) error
+	// Send returns what PushBranch of the other project writes for a branch, and its content type.
+	// options.Progress and options.Boundary are not used.
+	Send(branch string, options PushOptions) (io.ReadCloser, string, error)
+	Close() error
+}
+
+// PushTo pushes a branch to the other project of a transport, and returns the version it was pushed at.
+// options.Start, options.Haves and the resumed transfer are found through the transport.
+// Unless options.Force is set, the branch of the other project must not have versions that this one does not,
+// or a NonFastForwardError is returned.
+func (p Project) PushTo(t Transport, branch string, options PushOptions) (*ObjectHash, error) {
+	hash, err := p.GetBranch(branch)
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		return nil, Error{"branch does not exist: " + branch}
+	}
+
+	branches, err := t.Branches()
+	if err != nil {
+		return nil, err
+	}
+	options.Start, options.Haves = nil, nil
+	if start, ok := branches[branch]; ok {
+		options.Start = &start
+	}
+	for _, have := range branches {
+		options.Haves = append(options.Haves, have)
+	}
+
+	// Reject the push before it is sent, the other project checks it again when it is received
+	if options.Force && options.Lease != nil && !sameVersion(options.Lease, options.Start) {
+		return nil, NonFastForwardError{branch}
+	}
+	if !options.Force && options.Start != nil {
+		forward, err := isDescendent(p.gudPath, *hash, *options.Start)
+		if err != nil {
+			return nil, err
+		}
+		if !forward {
+			return nil, NonFastForwardError{branch}
+		}
+	}
+
+	options.Resume, options.Skip, err = t.IncomingTransfer(branch)
+	if err != nil {
+		return nil, err
+	}
+
+	// The objects are sent while they are written
+	options.Boundary = multipart.NewWriter(nil).Boundary()
+	body, out := io.Pipe()
+	go func() {
+		_, err := p.PushBranch(out, branch, options)
+		_ = out.CloseWithError(err)
+	}()
+	defer body.Close()
+
+	err = t.Receive(branch, body, "multipart/mixed; boundary="+options.Boundary)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// PullFrom receives the versions of a branch from the other project of a transport, see PullBranchWith.
+// The working tree is not touched.
+func (p Project) PullFrom(t Transport, branch string, progress func(Progress)) (*ObjectHash, error) {
+	return p.pullRefFrom(t, branch, filepath.Join(branchesPath, branch), PushOptions{Progress: progress})
+}
+
+// FetchFrom receives the versions of a branch from the other project of a transport, into the remote-tracking
+// branch of the remote it reaches, see FetchBranch. The remote-tracking branch follows the branch
+// even if it was force pushed.
+func (p Project) FetchFrom(t Transport, remote, branch string, progress func(Progress)) (*ObjectHash, error) {
+	return p.pullRefFrom(t, branch, remoteRef(remote, branch), PushOptions{Force: true, Progress: progress})
+}
+
+// pullRefFrom receives the versions of a branch of the other project of a transport into a reference.
+func (p Project) pullRefFrom(t Transport, branch, ref string, options PushOptions) (*ObjectHash, error) {
+	var err error
+	options.Start, err = getRef(p.gudPath, ref)
+	if err != nil {
+		return nil, err
+	}
+	options.Haves, err = p.Haves()
+	if err != nil {
+		return nil, err
+	}
+	options.Resume, options.Skip, err = p.incomingTransfer(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	in, contentType, err := t.Send(branch, options)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	return p.pullRef(ref, in, contentType, PullOptions{Progress: options.Progress})
+}
+
+// localTransport reaches a project on this computer.
+type localTransport struct {
+	other Project
+}
+
+// NewLocalTransport returns a transport to the project at a path. If create is set and the path has no project,
+// a project without a working tree is started there, and the path is created if needed.
+func NewLocalTransport(path string, create bool) (Transport, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(abs, DefaultPath))
+	if os.IsNotExist(err) && create {
+		err = os.MkdirAll(abs, dirPerm)
+		if err != nil {
+			return nil, err
+		}
+		other, err := StartHeadless(abs)
+		if err != nil {
+			return nil, err
+		}
+		return localTransport{*other}, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, Error{ProjectNotFound + abs}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	other, err := Load(abs)
+	if err != nil {
+		return nil, err
+	}
+	return localTransport{*other}, nil
+}
+
+func (t localTransport) Branches() (map[string]ObjectHash, error) {
+	branches := make(map[string]ObjectHash)
+	err := t.other.ListBranches(func(branch string) error {
+		hash, err := t.other.GetBranch(branch)
+		if err != nil || hash == nil {
+			return err
+		}
+		branches[filepath.ToSlash(branch)] = *hash
+		return nil
+	})
+	return branches, err
+}
+
+func (t localTransport) IncomingTransfer(branch string) (string, int, error) {
+	return t.other.IncomingTransfer(branch)
+}
+
+// Receive pulls the branch in a transaction, so the other project is locked while it changes.
+// The branch that the other project has checked out is refused, since its index and working tree would not follow it.
+func (t localTransport) Receive(branch string, in io.Reader, contentType string) error {
+	err := t.other.Begin()
+	if err != nil {
+		return err
+	}
+
+	if t.other.HasHead() {
+		head, err := loadHead(t.other.gudPath)
+		if err != nil {
+			_ = t.other.Abort()
+			return err
+		}
+		if !head.IsDetached && filepath.ToSlash(head.Branch) == filepath.ToSlash(branch) {
+			_ = t.other.Abort()
+			return Error{fmt.Sprintf("the branch %s is checked out in %s, so it cannot be pushed to", branch, t.other.Path)}
+		}
+	}
+
+	_, err = t.other.PullBranch(branch, in, contentType)
+	if err != nil {
+		_ = t.other.Abort()
+		return err
+	}
+	return t.other.Commit()
+}
+
+func (t localTransport) Send(branch string, options PushOptions) (io.ReadCloser, string, error) {
+	hash, err := t.other.GetBranch(branch)
+	if err != nil {
+		return nil, "", err
+	}
+	if hash == nil {
+		return nil, "", Error{"branch does not exist: " + branch}
+	}
+	if !options.Force && options.Start != nil {
+		forward, err := isDescendent(t.other.gudPath, *hash, *options.Start)
+		if err != nil {
+			return nil, "", err
+		}
+		if !forward {
+			return nil, "", NonFastForwardError{branch}
+		}
+	}
+
+	options.Progress = nil
+	options.Boundary = multipart.NewWriter(nil).Boundary()
+	in, out := io.Pipe()
+	go func() {
+		_, err := t.other.PushBranch(out, branch, options)
+		_ = out.CloseWithError(err)
+	}()
+
+	return in, "multipart/mixed; boundary=" + options.Boundary, nil
+}
+
+func (t localTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package gud
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProject_PushTo(t *testing.T) {
+	defer clearTest()
+
+	projectPath := filepath.Join(testDir, "project")
+	backupPath := filepath.Join(testDir, "backup", "project")
+	_ = os.Mkdir(projectPath, dirPerm)
+
+	p, _ := Start(projectPath)
+	_ = ioutil.WriteFile(filepath.Join(projectPath, testFile), []byte("first"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("first")
+
+	if _, err := NewLocalTransport(backupPath, false); err == nil {
+		t.Error("a transport was opened to a path without a project")
+	}
+
+	local, err := NewLocalTransport(backupPath, true)
+	if err != nil {
+		t.Fatal("failed to open a local transport:", err)
+	}
+	hash, err := p.PushTo(local, FirstBranchName, PushOptions{})
+	if err != nil {
+		t.Fatal("failed to push:", err)
+	}
+
+	backup, _ := Load(backupPath)
+	pushed, _ := backup.GetBranch(FirstBranchName)
+	if pushed == nil || *pushed != *hash {
+		t.Error("the branch was not pushed to the path")
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(projectPath, testFile), []byte("second"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("second")
+	_, err = p.PushTo(local, FirstBranchName, PushOptions{})
+	if err != nil {
+		t.Fatal("failed to push again:", err)
+	}
+
+	// Pull through a stdio transport that is served by the backup
+	clonePath := filepath.Join(testDir, "clone")
+	_ = os.Mkdir(clonePath, dirPerm)
+	clone, _ := StartHeadless(clonePath)
+
+	requests, requestsOut := io.Pipe()
+	responses, responsesOut := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- ServeStdio(local, requests, responsesOut)
+		_ = responsesOut.Close()
+	}()
+	stdio := newStdioTransport(responses, requestsOut, func() error {
+		return <-served
+	})
+
+	branches, err := stdio.Branches()
+	if err != nil {
+		t.Fatal("failed to list the branches through stdio:", err)
+	}
+	serverHash, _ := p.CurrentHash()
+	if branches[FirstBranchName] != *serverHash {
+		t.Error("unexpected branches through stdio:", branches)
+	}
+
+	if _, err = clone.PullFrom(stdio, "missing", nil); err == nil {
+		t.Error("a missing branch was pulled through stdio")
+	}
+	pulled, err := clone.PullFrom(stdio, FirstBranchName, nil)
+	if err != nil {
+		t.Fatal("failed to pull through stdio:", err)
+	}
+	if *pulled != *serverHash {
+		t.Error("the pulled branch is not at the pushed version")
+	}
+
+	err = stdio.Close()
+	if err != nil {
+		t.Error("failed to close the stdio transport:", err)
+	}
+}
+
+func TestProject_PushToForce(t *testing.T) {
+	defer clearTest()
+
+	firstPath := filepath.Join(testDir, "first")
+	secondPath := filepath.Join(testDir, "second")
+	backupPath := filepath.Join(testDir, "backup")
+	_ = os.Mkdir(firstPath, dirPerm)
+	_ = os.Mkdir(secondPath, dirPerm)
+
+	first, _ := Start(firstPath)
+	_ = ioutil.WriteFile(filepath.Join(firstPath, testFile), []byte("first"), 0644)
+	_ = first.AddAll()
+	_, _ = first.Save("first")
+	backup, _ := NewLocalTransport(backupPath, true)
+	_, _ = first.PushTo(backup, FirstBranchName, PushOptions{})
+
+	second, _ := StartHeadless(secondPath)
+	_, _ = second.PullFrom(backup, FirstBranchName, nil)
+	_ = second.AddHead()
+	_ = second.Reset()
+	_ = ioutil.WriteFile(filepath.Join(secondPath, testFile), []byte("second"), 0644)
+	_ = second.AddAll()
+	_, _ = second.Save("second")
+	secondHash, _ := second.PushTo(backup, FirstBranchName, PushOptions{})
+
+	_ = ioutil.WriteFile(filepath.Join(firstPath, testFile), []byte("diverged"), 0644)
+	_ = first.AddAll()
+	_, _ = first.Save("diverged")
+
+	_, err := first.PushTo(backup, FirstBranchName, PushOptions{})
+	if _, ok := err.(NonFastForwardError); !ok {
+		t.Fatal("a push that loses versions was not rejected:", err)
+	}
+	_, err = first.PullFrom(backup, FirstBranchName, nil)
+	if _, ok := err.(NonFastForwardError); !ok {
+		t.Error("a pull that loses versions was not rejected:", err)
+	}
+
+	start, _ := first.CurrentHash()
+	_, err = first.PushTo(backup, FirstBranchName, PushOptions{Force: true, Lease: start})
+	if _, ok := err.(NonFastForwardError); !ok {
+		t.Error("a push with a stale lease was not rejected:", err)
+	}
+
+	// The branch that the second project pushed is replaced
+	hash, err := first.PushTo(backup, FirstBranchName, PushOptions{Force: true, Lease: secondHash})
+	if err != nil {
+		t.Fatal("failed to force push:", err)
+	}
+	branches, _ := backup.Branches()
+	if branches[FirstBranchName] != *hash {
+		t.Error("the force push did not replace the branch")
+	}
+
+	// The second project can follow the replaced branch with a fetch, but not with a forbidden forced transfer
+	fetched, err := second.FetchFrom(backup, DefaultRemote, FirstBranchName, nil)
+	if err != nil || *fetched != *hash {
+		t.Error("failed to fetch the replaced branch:", err)
+	}
+
+	var buf bytes.Buffer
+	boundary, _ := first.PushBranch(&buf, FirstBranchName, PushOptions{Start: secondHash, Force: true})
+	_, err = second.PullBranchWith(FirstBranchName, &buf, "multipart/mixed; boundary="+boundary,
+		PullOptions{ForbidForce: true})
+	if err != ErrForceForbidden {
+		t.Error("a forbidden force push was received:", err)
+	}
+}
+
+func TestProject_PushToCheckedOut(t *testing.T) {
+	defer clearTest()
+
+	projectPath := filepath.Join(testDir, "project")
+	otherPath := filepath.Join(testDir, "other")
+	_ = os.Mkdir(projectPath, dirPerm)
+	_ = os.Mkdir(otherPath, dirPerm)
+
+	p, _ := Start(projectPath)
+	_ = ioutil.WriteFile(filepath.Join(projectPath, testFile), []byte("data"), 0644)
+	_ = p.AddAll()
+	_, _ = p.Save("first")
+	_ = p.CreateBranch("feature")
+
+	other, _ := Start(otherPath)
+	otherHash, _ := other.CurrentHash()
+	local, _ := NewLocalTransport(otherPath, false)
+
+	_, err := p.PushTo(local, FirstBranchName, PushOptions{Force: true})
+	if err == nil {
+		t.Error("the checked out branch of a project with a working tree was pushed to")
+	}
+	if hash, _ := other.GetBranch(FirstBranchName); hash == nil || *hash != *otherHash {
+		t.Error("the checked out branch was moved")
+	}
+
+	hash, err := p.PushTo(local, "feature", PushOptions{})
+	if err != nil {
+		t.Fatal("failed to push a branch that is not checked out:", err)
+	}
+	if pushed, _ := other.GetBranch("feature"); pushed == nil || *pushed != *hash {
+		t.Error("the branch was not pushed")
+	}
+}
@@ -0,0 +1,245 @@
+package gud
+
+import (
+	"encoding/gob"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const watchSocketPath = "watch.sock"
+const watchSyncPath = "watch-sync"
+const defaultQuietPeriod = 10 * time.Second
+
+// syncTimeout is how long a status request waits for the changes made before it to be reported
+const syncTimeout = time.Second
+
+// treeWatcher reports the paths that change in a directory tree.
+type treeWatcher interface {
+	// Changes returns the absolute paths of the files and directories that changed
+	Changes() <-chan string
+	Errors() <-chan error
+	Close() error
+}
+
+type watchRequest struct {
+	Command string
+}
+
+type watchStatus struct {
+	Changes statusChanges
+	Err     string // why the status could not be found
+}
+
+// Watch watches the working tree until stop is closed.
+// It answers the status requests of other processes over a unix socket, without comparing files
+// that did not change since the last request, and saves a checkpoint once the working tree has not
+// changed for the quiet period of the configuration. While another command holds the lock of the project,
+// the checkpoint waits for another quiet period.
+// Errors that do not stop the watch, like failed checkpoints, are passed to errFn.
+func (p Project) Watch(stop <-chan struct{}, errFn func(error)) error {
+	var config Config
+	err := p.LoadConfig(&config)
+	if err != nil {
+		return err
+	}
+	quietPeriod := defaultQuietPeriod
+	if config.QuietPeriod > 0 {
+		quietPeriod = time.Duration(config.QuietPeriod) * time.Second
+	}
+
+	socketPath := filepath.Join(p.gudPath, watchSocketPath)
+	syncPath := filepath.Join(p.gudPath, watchSyncPath)
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return Error{"the project is already watched"}
+	}
+	_ = os.Remove(socketPath) // left by a watch that did not stop cleanly
+
+	// Only the top of .gud is watched, since the status depends on the index, the head and the branches
+	branchesDir := filepath.Join(p.gudPath, branchesPath)
+	watcher, err := newTreeWatcher(p.Path, func(path string) bool {
+		return strings.HasPrefix(path, p.gudPath+string(os.PathSeparator)) && path != branchesDir
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	requests := make(chan chan watchStatus)
+	go serveWatch(listener, requests)
+
+	ig, err := p.loadIgnorer()
+	if err != nil {
+		return err
+	}
+
+	var status watchStatus
+	statusDirty := true
+	var quiet <-chan time.Time
+	syncs := 0
+
+	handle := func(path string) {
+		if filepath.Dir(path) == p.gudPath && isLocalMetadata(filepath.Base(path)) {
+			return
+		}
+		statusDirty = true
+		if path == p.gudPath || strings.HasPrefix(path, p.gudPath+string(os.PathSeparator)) {
+			return
+		}
+
+		if filepath.Base(path) == IgnoreFileName {
+			newIg, err := p.loadIgnorer()
+			if err != nil {
+				errFn(err)
+			} else {
+				ig = newIg
+			}
+		}
+
+		relPath, err := filepath.Rel(p.Path, path)
+		if err != nil {
+			return
+		}
+		if ignored, err := ig.ignored(relPath, false); err == nil && ignored {
+			return
+		}
+		quiet = time.After(quietPeriod)
+	}
+
+	updateStatus := func() {
+		if statusDirty {
+			status = p.watchedStatus()
+			statusDirty = status.Err != ""
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+
+		case path := <-watcher.Changes():
+			handle(path)
+
+		case err := <-watcher.Errors():
+			return err
+
+		case <-quiet:
+			quiet = nil
+			var saved bool
+			saved, err = p.autoCheckpoint()
+			if err != nil {
+				errFn(err)
+			} else if !saved { // retried once the command that holds the lock is done
+				quiet = time.After(quietPeriod)
+			}
+			updateStatus()
+
+		case reply := <-requests:
+			// Every change made before the request is reported before the marker is
+			syncs++
+			err = ioutil.WriteFile(syncPath, []byte(strconv.Itoa(syncs)), 0644)
+			if err != nil {
+				errFn(err)
+				statusDirty = true
+			}
+			timeout := time.After(syncTimeout)
+		sync:
+			for err == nil {
+				select {
+				case path := <-watcher.Changes():
+					if path == syncPath {
+						break sync
+					}
+					handle(path)
+				case err := <-watcher.Errors():
+					return err
+				case <-timeout:
+					statusDirty = true
+					break sync
+				}
+			}
+
+			updateStatus()
+			reply <- status
+		}
+	}
+}
+
+func (p Project) watchedStatus() watchStatus {
+	changes, err := p.findChanges()
+	if err != nil {
+		return watchStatus{Err: err.Error()}
+	}
+	return watchStatus{Changes: *changes}
+}
+
+// serveWatch passes the requests of other processes to the watch, one connection at a time.
+func serveWatch(listener net.Listener, requests chan<- chan watchStatus) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		var req watchRequest
+		_ = conn.SetDeadline(time.Now().Add(10 * syncTimeout))
+		err = gob.NewDecoder(conn).Decode(&req)
+		if err == nil && req.Command == "status" {
+			reply := make(chan watchStatus)
+			requests <- reply
+			_ = gob.NewEncoder(conn).Encode(<-reply)
+		}
+		conn.Close()
+	}
+}
+
+// WatchedStatus is like Status, but asks the watch of the project for the changes, if there is one.
+// It returns nil if the project is not watched or the watch failed.
+func (p Project) WatchedStatus() (*ProjectStatus, error) {
+	conn, err := net.DialTimeout("unix", filepath.Join(p.gudPath, watchSocketPath), syncTimeout)
+	if err != nil {
+		return nil, nil
+	}
+	defer conn.Close()
+
+	var status watchStatus
+	_ = conn.SetDeadline(time.Now().Add(10 * syncTimeout))
+	err = gob.NewEncoder(conn).Encode(watchRequest{Command: "status"})
+	if err == nil {
+		err = gob.NewDecoder(conn).Decode(&status)
+	}
+	if err != nil || status.Err != "" {
+		return nil, nil
+	}
+
+	return p.newStatus(status.Changes)
+}
+
+// autoCheckpoint saves a checkpoint of the watch in a transaction, or returns false without saving it if
+// another process holds the lock of the project. Auto-checkpoints are not operations, so they cannot be undone
+// and keep the undone ones.
+func (p Project) autoCheckpoint() (bool, error) {
+	ok, err := p.tryBegin()
+	if err != nil || !ok {
+		return false, err
+	}
+
+	_, err = p.checkpoint("watch")
+	if err != nil {
+		_ = p.Abort()
+		return true, err
+	}
+	return true, p.Commit()
+}
@@ -0,0 +1,143 @@
+//go:build linux
+// +build linux
+
+package gud
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// inotifyWatcher watches every directory of a tree with inotify, which is not recursive by itself.
+type inotifyWatcher struct {
+	fd      int // used directly, since asking the file for it would make it blocking
+	file    *os.File
+	skip    func(path string) bool
+	root    string
+	dirs    map[int32]string // the watched directory of each watch descriptor
+	changes chan string
+	errors  chan error
+}
+
+func newTreeWatcher(root string, skip func(path string) bool) (treeWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// A non-blocking file is read through the runtime poller, so closing it stops a pending read
+	w := &inotifyWatcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		skip:    skip,
+		root:    root,
+		dirs:    make(map[int32]string),
+		changes: make(chan string, 256),
+		errors:  make(chan error, 1),
+	}
+
+	err = w.addTree(root)
+	if err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.read()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Changes() <-chan string {
+	return w.changes
+}
+
+func (w *inotifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
+
+// addTree watches a directory and the directories inside it.
+func (w *inotifyWatcher) addTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) { // removed while walking
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if w.skip(path) {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err == syscall.ENOENT {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) read() {
+	var buf [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.errors <- err
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 { // events were lost, so anything might have changed
+				w.changes <- w.root
+				continue
+			}
+
+			dir, ok := w.dirs[event.Wd]
+			if !ok {
+				continue
+			}
+			if event.Mask&syscall.IN_IGNORED != 0 { // the directory was removed
+				delete(w.dirs, event.Wd)
+				continue
+			}
+
+			path := dir
+			if name != "" {
+				path = filepath.Join(dir, name)
+			}
+
+			if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				err = w.addTree(path)
+				if err != nil {
+					w.errors <- err
+					return
+				}
+			}
+
+			w.changes <- path
+		}
+	}
+}
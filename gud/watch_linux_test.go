@@ -0,0 +1,109 @@
+package gud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProject_Watch(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- p.Watch(stop, func(err error) {
+			t.Error("watch failed:", err)
+		})
+	}()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var status *ProjectStatus
+	for i := 0; i < 50 && status == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		status, _ = p.WatchedStatus()
+	}
+	if status == nil {
+		t.Fatal("the watch did not answer")
+	}
+	if len(status.Files) != 0 {
+		t.Error("changes reported in an empty project:", status.Files)
+	}
+
+	_ = ioutil.WriteFile(testPath, []byte("data"), 0644)
+	status, err := p.WatchedStatus()
+	if err != nil || status == nil {
+		t.Fatal("the watch did not answer:", err)
+	}
+	if len(status.Files) != 1 || status.Files[0].Path != testFile {
+		t.Error("a change made before the request was not reported:", status.Files)
+	}
+}
+
+func TestProject_WatchCheckpoint(t *testing.T) {
+	defer clearTest()
+
+	testPath := filepath.Join(testDir, testFile)
+	p, _ := Start(testDir)
+	var config Config
+	_ = p.LoadConfig(&config)
+	config.QuietPeriod = 1
+	_ = p.WriteConfig(config)
+
+	_ = ioutil.WriteFile(testPath, []byte("data"), 0644)
+	_ = p.Checkpoint("add")
+	_ = p.Add(testPath)
+	_, _ = p.Undo(1)
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- p.Watch(stop, func(err error) {
+			t.Error("watch failed:", err)
+		})
+	}()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// The checkpoint waits while a command holds the lock
+	time.Sleep(100 * time.Millisecond)
+	writeLock(t, p, os.Getpid())
+	_ = ioutil.WriteFile(testPath, []byte("changed"), 0644)
+	time.Sleep(1500 * time.Millisecond)
+	inner := p.innerProject()
+	version, _ := inner.CurrentVersion()
+	if version != nil && version.Message == "watch" {
+		t.Error("a checkpoint was saved while the project was locked")
+	}
+	_ = os.Remove(filepath.Join(p.gudPath, lockFilePath))
+
+	for i := 0; i < 50 && (version == nil || version.Message != "watch"); i++ {
+		time.Sleep(100 * time.Millisecond)
+		version, _ = inner.CurrentVersion()
+	}
+	if version == nil || version.Message != "watch" {
+		t.Fatal("the watch did not save a checkpoint")
+	}
+
+	log, err := p.loadOperations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Done) != 0 || len(log.Undone) != 1 {
+		t.Error("the checkpoint of the watch changed the operation log:", log)
+	}
+}
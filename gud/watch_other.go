@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package gud
+
+func newTreeWatcher(root string, skip func(path string) bool) (treeWatcher, error) {
+	return nil, Error{"watching the working tree is only supported on linux"}
+}
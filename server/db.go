@@ -20,6 +20,8 @@ var newUserStmt,
 	userProjectsStmt,
 	hasMemberStmt,
 	inviteMemberStmt,
+	getProtectionStmt,
+	setProtectionStmt,
 	createIssueStmt,
 	getIssuesStmt,
 	getIssueStmt,
@@ -79,6 +81,13 @@ func init() {
 		inviteMemberStmt = mustPrepare(
 			"INSERT INTO members (user_id, project_id) VALUES ($1, $2);")
 
+		getProtectionStmt = mustPrepare(
+			"SELECT forbid_force FROM branch_protections WHERE project_id = $1 AND branch = $2;")
+
+		setProtectionStmt = mustPrepare(`
+			INSERT INTO branch_protections (project_id, branch, forbid_force) VALUES ($1, $2, $3)
+			ON CONFLICT (project_id, branch) DO UPDATE SET forbid_force = $3;`)
+
 		createIssueStmt = mustPrepare(`
 			INSERT INTO issues (title, content, user_id, project_id, status, created_at)
 			VALUES ($1, $2, $3, $4, 'open', NOW())
@@ -162,6 +171,8 @@ func closeDB() error {
 		userProjectsStmt,
 		hasMemberStmt,
 		inviteMemberStmt,
+		getProtectionStmt,
+		setProtectionStmt,
 		createIssueStmt,
 		getIssuesStmt,
 		getIssueStmt,
@@ -19,6 +19,13 @@ CREATE TABLE IF NOT EXISTS members (
     project_id int NOT NULL REFERENCES projects(project_id)
 );
 
+CREATE TABLE IF NOT EXISTS branch_protections (
+    project_id   int     NOT NULL REFERENCES projects(project_id),
+    branch       varchar NOT NULL,
+    forbid_force boolean NOT NULL,
+    PRIMARY KEY (project_id, branch)
+);
+
 CREATE TYPE issue_status AS ENUM ('open', 'in_progress', 'done', 'closed');
 
 CREATE TABLE IF NOT EXISTS issues (
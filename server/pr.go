@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -63,6 +64,12 @@ func getPrs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	p, err := gud.Load(contextProjectPath(r.Context()))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
 	prs := make([]gud.PullRequest, 0)
 	for rows.Next() {
 		pr, err := scanPr(rows)
@@ -70,6 +77,11 @@ func getPrs(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err)
 			return
 		}
+		err = checkMergeable(p, pr)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
 		prs = append(prs, *pr)
 	}
 
@@ -102,6 +114,17 @@ func getPr(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := gud.Load(contextProjectPath(r.Context()))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	err = checkMergeable(p, pr)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
 	err = json.NewEncoder(w).Encode(pr)
 	if err != nil {
 		handleError(w, err)
@@ -126,33 +149,39 @@ func mergePr(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	project, err := gud.Load(contextProjectPath(r.Context()))
-	if err != nil {
-		handleError(w, err)
+	var req gud.MergePrRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF { // the options are optional
+		reportError(w, http.StatusBadRequest, "failed to receive merge options")
 		return
 	}
 
-	if !project.HasHead() {
-		err = project.AddHead()
-		if err != nil {
-			handleError(w, err)
-			return
-		}
-	}
-
-	err = project.CheckoutBranch(pr.To)
+	project, err := gud.Load(contextProjectPath(r.Context()))
 	if err != nil {
 		handleError(w, err)
+		return
 	}
 
-	_, err = project.MergeBranch(pr.From)
+	if !beginTransaction(w, *project) {
+		return
+	}
+	_, err = project.MergeBranchInto(pr.To, pr.From, gud.MergeOptions{
+		NoFastForward:   req.NoFastForward,
+		FastForwardOnly: req.FastForwardOnly,
+		Squash:          req.Squash,
+		Message:         req.Message,
+	})
+	endTransaction(*project, err)
 	if err == gud.ErrMergeConflict {
-		err = project.Reset()
-		if err != nil {
-			handleError(w, err)
-		} else {
-			reportError(w, http.StatusBadRequest, "cannot merge: there are merge conflicts.")
-		}
+		reportError(w, http.StatusBadRequest, "cannot merge: there are merge conflicts.")
+		return
+	}
+	if _, ok := err.(gud.Error); ok {
+		reportError(w, http.StatusBadRequest, "cannot merge: "+err.Error())
+		return
+	}
+	if _, ok := err.(gud.InputError); ok {
+		reportError(w, http.StatusBadRequest, "cannot merge: "+err.Error())
 		return
 	}
 	if err != nil {
@@ -194,6 +223,36 @@ func closePr(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// checkMergeable sets whether an open pull request can be merged without conflicts.
+func checkMergeable(p *gud.Project, pr *gud.PullRequest) error {
+	if pr.Status != "open" {
+		return nil
+	}
+
+	to, err := p.GetBranch(pr.To)
+	if err != nil {
+		return err
+	}
+	from, err := p.GetBranch(pr.From)
+	if err != nil {
+		return err
+	}
+	if to == nil || from == nil {
+		return nil
+	}
+
+	preview, err := p.PreviewMerge(*to, *from)
+	if _, ok := err.(gud.Error); ok { // the branches cannot be merged at all
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pr.Mergeable = len(preview.Conflicts) == 0
+	return nil
+}
+
 func scanPr(row scanner) (*gud.PullRequest, error) {
 	var pr gud.PullRequest
 	var authorId int
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -76,11 +77,23 @@ func projectBranch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hash, err := project.GetBranch(mux.Vars(r)["branch"])
+	branch := mux.Vars(r)["branch"]
+	hash, err := project.GetBranch(branch)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
+
+	id, skip, err := project.IncomingTransfer(branch)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if id != "" {
+		w.Header().Set(gud.ResumeHeader, id)
+		w.Header().Set(gud.SkipHeader, strconv.Itoa(skip))
+	}
+
 	if hash == nil {
 		reportError(w, http.StatusNotFound, "branch not found")
 		return
@@ -127,6 +140,29 @@ func pullProject(w http.ResponseWriter, r *http.Request) {
 		start = &startHash
 	}
 
+	// The versions the client has, whose objects are not sent
+	var haves []gud.ObjectHash
+	for _, have := range query["have"] {
+		var hash gud.ObjectHash
+		n, err := hex.Decode(hash[:], []byte(have))
+		if err != nil || n != len(hash) {
+			reportError(w, http.StatusBadRequest, "invalid have hash")
+			return
+		}
+		haves = append(haves, hash)
+	}
+
+	// The interrupted transfer the client has a part of, and whether the client replaces its branch
+	options := gud.PushOptions{Start: start, Haves: haves, Resume: query.Get("resume"), Force: query.Get("force") != ""}
+	if options.Resume != "" {
+		skip, err := strconv.Atoi(query.Get("skip"))
+		if err != nil || skip < 0 {
+			reportError(w, http.StatusBadRequest, "invalid skip")
+			return
+		}
+		options.Skip = skip
+	}
+
 	project, err := gud.Load(contextProjectPath(r.Context()))
 	if err != nil {
 		handleError(w, err)
@@ -134,9 +170,9 @@ func pullProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var buf bytes.Buffer
-	boundary, err := project.PushBranch(&buf, branches[0], start)
+	boundary, err := project.PushBranch(&buf, branches[0], options)
 	if err != nil {
-		handleError(w, err)
+		reportTransferError(w, err)
 		return
 	}
 
@@ -260,13 +296,21 @@ func pullProjectFrom(w http.ResponseWriter, r *http.Request, project gud.Project
 		return
 	}
 
-	hash, err := project.PullBranchFrom(branch, r.Body, r.Header.Get("Content-Type"), username)
+	var forbidForce bool
+	err = getProtectionStmt.QueryRow(r.Context().Value(KeyProjectId), branch).Scan(&forbidForce)
+	if err != nil && err != sql.ErrNoRows {
+		handleError(w, err)
+		return
+	}
+
+	if !beginTransaction(w, project) {
+		return
+	}
+	hash, err := project.PullBranchWith(branch, r.Body, r.Header.Get("Content-Type"),
+		gud.PullOptions{User: username, ForbidForce: forbidForce})
+	endTransaction(project, err)
 	if err != nil {
-		if inputErr, ok := err.(gud.InputError); ok {
-			reportError(w, http.StatusBadRequest, inputErr.Error())
-		} else {
-			handleError(w, err)
-		}
+		reportTransferError(w, err)
 		return
 	}
 
@@ -278,6 +322,86 @@ func pullProjectFrom(w http.ResponseWriter, r *http.Request, project gud.Project
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// reportTransferError reports why a push or a pull failed to the user, with the code of a non-fast-forward error.
+func reportTransferError(w http.ResponseWriter, err error) {
+	if _, ok := err.(gud.NonFastForwardError); ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(gud.ErrorResponse{Error: err.Error(), Code: gud.NonFastForwardCode})
+		return
+	}
+	if err == gud.ErrForceForbidden {
+		reportError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if inputErr, ok := err.(gud.InputError); ok {
+		reportError(w, http.StatusBadRequest, inputErr.Error())
+		return
+	}
+	handleError(w, err)
+}
+
+func getBranchProtection(w http.ResponseWriter, r *http.Request) {
+	var protection gud.BranchProtection
+	err := getProtectionStmt.QueryRow(r.Context().Value(KeyProjectId), mux.Vars(r)["branch"]).Scan(
+		&protection.ForbidForce)
+	if err != nil && err != sql.ErrNoRows {
+		handleError(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(protection)
+	if err != nil {
+		handleError(w, err)
+	}
+}
+
+// setBranchProtection protects a branch of a project, which only its owner can do.
+func setBranchProtection(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value(KeyUserId) != r.Context().Value(KeySelectedUserId) {
+		reportError(w, http.StatusForbidden, "only the owner of the project can protect its branches")
+		return
+	}
+
+	var protection gud.BranchProtection
+	err := json.NewDecoder(r.Body).Decode(&protection)
+	if err != nil {
+		reportError(w, http.StatusBadRequest, "failed to receive protection data")
+		return
+	}
+
+	_, err = setProtectionStmt.Exec(r.Context().Value(KeyProjectId), mux.Vars(r)["branch"], protection.ForbidForce)
+	if err != nil {
+		handleError(w, err)
+	}
+}
+
+// beginTransaction locks a project for a request that changes it, and reports to the user if it is busy.
+func beginTransaction(w http.ResponseWriter, p gud.Project) bool {
+	err := p.Begin()
+	if _, ok := err.(gud.Error); ok {
+		reportError(w, http.StatusConflict, err.Error())
+		return false
+	}
+	if err != nil {
+		handleError(w, err)
+		return false
+	}
+	return true
+}
+
+// endTransaction keeps the changes of a request that succeeded, and rolls back the ones of a request that failed.
+func endTransaction(p gud.Project, err error) {
+	if err != nil {
+		err = p.Abort()
+	} else {
+		err = p.Commit()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
 func verifyProject(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -22,6 +22,8 @@ func createUserRouter(route *mux.Route, selector mux.MiddlewareFunc) {
 	project.Use(verifyProject)
 	project.HandleFunc("/branches", projectBranches).Methods(http.MethodGet)
 	project.HandleFunc("/branch/{branch}", projectBranch).Methods(http.MethodGet)
+	project.HandleFunc("/branch/{branch}/protection", getBranchProtection).Methods(http.MethodGet)
+	project.HandleFunc("/branch/{branch}/protection", setBranchProtection).Methods(http.MethodPost)
 	project.HandleFunc("/push", pushProject).Methods(http.MethodPost)
 	project.HandleFunc("/pull", pullProject).Methods(http.MethodGet)
 	project.HandleFunc("/jobs", getJobs).Methods(http.MethodGet)